@@ -6,6 +6,7 @@ import (
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http"
 	"github.com/lejianwen/rustdesk-api/v2/lib/cache"
+	"github.com/lejianwen/rustdesk-api/v2/lib/crypt"
 	"github.com/lejianwen/rustdesk-api/v2/lib/jwt"
 	"github.com/lejianwen/rustdesk-api/v2/lib/lock"
 	"github.com/lejianwen/rustdesk-api/v2/lib/logger"
@@ -41,6 +42,13 @@ var rootCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		global.Logger.Info("API SERVER START")
+		go service.AllService.AddressBookService.CleanExpiredRulesEvery(time.Hour)
+		go service.AllService.RelayServerService.SyncEvery(5 * time.Minute)
+		go service.AllService.IpRuleService.SyncEvery(5 * time.Minute)
+		go service.AllService.ServerCmdScheduleService.RunEvery(15 * time.Second)
+		if global.Config.Admin.RelayUsageInterval > 0 {
+			go service.AllService.RelayUsageService.CollectEvery(global.Config.Admin.RelayUsageInterval, global.Config.Admin.RelayUsageRetention)
+		}
 		http.ApiInit()
 	},
 }
@@ -96,9 +104,31 @@ var resetUserPwdCmd = &cobra.Command{
 	},
 }
 
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Encrypt secret columns with the current crypto key",
+	Long:  "Encrypt plaintext secret columns and re-encrypt values of the old keys (crypto.old-keys) with the current key (crypto.key)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !global.Crypt.Enabled() {
+			global.Logger.Error("crypto key is not configured!")
+			return
+		}
+		res, err := service.AllService.SecretService.Rekey(global.Crypt)
+		for name, n := range res {
+			global.Logger.Info("rekey ", name, ": ", n)
+		}
+		if err != nil {
+			global.Logger.Error("rekey fail! ", err)
+			return
+		}
+		global.Logger.Info("rekey success!")
+	},
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&global.ConfigPath, "config", "c", "./conf/config.yaml", "choose config file")
-	rootCmd.AddCommand(resetPwdCmd, resetUserPwdCmd)
+	rootCmd.AddCommand(resetPwdCmd, resetUserPwdCmd, rekeyCmd)
 }
 func main() {
 	if err := rootCmd.Execute(); err != nil {
@@ -138,7 +168,14 @@ func InitGlobal() {
 			Password: global.Config.Cache.RedisPwd,
 			DB:       global.Config.Cache.RedisDb,
 		})
+	} else {
+		// 未配置时使用内存缓存
+		global.Cache = cache.NewMemoryCache(0)
 	}
+	//敏感字段加密, 必须在gorm解析model之前注册
+	global.Crypt = crypt.NewKeyring(global.Config.Crypto.Key, global.Config.Crypto.OldKeys)
+	crypt.RegisterSerializer(global.Crypt)
+
 	//gorm
 	var dns string
 	if global.Config.Gorm.Type == config.TypeMysql {
@@ -186,24 +223,42 @@ func InitGlobal() {
 	//jwt
 	//fmt.Println(global.Config.Jwt.PrivateKey)
 	global.Jwt = jwt.NewJwt(global.Config.Jwt.Key, global.Config.Jwt.ExpireDuration)
-	//locker
-	global.Lock = lock.NewLocal()
+	//locker, 多实例部署时锁、登录限制和心跳缓存使用redis共享
+	redisPrefix := global.Config.Redis.Prefix
+	if global.Config.Redis.Enabled() {
+		rl := lock.NewRedis(global.Redis, redisPrefix+"lock:", lock.DefaultLeaseTTL)
+		rl.OnError = func(err error) {
+			global.Logger.Warn("redis lock: ", err)
+		}
+		global.Lock = rl
+	} else {
+		global.Lock = lock.NewLocal()
+	}
 
 	//service
-	service.New(&global.Config, global.DB, global.Logger, global.Jwt, global.Lock)
+	service.New(&global.Config, global.DB, global.Logger, global.Jwt, global.Lock, global.Cache)
 
-	global.LoginLimiter = utils.NewLoginLimiter(utils.SecurityPolicy{
+	var limiterStore utils.LimiterStore = utils.NewMemoryLimiterStore()
+	if global.Config.Redis.Enabled() {
+		rs := utils.NewRedisLimiterStore(global.Redis, redisPrefix+"limiter:")
+		rs.OnError = func(err error) {
+			global.Logger.Warn("redis login limiter: ", err)
+		}
+		limiterStore = rs
+		service.PeerHeartbeats = service.NewRedisPeerHeartbeatStore(global.Redis, redisPrefix+"peer_heartbeat")
+	}
+	global.LoginLimiter = utils.NewLoginLimiterWithStore(utils.SecurityPolicy{
 		CaptchaThreshold: global.Config.App.CaptchaThreshold,
 		BanThreshold:     global.Config.App.BanThreshold,
 		AttemptsWindow:   10 * time.Minute,
 		BanDuration:      30 * time.Minute,
-	})
+	}, limiterStore)
 	global.LoginLimiter.RegisterProvider(utils.B64StringCaptchaProvider{})
 	DatabaseAutoUpdate()
 }
 
 func DatabaseAutoUpdate() {
-	version := 262
+	version := 281
 
 	db := global.DB
 
@@ -300,6 +355,15 @@ func Migrate(version uint) {
 		&model.AddressBookCollectionRule{},
 		&model.ServerCmd{},
 		&model.DeviceGroup{},
+		&model.AccessToken{},
+		&model.Role{},
+		&model.RoleBinding{},
+		&model.ShareAccessLog{},
+		&model.AddressBookRevision{},
+		&model.AddressBookSnapshot{},
+		&model.AddressBookChange{}, &model.AddressBookInvitation{}, &model.RelayServer{},
+		&model.RelayUsage{}, &model.RelayUsageConn{}, &model.IpRule{},
+		&model.ServerCmdSchedule{}, &model.ServerCmdRun{}, &model.AdminAuditLog{},
 	)
 	if err != nil {
 		global.Logger.Error("migrate err :=>", err)
@@ -0,0 +1,39 @@
+package config
+
+import "time"
+
+// 远程命令目标的类型
+const (
+	CmdTargetIdServer    = "id-server"
+	CmdTargetRelayServer = "relay-server"
+)
+
+const DefaultCmdTargetTimeout = 5 * time.Second
+
+// CmdTarget 远程的hbbs/hbbr命令端口, api和hbbs/hbbr不在同一台机器(或同一个pod)时使用
+// hbbs/hbbr只接受本机的命令连接, 需要通过ssh隧道或者目标机器上的TLS代理转发到本机
+type CmdTarget struct {
+	Name          string        `mapstructure:"name"`
+	Type          string        `mapstructure:"type"` // id-server 或 relay-server, 默认 id-server
+	Addr          string        `mapstructure:"addr"` // host:port, 使用ssh隧道时为ssh服务器上看到的地址, 如 127.0.0.1:21115
+	Tls           bool          `mapstructure:"tls"`
+	TlsCaFile     string        `mapstructure:"tls-ca-file"` // 为空使用系统证书
+	TlsSkipVerify bool          `mapstructure:"tls-skip-verify"`
+	Ssh           string        `mapstructure:"ssh"` // ssh隧道, user@host:port, 为空直接连接
+	SshPassword   string        `mapstructure:"ssh-password"`
+	SshKeyFile    string        `mapstructure:"ssh-key-file"`
+	SshKnownHosts string        `mapstructure:"ssh-known-hosts"` // known_hosts文件, 必须配置, 除非 ssh-insecure 为true
+	SshInsecure   bool          `mapstructure:"ssh-insecure"`    // 不校验主机密钥, 仅用于测试环境
+	Secret        string        `mapstructure:"secret"`          // 连接后先发送一行secret, 供目标前面的代理校验
+	AllowCmds     []string      `mapstructure:"allow-cmds"`      // 允许发送的命令(含别名), 为空不限制
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+func (t *CmdTarget) Init() {
+	if t.Type == "" {
+		t.Type = CmdTargetIdServer
+	}
+	if t.Timeout == 0 {
+		t.Timeout = DefaultCmdTargetTimeout
+	}
+}
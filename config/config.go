@@ -23,13 +23,19 @@ type App struct {
 	DisablePwdLogin  bool          `mapstructure:"disable-pwd-login"`
 	CaptchaThreshold int           `mapstructure:"captcha-threshold"`
 	BanThreshold     int           `mapstructure:"ban-threshold"`
+	MyDevices        bool          `mapstructure:"my-devices"`     // 为所有用户自动维护"我的设备"地址簿, 也可以按分组开启
+	AuthCacheTtl     time.Duration `mapstructure:"auth-cache-ttl"` // 认证时token、用户和地址簿共享权限的缓存时间, 0 为不缓存
 }
 type Admin struct {
-	Title           string `mapstructure:"title"`
-	Hello           string `mapstructure:"hello"`
-	HelloFile       string `mapstructure:"hello-file"`
-	IdServerPort    int    `mapstructure:"id-server-port"`
-	RelayServerPort int    `mapstructure:"relay-server-port"`
+	Title           string      `mapstructure:"title"`
+	Hello           string      `mapstructure:"hello"`
+	HelloFile       string      `mapstructure:"hello-file"`
+	IdServerPort    int         `mapstructure:"id-server-port"`
+	RelayServerPort int         `mapstructure:"relay-server-port"`
+	CmdTargets      []CmdTarget `mapstructure:"cmd-targets"` // 远程的hbbs/hbbr命令端口
+	// 中继用量的采集间隔, 0 为不采集; 保留时间为0时不清理
+	RelayUsageInterval  time.Duration `mapstructure:"relay-usage-interval"`
+	RelayUsageRetention time.Duration `mapstructure:"relay-usage-retention"`
 }
 type Config struct {
 	Lang     string `mapstructure:"lang"`
@@ -46,6 +52,8 @@ type Config struct {
 	Rustdesk Rustdesk
 	Proxy    Proxy
 	Ldap     Ldap
+	Crypto   Crypto
+	Mail     Mail
 }
 
 func (a *Admin) Init() {
@@ -55,6 +63,9 @@ func (a *Admin) Init() {
 	if a.RelayServerPort == 0 {
 		a.RelayServerPort = DefaultRelayServerPort
 	}
+	for i := range a.CmdTargets {
+		a.CmdTargets[i].Init()
+	}
 }
 
 // Init 初始化配置
@@ -91,6 +102,7 @@ func Init(rowVal *Config, path string) *viper.Viper {
 		panic(fmt.Errorf("Fatal error config: %s \n", err))
 	}
 	rowVal.Rustdesk.LoadKeyFile()
+	rowVal.Crypto.LoadKeyFile()
 	rowVal.Admin.Init()
 	return v
 }
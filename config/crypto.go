@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Crypto 数据库中敏感字段(设备密码, oauth secret等)的加密配置
+// 主密钥可以配置在 key, 或者 key-file 中, 也可以使用环境变量 RUSTDESK_API_CRYPTO_KEY
+// 轮换主密钥时把旧的主密钥放入 old-keys, 然后执行 rekey 命令
+type Crypto struct {
+	Key     string   `mapstructure:"key"`
+	KeyFile string   `mapstructure:"key-file"`
+	OldKeys []string `mapstructure:"old-keys"`
+}
+
+func (c *Crypto) LoadKeyFile() {
+	if c.Key != "" || c.KeyFile == "" {
+		return
+	}
+	b, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return
+	}
+	c.Key = strings.TrimSpace(string(b))
+}
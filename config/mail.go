@@ -0,0 +1,11 @@
+package config
+
+// Mail 发送邮件的smtp配置, host为空时不发送邮件
+type Mail struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+	Tls      bool   `mapstructure:"tls"` // 直接使用tls连接(一般是465端口), 否则服务器支持时使用STARTTLS
+}
@@ -4,4 +4,10 @@ type Redis struct {
 	Addr     string
 	Password string
 	Db       int
+	Prefix   string // key的前缀, 多个部署共用一个redis时用来区分
+}
+
+// Enabled 设置了地址时锁、登录限制和心跳缓存保存到redis, 多实例部署时共享
+func (r *Redis) Enabled() bool {
+	return r.Addr != ""
 }
@@ -3,6 +3,7 @@ package admin
 import (
 	"encoding/json"
 	_ "encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
@@ -76,7 +77,11 @@ func (ct *AddressBook) Create(c *gin.Context) {
 		return
 	}
 
-	err := service.AllService.AddressBookService.Create(t)
+	err := service.AllService.AddressBookService.Create(service.AllService.UserService.CurUser(c), t)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -127,7 +132,7 @@ func (ct *AddressBook) BatchCreate(c *gin.Context) {
 		for _, ft := range f.Tags {
 			exTag := service.AllService.TagService.InfoByUserIdAndNameAndCollectionId(fu, ft, 0)
 			if exTag.Id == 0 {
-				service.AllService.TagService.Create(&model.Tag{
+				service.AllService.TagService.Create(service.AllService.UserService.CurUser(c), &model.Tag{
 					UserId: fu,
 					Name:   ft,
 				})
@@ -141,7 +146,7 @@ func (ct *AddressBook) BatchCreate(c *gin.Context) {
 		}
 		ex := service.AllService.AddressBookService.InfoByUserIdAndIdAndCid(t.UserId, t.Id, t.CollectionId)
 		if ex.RowId == 0 {
-			service.AllService.AddressBookService.Create(t)
+			service.AllService.AddressBookService.Create(service.AllService.UserService.CurUser(c), t)
 		}
 	}
 
@@ -232,7 +237,11 @@ func (ct *AddressBook) Update(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
 		return
 	}
-	err := service.AllService.AddressBookService.UpdateAll(t)
+	err := service.AllService.AddressBookService.UpdateAll(service.AllService.UserService.CurUser(c), t)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -268,7 +277,7 @@ func (ct *AddressBook) Delete(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
 		return
 	}
-	err := service.AllService.AddressBookService.Delete(t)
+	err := service.AllService.AddressBookService.Delete(service.AllService.UserService.CurUser(c), t)
 	if err == nil {
 		response.Success(c, nil)
 		return
@@ -307,6 +316,10 @@ func (ct *AddressBook) ShareByWebClient(c *gin.Context) {
 	}
 	m := f.ToShareRecord()
 	m.UserId = u.Id
+	if err := service.AllService.ShareRecordService.ValidateShareRecord(m); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
 	err := service.AllService.AddressBookService.ShareByWebClient(m)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
@@ -356,7 +369,142 @@ func (ct *AddressBook) BatchCreateFromPeers(c *gin.Context) {
 		if ex.RowId != 0 {
 			continue
 		}
-		service.AllService.AddressBookService.Create(ab)
+		service.AllService.AddressBookService.Create(service.AllService.UserService.CurUser(c), ab)
 	}
 	response.Success(c, nil)
 }
+
+// Export 导出
+// @Tags 地址簿
+// @Summary 地址簿导出
+// @Description 导出用户地址簿的设备和标签, format 为 json(客户端格式) 或 csv
+// @Accept  json
+// @Produce  octet-stream
+// @Param user_id query int true "用户id"
+// @Param collection_id query int false "地址簿id, 0为默认地址簿"
+// @Param format query string false "json/csv"
+// @Success 200 {file} file
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book/export [get]
+// @Security token
+func (ct *AddressBook) Export(c *gin.Context) {
+	query := &admin.AddressBookExportQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, query)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	if query.UserId == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
+		return
+	}
+	if query.CollectionId > 0 && !service.AllService.AddressBookService.CheckCollectionOwner(query.UserId, query.CollectionId) {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	data := service.AllService.AddressBookService.Export(query.UserId, query.CollectionId)
+	body, err := service.AllService.AddressBookService.Encode(data, query.Format)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Download(c, "address_book_"+strconv.Itoa(int(query.UserId)), query.Format, body)
+}
+
+// Import 导入
+// @Tags 地址簿
+// @Summary 地址簿导入
+// @Description 导入json(客户端格式)或csv到用户的地址簿, mode 为已存在设备的处理方式: skip(默认)/overwrite/merge(合并标签)
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookImportForm true "导入内容"
+// @Success 200 {object} response.Response{data=model.AddressBookImportResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book/import [post]
+// @Security token
+func (ct *AddressBook) Import(c *gin.Context) {
+	f := &admin.AddressBookImportForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	if f.UserId == 0 || service.AllService.UserService.InfoById(f.UserId).Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if f.CollectionId > 0 && !service.AllService.AddressBookService.CheckCollectionOwner(f.UserId, f.CollectionId) {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	data, err := service.AllService.AddressBookService.Decode([]byte(f.Data), f.Format)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res, err := service.AllService.AddressBookService.Import(service.AllService.UserService.CurUser(c), f.UserId, f.CollectionId, data, f.Mode)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, res)
+}
+
+// Bulk 批量操作
+// @Tags 地址簿
+// @Summary 地址簿批量操作
+// @Description 批量操作用户的地址簿条目, user_id为0时不限制用户, selector 按row_ids、地址簿、标签或id/hostname/username/alias选择条目, 最多1000条
+// @Description action: move/copy(target_collection_id), add_tags/remove_tags(tags), set_alias(alias, 支持{id} {hostname} {username} {platform} {alias} {n}),
+// @Description set_force_always_relay/set_rdp_port/set_rdp_username, delete. 有条目失败时全部不生效, 返回每个条目的结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookBulkForm true "批量操作"
+// @Success 200 {object} response.Response{data=model.AddressBookBulkResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book/bulk [post]
+// @Security token
+func (ct *AddressBook) Bulk(c *gin.Context) {
+	f := &admin.AddressBookBulkForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	abs, err := service.AllService.AddressBookService.BulkSelect(f.UserId, &f.Selector)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	res, err := service.AllService.AddressBookService.Bulk(u, abs, f.ToAction())
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) || errors.Is(err, service.ErrCollectionNotFound) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	for _, item := range res.Items {
+		if item.Error != "" {
+			item.Error = response.TranslateMsg(c, item.Error)
+		}
+	}
+	response.Success(c, res)
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+)
+
+type AddressBookChange struct {
+}
+
+// List 列表
+// @Tags 地址簿修改历史
+// @Summary 地址簿修改历史
+// @Description 地址簿修改历史, 按时间倒序
+// @Accept  json
+// @Produce  json
+// @Param user_id query int false "地址簿所有者"
+// @Param collection_id query int false "地址簿id"
+// @Param target query string false "peer/tag/collection"
+// @Param target_id query string false "设备id/标签名/地址簿id"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.AddressBookChangeList}
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_change/list [get]
+// @Security token
+func (abc *AddressBookChange) List(c *gin.Context) {
+	query := &admin.AddressBookChangeQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.AddressBookService.ChangeList(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.UserId > 0 {
+			tx.Where("user_id = ?", query.UserId)
+		}
+		if query.CollectionId != nil && *query.CollectionId >= 0 {
+			tx.Where("collection_id = ?", *query.CollectionId)
+		}
+		if query.Target != "" {
+			tx.Where("target = ?", query.Target)
+		}
+		if query.TargetId != "" {
+			tx.Where("target_id = ?", query.TargetId)
+		}
+	})
+	response.Success(c, res)
+}
+
+// Restore 恢复
+// @Tags 地址簿修改历史
+// @Summary 恢复到某次修改之前
+// @Description scope 为 entry(默认) 时恢复单个设备/标签, 为 collection 时恢复整个地址簿(包括已删除的地址簿)
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookRestoreForm true "恢复"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_change/restore [post]
+// @Security token
+func (abc *AddressBookChange) Restore(c *gin.Context) {
+	f := &admin.AddressBookRestoreForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	ch := service.AllService.AddressBookService.ChangeInfoById(f.ChangeId)
+	if ch.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	var err error
+	if f.Scope == model.AddressBookChangeTargetCollection {
+		err = service.AllService.AddressBookService.RestoreCollection(u, ch)
+	} else {
+		err = service.AllService.AddressBookService.RestoreEntry(u, ch)
+	}
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
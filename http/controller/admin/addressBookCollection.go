@@ -37,6 +37,28 @@ func (abc *AddressBookCollection) Detail(c *gin.Context) {
 	return
 }
 
+// Peers 智能地址簿当前的设备
+// @Tags 地址簿名称
+// @Summary 智能地址簿设备
+// @Description 按筛选条件生成的设备列表
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.AddressBookList}
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_collection/peers/{id} [get]
+// @Security token
+func (abc *AddressBookCollection) Peers(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	t := service.AllService.AddressBookService.CollectionInfoById(uint(iid))
+	if t.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	response.Success(c, service.AllService.AddressBookService.SmartPeers(t))
+}
+
 // Create 创建地址簿名称
 // @Tags 地址簿名称
 // @Summary 创建地址簿名称
@@ -64,7 +86,7 @@ func (abc *AddressBookCollection) Create(c *gin.Context) {
 		return
 	}
 	t := f
-	err := service.AllService.AddressBookService.CreateCollection(t)
+	err := service.AllService.AddressBookService.CreateCollection(service.AllService.UserService.CurUser(c), t)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -127,7 +149,7 @@ func (abc *AddressBookCollection) Update(c *gin.Context) {
 		return
 	}
 	t := f //f.ToAddressBookCollection()
-	err := service.AllService.AddressBookService.UpdateCollection(t)
+	err := service.AllService.AddressBookService.UpdateCollection(service.AllService.UserService.CurUser(c), t)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -163,7 +185,7 @@ func (abc *AddressBookCollection) Delete(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
 		return
 	}
-	err := service.AllService.AddressBookService.DeleteCollection(ex)
+	err := service.AllService.AddressBookService.DeleteCollection(service.AllService.UserService.CurUser(c), ex)
 	if err == nil {
 		response.Success(c, nil)
 		return
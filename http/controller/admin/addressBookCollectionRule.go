@@ -134,6 +134,9 @@ func (abcr *AddressBookCollectionRule) CheckForm(t *model.AddressBookCollectionR
 	} else {
 		return "ParamsError", false
 	}
+	if err := t.CheckSchedule(); err != nil {
+		return "ParamsError", false
+	}
 	// 重复检查
 	ex := service.AllService.AddressBookService.RuleInfoByToIdAndCid(t.Type, t.ToId, t.CollectionId)
 	if t.Id == 0 && ex.Id > 0 {
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+)
+
+type AddressBookInvitation struct {
+}
+
+// List 列表
+// @Tags 地址簿邀请
+// @Summary 地址簿邀请列表
+// @Description 所有地址簿邀请, 可以按状态筛选
+// @Accept  json
+// @Produce  json
+// @Param collection_id query int false "地址簿id"
+// @Param status query string false "pending/accepted/expired"
+// @Param email query string false "邮箱"
+// @Param user_id query int false "邀请人id"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.AddressBookInvitationList}
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_invitation/list [get]
+// @Security token
+func (abi *AddressBookInvitation) List(c *gin.Context) {
+	query := &admin.AddressBookInvitationQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.AddressBookInvitationService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.UserId > 0 {
+			tx.Where("user_id = ?", query.UserId)
+		}
+		if query.CollectionId > 0 {
+			tx.Where("collection_id = ?", query.CollectionId)
+		}
+		if query.Email != "" {
+			tx.Where("email like ?", "%"+query.Email+"%")
+		}
+		service.AllService.AddressBookInvitationService.WhereStatus(tx, query.Status)
+	})
+	response.Success(c, res)
+}
+
+// Create 邀请
+// @Tags 地址簿邀请
+// @Summary 邀请外部用户共享地址簿
+// @Description 发送邀请邮件, 未配置邮件时需要把返回的url发给对方
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookInvitationForm true "邀请"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_invitation/create [post]
+// @Security token
+func (abi *AddressBookInvitation) Create(c *gin.Context) {
+	f := &admin.AddressBookInvitationForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	// 邀请人为地址簿所有者
+	collection := service.AllService.AddressBookService.CollectionInfoById(f.CollectionId)
+	if collection.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	inv := &model.AddressBookInvitation{
+		UserId:       collection.UserId,
+		CollectionId: f.CollectionId,
+		Email:        f.Email,
+		Rule:         f.Rule,
+		ExpiredAt:    f.ExpiredAt,
+	}
+	token, err := service.AllService.AddressBookInvitationService.Create(inv)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	abi.send(c, inv, token)
+}
+
+// Resend 重新发送
+// @Tags 地址簿邀请
+// @Summary 重新发送邀请
+// @Description 重新生成链接并延长有效期, 之前的链接失效, 也用于延长已过期的邀请
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookInvitationIdForm true "邀请id"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_invitation/resend [post]
+// @Security token
+func (abi *AddressBookInvitation) Resend(c *gin.Context) {
+	inv := abi.info(c)
+	if inv == nil {
+		return
+	}
+	token, err := service.AllService.AddressBookInvitationService.Renew(inv)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	abi.send(c, inv, token)
+}
+
+// Delete 删除
+// @Tags 地址簿邀请
+// @Summary 删除邀请
+// @Description 删除后链接失效, 已接受的邀请删除后不影响已创建的共享规则
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookInvitationIdForm true "邀请id"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/address_book_invitation/delete [post]
+// @Security token
+func (abi *AddressBookInvitation) Delete(c *gin.Context) {
+	inv := abi.info(c)
+	if inv == nil {
+		return
+	}
+	if err := service.AllService.AddressBookInvitationService.Delete(inv); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// info 取邀请, 失败时已经响应
+func (abi *AddressBookInvitation) info(c *gin.Context) *model.AddressBookInvitation {
+	f := &admin.AddressBookInvitationIdForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return nil
+	}
+	inv := service.AllService.AddressBookInvitationService.InfoById(f.Id)
+	if inv.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return nil
+	}
+	return inv
+}
+
+// send 发送邀请邮件, 返回邀请链接和是否发送成功
+func (abi *AddressBookInvitation) send(c *gin.Context, inv *model.AddressBookInvitation, token string) {
+	sent := false
+	if service.AllService.AddressBookInvitationService.MailEnabled() {
+		if err := service.AllService.AddressBookInvitationService.Send(inv, token); err != nil {
+			global.Logger.Warn("send invitation mail failed: ", err)
+		} else {
+			sent = true
+		}
+	}
+	response.Success(c, gin.H{
+		"invitation": inv,
+		"url":        service.AllService.AddressBookInvitationService.AcceptUrl(token),
+		"sent":       sent,
+	})
+}
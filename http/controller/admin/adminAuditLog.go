@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+type AdminAuditLog struct {
+}
+
+// List 列表
+// @Tags 操作日志
+// @Summary 后台操作日志列表
+// @Description 后台修改类请求的记录, 包括操作人、ip、路由、对象和修改前后的值, 不可删除
+// @Accept  json
+// @Produce  json
+// @Param user_id query int false "操作人ID"
+// @Param username query string false "操作人"
+// @Param target query string false "对象类型, 如oauth"
+// @Param target_id query string false "对象ID"
+// @Param route query string false "路由"
+// @Param ip query string false "IP"
+// @Param failed query bool false "只看失败的请求"
+// @Param start query int false "开始时间(unix秒)"
+// @Param end query int false "结束时间(unix秒)"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.AdminAuditLogList}
+// @Failure 500 {object} response.Response
+// @Router /admin/admin_audit_log/list [get]
+// @Security token
+func (ct *AdminAuditLog) List(c *gin.Context) {
+	query := &admin.AdminAuditLogQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.AdminAuditService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.UserId > 0 {
+			tx.Where("user_id = ?", query.UserId)
+		}
+		if query.Username != "" {
+			tx.Where("username = ?", query.Username)
+		}
+		if query.Target != "" {
+			tx.Where("target = ?", query.Target)
+		}
+		if query.TargetId != "" {
+			tx.Where("target_id = ?", query.TargetId)
+		}
+		if query.Route != "" {
+			tx.Where("route like ?", "%"+query.Route+"%")
+		}
+		if query.Ip != "" {
+			tx.Where("ip = ?", query.Ip)
+		}
+		if query.Failed {
+			tx.Where("code <> 0")
+		}
+		if query.Start > 0 {
+			tx.Where("created_at >= ?", time.Unix(query.Start, 0))
+		}
+		if query.End > 0 {
+			tx.Where("created_at < ?", time.Unix(query.End, 0))
+		}
+	})
+	response.Success(c, res)
+}
+
+// Detail 详情
+// @Tags 操作日志
+// @Summary 后台操作日志详情
+// @Description 后台操作日志详情
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.AdminAuditLog}
+// @Failure 500 {object} response.Response
+// @Router /admin/admin_audit_log/detail/{id} [get]
+// @Security token
+func (ct *AdminAuditLog) Detail(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	l := service.AllService.AdminAuditService.InfoById(uint(iid))
+	if l.Id > 0 {
+		response.Success(c, l)
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+)
+
+// Invitation 被邀请人使用的接口
+type Invitation struct {
+}
+
+// Info 邀请信息
+// @Tags 地址簿邀请
+// @Summary 邀请信息
+// @Description 根据邀请链接中的token取邀请信息, 不需要登录
+// @Accept  json
+// @Produce  json
+// @Param body body admin.InvitationTokenForm true "token"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/invitation/info [post]
+func (ct *Invitation) Info(c *gin.Context) {
+	inv := ct.pending(c, &admin.InvitationTokenForm{})
+	if inv == nil {
+		return
+	}
+	inviter := service.AllService.UserService.InfoById(inv.UserId)
+	collection := service.AllService.AddressBookService.CollectionInfoById(inv.CollectionId)
+	response.Success(c, gin.H{
+		"email":           inv.Email,
+		"rule":            inv.Rule,
+		"expired_at":      inv.ExpiredAt,
+		"inviter":         inviter.Username,
+		"collection_name": collection.Name,
+		"can_register":    service.AllService.AddressBookInvitationService.CanRegister(inv),
+	})
+}
+
+// Register 注册并接受邀请
+// @Tags 地址簿邀请
+// @Summary 注册并接受邀请
+// @Description 使用邀请的邮箱注册新用户并接受邀请, 需要开放注册或者邀请人是管理员; 邀请人不是管理员时按 app.register-status 可能需要管理员审核
+// @Accept  json
+// @Produce  json
+// @Param body body admin.InvitationRegisterForm true "注册信息"
+// @Success 200 {object} response.Response{data=adResp.LoginPayload}
+// @Failure 500 {object} response.Response
+// @Router /admin/invitation/register [post]
+func (ct *Invitation) Register(c *gin.Context) {
+	f := &admin.InvitationRegisterForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	if f.Password != f.ConfirmPassword {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
+		return
+	}
+	inv := service.AllService.AddressBookInvitationService.InfoByToken(f.Token)
+	if inv.Id == 0 || inv.Status != model.AddressBookInvitationPending {
+		response.Fail(c, 101, response.TranslateMsg(c, "InvitationInvalid"))
+		return
+	}
+	if !service.AllService.AddressBookInvitationService.CanRegister(inv) {
+		response.Fail(c, 101, response.TranslateMsg(c, "RegisterClosed"))
+		return
+	}
+	// 邮箱已有账号时需要登录后接受
+	if ex := service.AllService.UserService.InfoByEmail(inv.Email); ex.Id > 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemExists"))
+		return
+	}
+	regStatus := service.AllService.AddressBookInvitationService.RegisterStatus(inv)
+	u := service.AllService.UserService.Register(f.Username, inv.Email, f.Password, regStatus)
+	if u == nil || u.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed"))
+		return
+	}
+	if err := service.AllService.AddressBookInvitationService.Accept(inv, u); err != nil {
+		ct.fail(c, err)
+		return
+	}
+	if regStatus == model.COMMON_STATUS_DISABLED {
+		// 需要管理员审核
+		response.Fail(c, 101, response.TranslateMsg(c, "RegisterSuccessWaitAdminConfirm"))
+		return
+	}
+	ut := service.AllService.UserService.Login(u, &model.LoginLog{
+		UserId: u.Id,
+		Client: model.LoginLogClientWebAdmin,
+		Uuid:   "",
+		Ip:     c.ClientIP(),
+		Type:   model.LoginLogTypeAccount,
+	})
+	responseLoginSuccess(c, u, ut.Token)
+}
+
+// Accept 接受邀请
+// @Tags 地址簿邀请
+// @Summary 接受邀请
+// @Description 已登录(包括oauth登录)的用户接受邀请, 用户有邮箱时必须和邀请的邮箱一致
+// @Accept  json
+// @Produce  json
+// @Param body body admin.InvitationTokenForm true "token"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/invitation/accept [post]
+// @Security token
+func (ct *Invitation) Accept(c *gin.Context) {
+	inv := ct.pending(c, &admin.InvitationTokenForm{})
+	if inv == nil {
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	if err := service.AllService.AddressBookInvitationService.Accept(inv, u); err != nil {
+		ct.fail(c, err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// pending 取未接受且未过期的邀请, 失败时已经响应
+func (ct *Invitation) pending(c *gin.Context, f *admin.InvitationTokenForm) *model.AddressBookInvitation {
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return nil
+	}
+	inv := service.AllService.AddressBookInvitationService.InfoByToken(f.Token)
+	if inv.Id == 0 || inv.Status != model.AddressBookInvitationPending {
+		response.Fail(c, 101, response.TranslateMsg(c, "InvitationInvalid"))
+		return nil
+	}
+	return inv
+}
+
+func (ct *Invitation) fail(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvitationInvalid) || errors.Is(err, service.ErrInvitationEmailMismatch) || err.Error() == "CannotShareToSelf" {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+}
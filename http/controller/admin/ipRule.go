@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+type IpRule struct {
+}
+
+// List 列表
+// @Tags IP封禁
+// @Summary IP封禁规则列表
+// @Description IP封禁规则列表
+// @Accept  json
+// @Produce  json
+// @Param cidr query string false "ip或网段"
+// @Param scope query string false "范围 all/api/relay"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.IpRuleList}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/list [get]
+// @Security token
+func (ct *IpRule) List(c *gin.Context) {
+	query := &admin.IpRuleQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.IpRuleService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.Cidr != "" {
+			tx.Where("cidr like ?", "%"+query.Cidr+"%")
+		}
+		if query.Scope != "" {
+			tx.Where("scope = ?", query.Scope)
+		}
+	})
+	response.Success(c, res)
+}
+
+// Detail 详情
+// @Tags IP封禁
+// @Summary IP封禁规则详情
+// @Description IP封禁规则详情
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.IpRule}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/detail/{id} [get]
+// @Security token
+func (ct *IpRule) Detail(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	r := service.AllService.IpRuleService.InfoById(uint(iid))
+	if r.Id > 0 {
+		response.Success(c, r)
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
+
+// Create 创建
+// @Tags IP封禁
+// @Summary 创建IP封禁规则
+// @Description 创建后立即在api中生效, 单个ip同步到hbbr的blocklist, 返回每个中继的结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.IpRuleForm true "规则信息"
+// @Success 200 {object} response.Response{data=model.IpRuleSyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/create [post]
+// @Security token
+func (ct *IpRule) Create(c *gin.Context) {
+	r := ct.form(c)
+	if r == nil {
+		return
+	}
+	if ex := service.AllService.IpRuleService.InfoByCidr(r.Cidr); ex.Id > 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemExists"))
+		return
+	}
+	r.Id = 0
+	res, err := service.AllService.IpRuleService.Create(c.Request.Context(), r)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, res)
+}
+
+// Update 编辑
+// @Tags IP封禁
+// @Summary 编辑IP封禁规则
+// @Description 编辑后同步到hbbr, ip或范围变化时先从hbbr中移除旧的ip
+// @Accept  json
+// @Produce  json
+// @Param body body admin.IpRuleForm true "规则信息"
+// @Success 200 {object} response.Response{data=model.IpRuleSyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/update [post]
+// @Security token
+func (ct *IpRule) Update(c *gin.Context) {
+	r := ct.form(c)
+	if r == nil {
+		return
+	}
+	old := service.AllService.IpRuleService.InfoById(r.Id)
+	if r.Id == 0 || old.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if ex := service.AllService.IpRuleService.InfoByCidr(r.Cidr); ex.Id > 0 && ex.Id != r.Id {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemExists"))
+		return
+	}
+	res, err := service.AllService.IpRuleService.Update(c.Request.Context(), old, r)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, res)
+}
+
+// Delete 删除
+// @Tags IP封禁
+// @Summary 删除IP封禁规则
+// @Description 删除即解封: 从hbbr的blocklist中移除, 解除hbbs ip-blocker和登录失败的封禁
+// @Accept  json
+// @Produce  json
+// @Param body body admin.IpRuleForm true "规则信息"
+// @Success 200 {object} response.Response{data=model.IpRuleSyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/delete [post]
+// @Security token
+func (ct *IpRule) Delete(c *gin.Context) {
+	f := &admin.IpRuleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	r := service.AllService.IpRuleService.InfoById(f.Id)
+	if r.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	res, err := service.AllService.IpRuleService.Delete(c.Request.Context(), r)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	global.LoginLimiter.Unban(r.Cidr)
+	response.Success(c, res)
+}
+
+// Sync 同步
+// @Tags IP封禁
+// @Summary 同步IP封禁规则到hbbr
+// @Description 删除过期的规则, 并把所有单个ip的规则同步到hbbr的blocklist(hbbr重启后会丢失), 也会每5分钟自动同步
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} response.Response{data=[]model.RelayCmdResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/ip_rule/sync [post]
+// @Security token
+func (ct *IpRule) Sync(c *gin.Context) {
+	response.Success(c, service.AllService.IpRuleService.Sync(c.Request.Context()))
+}
+
+// form 绑定并校验表单, 失败时已经响应
+func (ct *IpRule) form(c *gin.Context) *model.IpRule {
+	f := &admin.IpRuleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return nil
+	}
+	r := f.ToIpRule()
+	if err := r.Check(); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	if r.LocksOut(c.ClientIP()) {
+		response.Fail(c, 101, response.TranslateMsg(c, "IpRuleLocksOut"))
+		return nil
+	}
+	return r
+}
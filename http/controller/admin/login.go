@@ -140,9 +140,16 @@ func (ct *Login) Captcha(c *gin.Context) {
 func (ct *Login) Logout(c *gin.Context) {
 	u := service.AllService.UserService.CurUser(c)
 	token, ok := c.Get("token")
+	logoutUrl := ""
 	if ok {
+		logoutUrl = service.AllService.UserService.OidcLogoutUrl(token.(string))
 		service.AllService.UserService.Logout(u, token.(string))
 	}
+	if logoutUrl != "" {
+		// oidc登录的, 返回IdP的登出地址
+		response.Success(c, gin.H{"logout_url": logoutUrl})
+		return
+	}
 	response.Success(c, nil)
 }
 
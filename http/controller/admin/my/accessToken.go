@@ -0,0 +1,116 @@
+package my
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+	"time"
+)
+
+type AccessToken struct {
+}
+
+// List 列表
+// @Tags 我的访问令牌
+// @Summary 访问令牌列表
+// @Description 访问令牌列表
+// @Accept  json
+// @Produce  json
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.AccessTokenList}
+// @Failure 500 {object} response.Response
+// @Router /admin/my/access_token/list [get]
+// @Security token
+func (ct *AccessToken) List(c *gin.Context) {
+	query := &admin.AccessTokenQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	res := service.AllService.AccessTokenService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		tx.Where("user_id = ?", u.Id)
+		tx.Order("id desc")
+	})
+	response.Success(c, res)
+}
+
+// Create 创建
+// @Tags 我的访问令牌
+// @Summary 访问令牌创建
+// @Description 访问令牌创建, 明文token只返回一次
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AccessTokenForm true "访问令牌信息"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/my/access_token/create [post]
+// @Security token
+func (ct *AccessToken) Create(c *gin.Context) {
+	f := &admin.AccessTokenForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	if f.ExpiredAt != 0 && f.ExpiredAt < time.Now().Unix() {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+"expired_at")
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	token, at, err := service.AllService.AccessTokenService.Create(u, f.Name, f.Scopes, f.ExpiredAt)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"token":        token,
+		"access_token": at,
+	})
+}
+
+// Delete 删除
+// @Tags 我的访问令牌
+// @Summary 访问令牌删除
+// @Description 访问令牌删除
+// @Accept  json
+// @Produce  json
+// @Param body body model.AccessToken true "访问令牌信息"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/my/access_token/delete [post]
+// @Security token
+func (ct *AccessToken) Delete(c *gin.Context) {
+	f := &model.AccessToken{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	id := f.Id
+	errList := global.Validator.ValidVar(c, id, "required,gt=0")
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	at := service.AllService.AccessTokenService.InfoById(f.Id)
+	u := service.AllService.UserService.CurUser(c)
+	if at.Id == 0 || at.UserId != u.Id {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	err := service.AllService.AccessTokenService.Delete(at)
+	if err == nil {
+		response.Success(c, nil)
+		return
+	}
+	response.Fail(c, 101, err.Error())
+}
@@ -2,6 +2,7 @@ package my
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
@@ -96,7 +97,11 @@ func (ct *AddressBook) Create(c *gin.Context) {
 		return
 	}
 
-	err := service.AllService.AddressBookService.Create(t)
+	err := service.AllService.AddressBookService.Create(u, t)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -150,7 +155,11 @@ func (ct *AddressBook) Update(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
 		return
 	}
-	err := service.AllService.AddressBookService.UpdateAll(t)
+	err := service.AllService.AddressBookService.UpdateAll(u, t)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -191,7 +200,7 @@ func (ct *AddressBook) Delete(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
-	err := service.AllService.AddressBookService.Delete(ex)
+	err := service.AllService.AddressBookService.Delete(u, ex)
 	if err == nil {
 		response.Success(c, nil)
 		return
@@ -241,7 +250,7 @@ func (ct *AddressBook) BatchCreateFromPeers(c *gin.Context) {
 		if ex.RowId != 0 {
 			continue
 		}
-		service.AllService.AddressBookService.Create(ab)
+		service.AllService.AddressBookService.Create(u, ab)
 	}
 	response.Success(c, nil)
 }
@@ -262,10 +271,143 @@ func (ct *AddressBook) BatchUpdateTags(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
 		return
 	}
-	err := service.AllService.AddressBookService.BatchUpdateTags(abs.AddressBooks, f.Tags)
+	err := service.AllService.AddressBookService.BatchUpdateTags(u, abs.AddressBooks, f.Tags)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
 	}
 	response.Success(c, nil)
 }
+
+// Export 导出
+// @Tags 我的地址簿
+// @Summary 地址簿导出
+// @Description 导出地址簿的设备和标签, format 为 json(客户端格式) 或 csv
+// @Accept  json
+// @Produce  octet-stream
+// @Param collection_id query int false "地址簿id, 0为默认地址簿"
+// @Param format query string false "json/csv"
+// @Success 200 {file} file
+// @Failure 500 {object} response.Response
+// @Router /admin/my/address_book/export [get]
+// @Security token
+func (ct *AddressBook) Export(c *gin.Context) {
+	query := &admin.AddressBookExportQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, query)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	if query.CollectionId > 0 && !service.AllService.AddressBookService.CheckCollectionOwner(u.Id, query.CollectionId) {
+		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
+		return
+	}
+	data := service.AllService.AddressBookService.Export(u.Id, query.CollectionId)
+	body, err := service.AllService.AddressBookService.Encode(data, query.Format)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Download(c, "address_book", query.Format, body)
+}
+
+// Import 导入
+// @Tags 我的地址簿
+// @Summary 地址簿导入
+// @Description 导入json(客户端格式)或csv, mode 为已存在设备的处理方式: skip(默认)/overwrite/merge(合并标签)
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookImportForm true "导入内容"
+// @Success 200 {object} response.Response{data=model.AddressBookImportResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/my/address_book/import [post]
+// @Security token
+func (ct *AddressBook) Import(c *gin.Context) {
+	f := &admin.AddressBookImportForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	if f.CollectionId > 0 && !service.AllService.AddressBookService.CheckCollectionOwner(u.Id, f.CollectionId) {
+		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
+		return
+	}
+	data, err := service.AllService.AddressBookService.Decode([]byte(f.Data), f.Format)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res, err := service.AllService.AddressBookService.Import(u, u.Id, f.CollectionId, data, f.Mode)
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, res)
+}
+
+// Bulk 批量操作
+// @Tags 我的地址簿
+// @Summary 地址簿批量操作
+// @Description 批量操作我的地址簿条目, selector 按row_ids、地址簿、标签或id/hostname/username/alias选择条目, 最多1000条
+// @Description action: move/copy(target_collection_id), add_tags/remove_tags(tags), set_alias(alias, 支持{id} {hostname} {username} {platform} {alias} {n}),
+// @Description set_force_always_relay/set_rdp_port/set_rdp_username, delete. 有条目失败时全部不生效, 返回每个条目的结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.AddressBookBulkForm true "批量操作"
+// @Success 200 {object} response.Response{data=model.AddressBookBulkResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/my/address_book/bulk [post]
+// @Security token
+func (ct *AddressBook) Bulk(c *gin.Context) {
+	f := &admin.AddressBookBulkForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	// 只能操作自己的条目, 目标地址簿也必须是自己的
+	if f.TargetCollectionId > 0 && !service.AllService.AddressBookService.CheckCollectionOwner(u.Id, f.TargetCollectionId) {
+		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
+		return
+	}
+	abs, err := service.AllService.AddressBookService.BulkSelect(u.Id, &f.Selector)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	res, err := service.AllService.AddressBookService.Bulk(u, abs, f.ToAction())
+	if errors.Is(err, service.ErrSmartCollectionReadOnly) || errors.Is(err, service.ErrCollectionNotFound) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	for _, item := range res.Items {
+		if item.Error != "" {
+			item.Error = response.TranslateMsg(c, item.Error)
+		}
+	}
+	response.Success(c, res)
+}
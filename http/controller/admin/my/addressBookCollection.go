@@ -8,6 +8,7 @@ import (
 	"github.com/lejianwen/rustdesk-api/v2/model"
 	"github.com/lejianwen/rustdesk-api/v2/service"
 	"gorm.io/gorm"
+	"strconv"
 )
 
 type AddressBookCollection struct {
@@ -37,7 +38,8 @@ func (abc *AddressBookCollection) Create(c *gin.Context) {
 	}
 	u := service.AllService.UserService.CurUser(c)
 	f.UserId = u.Id
-	err := service.AllService.AddressBookService.CreateCollection(f)
+	f.Auto = 0
+	err := service.AllService.AddressBookService.CreateCollection(u, f)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -45,6 +47,33 @@ func (abc *AddressBookCollection) Create(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+// Peers 智能地址簿当前的设备
+// @Tags 我的地址簿名称
+// @Summary 智能地址簿设备
+// @Description 按筛选条件生成的设备列表
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.AddressBookList}
+// @Failure 500 {object} response.Response
+// @Router /admin/my/address_book_collection/peers/{id} [get]
+// @Security token
+func (abc *AddressBookCollection) Peers(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	t := service.AllService.AddressBookService.CollectionInfoById(uint(iid))
+	if t.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	if !service.AllService.AddressBookService.CheckUserReadPrivilege(u, t.UserId, t.Id) {
+		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
+		return
+	}
+	response.Success(c, service.AllService.AddressBookService.SmartPeers(t))
+}
+
 // List 列表
 // @Tags 我的地址簿名称
 // @Summary 地址簿名称列表
@@ -107,12 +136,12 @@ func (abc *AddressBookCollection) Update(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
 		return
 	}
-	if ex.UserId != u.Id {
+	if ex.UserId != u.Id || ex.Auto > 0 {
 		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
-
-	err := service.AllService.AddressBookService.UpdateCollection(f)
+	f.Auto = 0
+	err := service.AllService.AddressBookService.UpdateCollection(u, f)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -149,11 +178,11 @@ func (abc *AddressBookCollection) Delete(c *gin.Context) {
 		return
 	}
 	u := service.AllService.UserService.CurUser(c)
-	if ex.UserId != u.Id {
+	if ex.UserId != u.Id || ex.Auto > 0 {
 		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
-	err := service.AllService.AddressBookService.DeleteCollection(ex)
+	err := service.AllService.AddressBookService.DeleteCollection(u, ex)
 	if err == nil {
 		response.Success(c, nil)
 		return
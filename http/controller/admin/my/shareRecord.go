@@ -34,6 +34,70 @@ func (sr *ShareRecord) List(c *gin.Context) {
 	res := service.AllService.ShareRecordService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
 		tx.Where("user_id = ?", u.Id)
 	})
+	service.AllService.ShareRecordService.LoadRecentAccessLogs(res.ShareRecords)
+	response.Success(c, res)
+}
+
+// Revoke 吊销分享链接
+// @Tags 我的分享记录
+// @Summary 吊销分享链接
+// @Description 吊销后链接不能再访问, 访问记录保留
+// @Accept  json
+// @Produce  json
+// @Param body body admin.ShareRecordForm true "分享记录信息"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/my/share_record/revoke [post]
+// @Security token
+func (sr *ShareRecord) Revoke(c *gin.Context) {
+	f := &admin.ShareRecordForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	i := service.AllService.ShareRecordService.InfoById(f.Id)
+	if i.Id == 0 || i.UserId != u.Id {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	err := service.AllService.ShareRecordService.Revoke(i)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// AccessLog 分享链接访问记录
+// @Tags 我的分享记录
+// @Summary 分享链接访问记录
+// @Description 分享链接访问记录
+// @Accept  json
+// @Produce  json
+// @Param share_record_id query int true "分享记录ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/my/share_record/access_log [get]
+// @Security token
+func (sr *ShareRecord) AccessLog(c *gin.Context) {
+	query := &admin.ShareAccessLogQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	u := service.AllService.UserService.CurUser(c)
+	i := service.AllService.ShareRecordService.InfoById(query.ShareRecordId)
+	if i.Id == 0 || i.UserId != u.Id {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	res := service.AllService.ShareRecordService.AccessLogList(query.Page, query.PageSize, func(tx *gorm.DB) {
+		tx.Where("share_record_id = ?", i.Id)
+		tx.Order("id desc")
+	})
 	response.Success(c, res)
 }
 
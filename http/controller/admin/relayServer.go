@@ -0,0 +1,293 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+type RelayServer struct {
+}
+
+// List 列表
+// @Tags 中继服务器
+// @Summary 中继服务器列表
+// @Description 中继服务器列表
+// @Accept  json
+// @Produce  json
+// @Param name query string false "名称"
+// @Param region query string false "区域"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.RelayServerList}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/list [get]
+// @Security token
+func (ct *RelayServer) List(c *gin.Context) {
+	query := &admin.RelayServerQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.RelayServerService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.Name != "" {
+			tx.Where("name like ?", "%"+query.Name+"%")
+		}
+		if query.Region != "" {
+			tx.Where("region = ?", query.Region)
+		}
+	})
+	response.Success(c, res)
+}
+
+// Detail 详情
+// @Tags 中继服务器
+// @Summary 中继服务器详情
+// @Description 中继服务器详情
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.RelayServer}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/detail/{id} [get]
+// @Security token
+func (ct *RelayServer) Detail(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	r := service.AllService.RelayServerService.InfoById(uint(iid))
+	if r.Id > 0 {
+		response.Success(c, r)
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
+
+// Create 创建
+// @Tags 中继服务器
+// @Summary 创建中继服务器
+// @Description 创建中继服务器, 成功后同步hbbs的relay-servers, 返回同步结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RelayServerForm true "中继服务器信息"
+// @Success 200 {object} response.Response{data=[]model.RelaySyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/create [post]
+// @Security token
+func (ct *RelayServer) Create(c *gin.Context) {
+	f := ct.form(c)
+	if f == nil {
+		return
+	}
+	if ex := service.AllService.RelayServerService.InfoByName(f.Name); ex.Id > 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemExists"))
+		return
+	}
+	r := f.ToRelayServer()
+	r.Id = 0
+	if err := service.AllService.RelayServerService.Create(r); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, service.AllService.RelayServerService.Sync(c.Request.Context()))
+}
+
+// Update 编辑
+// @Tags 中继服务器
+// @Summary 编辑中继服务器
+// @Description 编辑中继服务器, 成功后同步hbbs的relay-servers, 返回同步结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RelayServerForm true "中继服务器信息"
+// @Success 200 {object} response.Response{data=[]model.RelaySyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/update [post]
+// @Security token
+func (ct *RelayServer) Update(c *gin.Context) {
+	f := ct.form(c)
+	if f == nil {
+		return
+	}
+	old := service.AllService.RelayServerService.InfoById(f.Id)
+	if f.Id == 0 || old.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if ex := service.AllService.RelayServerService.InfoByName(f.Name); ex.Id > 0 && ex.Id != f.Id {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemExists"))
+		return
+	}
+	if err := service.AllService.RelayServerService.Update(f.ToRelayServer()); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, service.AllService.RelayServerService.SyncChanged(c.Request.Context(), old))
+}
+
+// Delete 删除
+// @Tags 中继服务器
+// @Summary 删除中继服务器
+// @Description 删除中继服务器, 成功后同步hbbs的relay-servers, 返回同步结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RelayServerForm true "中继服务器信息"
+// @Success 200 {object} response.Response{data=[]model.RelaySyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/delete [post]
+// @Security token
+func (ct *RelayServer) Delete(c *gin.Context) {
+	f := &admin.RelayServerForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	r := service.AllService.RelayServerService.InfoById(f.Id)
+	if r.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if err := service.AllService.RelayServerService.Delete(r); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, service.AllService.RelayServerService.SyncChanged(c.Request.Context(), r))
+}
+
+// SendCmd 批量发送命令
+// @Tags 中继服务器
+// @Summary 向中继服务器发送命令
+// @Description 并发发送到选中的中继(ids或region), 都为空时发送到所有启用的中继, 返回每个中继的结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RelayCmdForm true "命令"
+// @Success 200 {object} response.Response{data=[]model.RelayCmdResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/sendCmd [post]
+// @Security token
+func (ct *RelayServer) SendCmd(c *gin.Context) {
+	f := &admin.RelayCmdForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	relays := service.AllService.RelayServerService.List(1, 9999, func(tx *gorm.DB) {
+		if len(f.Ids) > 0 {
+			tx.Where("id in ?", f.Ids)
+		} else {
+			tx.Where("status = ?", model.COMMON_STATUS_ENABLE)
+		}
+		if f.Region != "" {
+			tx.Where("region = ?", f.Region)
+		}
+	})
+	if relays.Total == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	response.Success(c, service.AllService.RelayServerService.SendCmd(c.Request.Context(), relays.RelayServers, f.Cmd, f.Option))
+}
+
+// Sync 同步
+// @Tags 中继服务器
+// @Summary 同步hbbs的relay-servers
+// @Description 把启用的中继的公网地址同步到hbbs的relay-servers, 没有启用的中继时不同步
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} response.Response{data=[]model.RelaySyncResult}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/sync [post]
+// @Security token
+func (ct *RelayServer) Sync(c *gin.Context) {
+	response.Success(c, service.AllService.RelayServerService.Sync(c.Request.Context()))
+}
+
+// form 绑定并校验表单, 失败时已经响应
+func (ct *RelayServer) form(c *gin.Context) *admin.RelayServerForm {
+	f := &admin.RelayServerForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return nil
+	}
+	if f.CmdTarget != "" && service.AllService.ServerCmdService.CmdTarget(f.CmdTarget) == nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+"cmd_target")
+		return nil
+	}
+	return f
+}
+
+// Usage 用量历史
+// @Tags 中继服务器
+// @Summary 中继用量历史
+// @Description 按中继分组的会话数和总带宽, 按step秒降采样(平均值和最大值), RelayId为0的是本机的hbbr
+// @Accept  json
+// @Produce  json
+// @Param relay_ids query []int false "中继ID"
+// @Param start query int false "开始时间(unix秒)"
+// @Param end query int false "结束时间(unix秒)"
+// @Param step query int false "降采样步长(秒)"
+// @Success 200 {object} response.Response{data=[]model.RelayUsageSeries}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/usage [get]
+// @Security token
+func (ct *RelayServer) Usage(c *gin.Context) {
+	query := &admin.RelayUsageQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	start, end := query.Range()
+	if start >= end {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
+		return
+	}
+	response.Success(c, service.AllService.RelayUsageService.Series(query.RelayIds, start, end, query.Step))
+}
+
+// UsageConns 连接用量明细
+// @Tags 中继服务器
+// @Summary 中继连接用量明细
+// @Description 每次采样时每个中继连接的用量
+// @Accept  json
+// @Produce  json
+// @Param relay_id query int false "中继ID"
+// @Param conn_key query string false "连接"
+// @Param start query int false "开始时间(unix秒)"
+// @Param end query int false "结束时间(unix秒)"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.RelayUsageConnList}
+// @Failure 500 {object} response.Response
+// @Router /admin/relay_server/usage/conns [get]
+// @Security token
+func (ct *RelayServer) UsageConns(c *gin.Context) {
+	query := &admin.RelayUsageConnQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	start, end := query.Range()
+	res := service.AllService.RelayUsageService.ConnList(query.Page, query.PageSize, func(tx *gorm.DB) {
+		tx.Where("sampled_at >= ? and sampled_at < ?", start, end)
+		if query.RelayId > 0 {
+			tx.Where("relay_id = ?", query.RelayId)
+		}
+		if query.ConnKey != "" {
+			tx.Where("conn_key like ?", "%"+query.ConnKey+"%")
+		}
+	})
+	response.Success(c, res)
+}
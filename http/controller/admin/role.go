@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"strconv"
+)
+
+type Role struct {
+}
+
+// Detail 角色
+// @Tags 角色
+// @Summary 角色详情
+// @Description 角色详情
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.Role}
+// @Failure 500 {object} response.Response
+// @Router /admin/role/detail/{id} [get]
+// @Security token
+func (ct *Role) Detail(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	r := service.AllService.RoleService.InfoById(uint(iid))
+	if r.Id > 0 {
+		response.Success(c, r)
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
+
+// Permissions 可分配的权限
+// @Tags 角色
+// @Summary 可分配的权限
+// @Description 可分配的权限, 格式为 resource:action, write 包含 read
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} response.Response{data=[]string}
+// @Failure 500 {object} response.Response
+// @Router /admin/role/permissions [get]
+// @Security token
+func (ct *Role) Permissions(c *gin.Context) {
+	response.Success(c, model.AllPermissions())
+}
+
+// Create 创建角色
+// @Tags 角色
+// @Summary 创建角色
+// @Description 创建角色
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RoleForm true "角色信息"
+// @Success 200 {object} response.Response{data=model.Role}
+// @Failure 500 {object} response.Response
+// @Router /admin/role/create [post]
+// @Security token
+func (ct *Role) Create(c *gin.Context) {
+	f := &admin.RoleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	r := f.ToRole()
+	perms, err := service.AllService.RoleService.FormatPermissions(f.Permissions)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	r.Permissions = perms
+	err = service.AllService.RoleService.Create(r)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// List 列表
+// @Tags 角色
+// @Summary 角色列表
+// @Description 角色列表
+// @Accept  json
+// @Produce  json
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.RoleList}
+// @Failure 500 {object} response.Response
+// @Router /admin/role/list [get]
+// @Security token
+func (ct *Role) List(c *gin.Context) {
+	query := &admin.PageQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.RoleService.List(query.Page, query.PageSize, nil)
+	response.Success(c, res)
+}
+
+// Update 编辑
+// @Tags 角色
+// @Summary 角色编辑
+// @Description 角色编辑, 用户和分组的绑定会整体替换
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RoleForm true "角色信息"
+// @Success 200 {object} response.Response{data=model.Role}
+// @Failure 500 {object} response.Response
+// @Router /admin/role/update [post]
+// @Security token
+func (ct *Role) Update(c *gin.Context) {
+	f := &admin.RoleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	if f.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
+		return
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	r := f.ToRole()
+	perms, err := service.AllService.RoleService.FormatPermissions(f.Permissions)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	r.Permissions = perms
+	err = service.AllService.RoleService.Update(r)
+	if err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Delete 删除
+// @Tags 角色
+// @Summary 角色删除
+// @Description 角色删除
+// @Accept  json
+// @Produce  json
+// @Param body body admin.RoleForm true "角色信息"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/role/delete [post]
+// @Security token
+func (ct *Role) Delete(c *gin.Context) {
+	f := &admin.RoleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	id := f.Id
+	errList := global.Validator.ValidVar(c, id, "required,gt=0")
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return
+	}
+	r := service.AllService.RoleService.InfoById(f.Id)
+	if r.Id > 0 {
+		err := service.AllService.RoleService.Delete(r)
+		if err == nil {
+			response.Success(c, nil)
+			return
+		}
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
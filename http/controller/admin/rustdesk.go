@@ -1,10 +1,12 @@
 package admin
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/lib/servercmd"
 	"github.com/lejianwen/rustdesk-api/v2/model"
 	"github.com/lejianwen/rustdesk-api/v2/service"
 )
@@ -16,6 +18,8 @@ type RustdeskCmd struct {
 	Cmd    string `json:"cmd"`
 	Option string `json:"option"`
 	Target string `json:"target"`
+	Server string `json:"server"` // 远程目标的名称, 为空发送到本机
+	Parse  bool   `json:"parse"`  // 为true时返回原始输出和已知命令解析后的结果
 }
 
 func (r *Rustdesk) CmdList(c *gin.Context) {
@@ -111,6 +115,10 @@ func (r *Rustdesk) SendCmd(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
 		return
 	}
+	if rc.Server != "" {
+		r.sendCmdTo(c, rc)
+		return
+	}
 	if rc.Target == "" {
 		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
 		return
@@ -128,10 +136,47 @@ func (r *Rustdesk) SendCmd(c *gin.Context) {
 		port = global.Config.Admin.RelayServerPort
 	}
 
-	res, err := service.AllService.ServerCmdService.SendCmd(port, rc.Cmd, rc.Option)
+	res, err := service.AllService.ServerCmdService.SendCmd(c.Request.Context(), port, rc.Cmd, rc.Option)
 	if err != nil {
 		response.Fail(c, 101, err.Error())
 		return
 	}
-	response.Success(c, res)
+	r.success(c, rc, res)
+}
+
+// sendCmdTo 发送到配置的远程目标, target 为空时使用目标的类型
+func (r *Rustdesk) sendCmdTo(c *gin.Context, rc *RustdeskCmd) {
+	t := service.AllService.ServerCmdService.CmdTarget(rc.Server)
+	if t == nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if rc.Target != "" && rc.Target != service.AllService.ServerCmdService.TargetOf(t) {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError"))
+		return
+	}
+	res, err := service.AllService.ServerCmdService.SendCmdTo(c.Request.Context(), rc.Server, rc.Cmd, rc.Option)
+	if errors.Is(err, service.ErrCmdNotAllowed) {
+		response.Fail(c, 101, response.TranslateMsg(c, err.Error()))
+		return
+	}
+	if err != nil {
+		response.Fail(c, 101, err.Error())
+		return
+	}
+	r.success(c, rc, res)
+}
+
+// success 默认返回原始输出, 兼容旧的前端
+func (r *Rustdesk) success(c *gin.Context, rc *RustdeskCmd, res string) {
+	if !rc.Parse {
+		response.Success(c, res)
+		return
+	}
+	response.Success(c, &model.ServerCmdResult{Raw: res, Parsed: servercmd.Parse(rc.Cmd, rc.Option, res)})
+}
+
+// Hosts 可以发送命令的hbbs/hbbr, name为空的是本机
+func (r *Rustdesk) Hosts(c *gin.Context) {
+	response.Success(c, service.AllService.ServerCmdService.Hosts())
 }
@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/http/request/admin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+type ServerCmdSchedule struct {
+}
+
+// List 列表
+// @Tags 定时指令
+// @Summary 定时指令列表
+// @Description 定时指令列表
+// @Accept  json
+// @Produce  json
+// @Param name query string false "名称"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.ServerCmdScheduleList}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/list [get]
+// @Security token
+func (ct *ServerCmdSchedule) List(c *gin.Context) {
+	query := &admin.ServerCmdScheduleQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.ServerCmdScheduleService.List(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.Name != "" {
+			tx.Where("name like ?", "%"+query.Name+"%")
+		}
+	})
+	response.Success(c, res)
+}
+
+// Detail 详情
+// @Tags 定时指令
+// @Summary 定时指令详情
+// @Description 定时指令详情
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} response.Response{data=model.ServerCmdSchedule}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/detail/{id} [get]
+// @Security token
+func (ct *ServerCmdSchedule) Detail(c *gin.Context) {
+	id := c.Param("id")
+	iid, _ := strconv.Atoi(id)
+	s := service.AllService.ServerCmdScheduleService.InfoById(uint(iid))
+	if s.Id > 0 {
+		response.Success(c, s)
+		return
+	}
+	response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+}
+
+// Create 创建
+// @Tags 定时指令
+// @Summary 创建定时指令
+// @Description 创建定时指令, cron为5段表达式(分 时 日 月 周), option可以使用模板如{{.speed}}, 用params(json)渲染
+// @Accept  json
+// @Produce  json
+// @Param body body admin.ServerCmdScheduleForm true "定时指令信息"
+// @Success 200 {object} response.Response{data=model.ServerCmdSchedule}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/create [post]
+// @Security token
+func (ct *ServerCmdSchedule) Create(c *gin.Context) {
+	s := ct.form(c)
+	if s == nil {
+		return
+	}
+	s.Id = 0
+	if err := service.AllService.ServerCmdScheduleService.Create(s); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, s)
+}
+
+// Update 编辑
+// @Tags 定时指令
+// @Summary 编辑定时指令
+// @Description 编辑定时指令, 会重新计算下次执行时间
+// @Accept  json
+// @Produce  json
+// @Param body body admin.ServerCmdScheduleForm true "定时指令信息"
+// @Success 200 {object} response.Response{data=model.ServerCmdSchedule}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/update [post]
+// @Security token
+func (ct *ServerCmdSchedule) Update(c *gin.Context) {
+	s := ct.form(c)
+	if s == nil {
+		return
+	}
+	if s.Id == 0 || service.AllService.ServerCmdScheduleService.InfoById(s.Id).Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if err := service.AllService.ServerCmdScheduleService.Update(s); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, s)
+}
+
+// Delete 删除
+// @Tags 定时指令
+// @Summary 删除定时指令
+// @Description 删除定时指令和执行历史
+// @Accept  json
+// @Produce  json
+// @Param body body admin.ServerCmdScheduleForm true "定时指令信息"
+// @Success 200 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/delete [post]
+// @Security token
+func (ct *ServerCmdSchedule) Delete(c *gin.Context) {
+	f := &admin.ServerCmdScheduleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	s := service.AllService.ServerCmdScheduleService.InfoById(f.Id)
+	if s.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	if err := service.AllService.ServerCmdScheduleService.Delete(s); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Run 立即执行
+// @Tags 定时指令
+// @Summary 立即执行定时指令
+// @Description 立即执行一次, 不影响下次执行时间, 返回每个目标的结果
+// @Accept  json
+// @Produce  json
+// @Param body body admin.ServerCmdScheduleForm true "定时指令信息"
+// @Success 200 {object} response.Response{data=[]model.ServerCmdRun}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/run [post]
+// @Security token
+func (ct *ServerCmdSchedule) Run(c *gin.Context) {
+	f := &admin.ServerCmdScheduleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	s := service.AllService.ServerCmdScheduleService.InfoById(f.Id)
+	if s.Id == 0 {
+		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
+		return
+	}
+	response.Success(c, service.AllService.ServerCmdScheduleService.Run(c.Request.Context(), s, model.ServerCmdRunTriggerManual))
+}
+
+// Runs 执行历史
+// @Tags 定时指令
+// @Summary 定时指令执行历史
+// @Description 每次执行每个目标一条, 包含输出和状态
+// @Accept  json
+// @Produce  json
+// @Param schedule_id query int false "定时指令ID"
+// @Param status query string false "状态 success/failed"
+// @Param page query int false "页码"
+// @Param page_size query int false "页大小"
+// @Success 200 {object} response.Response{data=model.ServerCmdRunList}
+// @Failure 500 {object} response.Response
+// @Router /admin/server_cmd_schedule/runs [get]
+// @Security token
+func (ct *ServerCmdSchedule) Runs(c *gin.Context) {
+	query := &admin.ServerCmdRunQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return
+	}
+	res := service.AllService.ServerCmdScheduleService.RunList(query.Page, query.PageSize, func(tx *gorm.DB) {
+		if query.ScheduleId > 0 {
+			tx.Where("schedule_id = ?", query.ScheduleId)
+		}
+		if query.Status != "" {
+			tx.Where("status = ?", query.Status)
+		}
+	})
+	response.Success(c, res)
+}
+
+// form 绑定并校验表单, 失败时已经响应
+func (ct *ServerCmdSchedule) form(c *gin.Context) *model.ServerCmdSchedule {
+	f := &admin.ServerCmdScheduleForm{}
+	if err := c.ShouldBindJSON(f); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	errList := global.Validator.ValidStruct(c, f)
+	if len(errList) > 0 {
+		response.Fail(c, 101, errList[0])
+		return nil
+	}
+	s := f.ToServerCmdSchedule()
+	if err := service.AllService.ServerCmdScheduleService.Prepare(s); err != nil {
+		response.Fail(c, 101, response.TranslateMsg(c, "ParamsError")+err.Error())
+		return nil
+	}
+	return s
+}
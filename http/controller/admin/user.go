@@ -15,6 +15,15 @@ import (
 type User struct {
 }
 
+// canManage 非管理员(通过角色获得users权限)不能获得或授予超出自己的权限, 见 RoleService.CanManageUser
+func (ct *User) canManage(c *gin.Context, target, data *model.User) bool {
+	if !service.AllService.RoleService.CanManageUser(service.AllService.UserService.CurUser(c), target, data) {
+		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
+		return false
+	}
+	return true
+}
+
 // Detail 管理员
 // @Tags 用户
 // @Summary 管理员详情
@@ -61,6 +70,9 @@ func (ct *User) Create(c *gin.Context) {
 		return
 	}
 	u := f.ToUser()
+	if !ct.canManage(c, nil, u) {
+		return
+	}
 	err := service.AllService.UserService.Create(u)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
@@ -123,6 +135,9 @@ func (ct *User) Update(c *gin.Context) {
 		return
 	}
 	u := f.ToUser()
+	if !ct.canManage(c, service.AllService.UserService.InfoById(u.Id), u) {
+		return
+	}
 	err := service.AllService.UserService.Update(u)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
@@ -156,6 +171,9 @@ func (ct *User) Delete(c *gin.Context) {
 	}
 	u := service.AllService.UserService.InfoById(f.Id)
 	if u.Id > 0 {
+		if !ct.canManage(c, u, nil) {
+			return
+		}
 		err := service.AllService.UserService.Delete(u)
 		if err == nil {
 			response.Success(c, nil)
@@ -194,6 +212,9 @@ func (ct *User) UpdatePassword(c *gin.Context) {
 		response.Fail(c, 101, response.TranslateMsg(c, "ItemNotFound"))
 		return
 	}
+	if !ct.canManage(c, u, nil) {
+		return
+	}
 	err := service.AllService.UserService.UpdatePassword(u, f.Password)
 	if err != nil {
 		response.Fail(c, 101, response.TranslateMsg(c, "OperationFailed")+err.Error())
@@ -247,7 +268,8 @@ func (ct *User) ChangeCurPwd(c *gin.Context) {
 	// otherwise, the old password is not verified
 	if !service.AllService.UserService.IsPasswordEmptyByUser(u) {
 		oldPwd := service.AllService.UserService.EncryptPassword(f.OldPassword)
-		if u.Password != oldPwd {
+		// 当前用户来自缓存, 不含密码
+		if service.AllService.UserService.InfoById(u.Id).Password != oldPwd {
 			response.Fail(c, 101, response.TranslateMsg(c, "OldPasswordError"))
 			return
 		}
@@ -66,7 +66,7 @@ func (ct *UserToken) Delete(c *gin.Context) {
 	}
 	l := service.AllService.UserService.TokenInfoById(f.Id)
 	u := service.AllService.UserService.CurUser(c)
-	if !service.AllService.UserService.IsAdmin(u) && l.UserId != u.Id {
+	if !service.AllService.RoleService.HasPermission(u, model.ResourceTokens, model.ActionWrite) && l.UserId != u.Id {
 		response.Fail(c, 101, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
@@ -49,8 +49,11 @@ func (a *Ab) Ab(c *gin.Context) {
 		TagColors: string(tgc),
 	}
 	data, _ := json.Marshal(res)
+	rev := service.AllService.AddressBookService.Revision(user.Id, 0)
+	a.setRevision(c, rev)
 	c.JSON(http.StatusOK, gin.H{
-		"data": string(data),
+		"data":     string(data),
+		"revision": rev,
 		//"licensed_devices": 999,
 	})
 }
@@ -87,15 +90,61 @@ func (a *Ab) UpAb(c *gin.Context) {
 	}
 	user := service.AllService.UserService.CurUser(c)
 
-	err = service.AllService.AddressBookService.UpdateAddressBook(abd.Peers, user.Id)
+	// 带了版本号时, 版本不是最新的会和服务端的修改合并
+	baseRev := a.ifMatchRevision(c)
+	if baseRev < 0 && abf.Revision != nil {
+		baseRev = *abf.Revision
+	}
+	rev, err := service.AllService.AddressBookService.UpdateAddressBook(user, user.Id, abd.ToExport(), baseRev)
+	if errors.Is(err, service.ErrAddressBookConflict) {
+		a.conflict(c, user.Id, 0)
+		return
+	}
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
 	}
+	a.setRevision(c, rev)
+	c.JSON(http.StatusOK, nil)
+}
 
-	service.AllService.TagService.UpdateTags(user.Id, tc)
+// ifMatchRevision 客户端修改前的版本号, 来自 If-Match 头, 没有时返回-1
+func (a *Ab) ifMatchRevision(c *gin.Context) int64 {
+	v := strings.TrimSpace(c.GetHeader("If-Match"))
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+	if v == "" || v == "*" {
+		return -1
+	}
+	rev, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return rev
+}
 
-	c.JSON(http.StatusOK, nil)
+// setRevision 版本号作为ETag返回
+func (a *Ab) setRevision(c *gin.Context, rev int64) {
+	c.Header("ETag", `"`+strconv.FormatInt(rev, 10)+`"`)
+}
+
+// conflict 返回409和当前版本号, 客户端需要重新拉取后再提交
+func (a *Ab) conflict(c *gin.Context, uid, cid uint) {
+	rev := service.AllService.AddressBookService.Revision(uid, cid)
+	a.setRevision(c, rev)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":    response.TranslateMsg(c, service.ErrAddressBookConflict.Error()),
+		"revision": rev,
+	})
+}
+
+// checkRevision 带了 If-Match 时, 版本不是最新的返回409
+func (a *Ab) checkRevision(c *gin.Context, uid, cid uint) bool {
+	rev := a.ifMatchRevision(c)
+	if rev >= 0 && rev != service.AllService.AddressBookService.Revision(uid, cid) {
+		a.conflict(c, uid, cid)
+		return false
+	}
+	return true
 }
 
 // PTags
@@ -160,6 +209,9 @@ func (a *Ab) TagAdd(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	tag := service.AllService.TagService.InfoByUserIdAndNameAndCollectionId(uid, t.Name, cid)
 	if tag != nil && tag.Id != 0 {
@@ -168,7 +220,7 @@ func (a *Ab) TagAdd(c *gin.Context) {
 	}
 	t.UserId = uid
 	t.CollectionId = cid
-	err = service.AllService.TagService.Create(t)
+	err = service.AllService.TagService.Create(u, t)
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -208,6 +260,9 @@ func (a *Ab) TagRename(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	tag := service.AllService.TagService.InfoByUserIdAndNameAndCollectionId(uid, t.Old, cid)
 	if tag == nil || tag.Id == 0 {
@@ -220,7 +275,7 @@ func (a *Ab) TagRename(c *gin.Context) {
 		return
 	}
 	tag.Name = t.New
-	err = service.AllService.TagService.Update(tag)
+	err = service.AllService.TagService.Update(u, tag)
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -259,6 +314,9 @@ func (a *Ab) TagUpdate(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	tag := service.AllService.TagService.InfoByUserIdAndNameAndCollectionId(uid, t.Name, cid)
 	if tag == nil || tag.Id == 0 {
@@ -266,7 +324,7 @@ func (a *Ab) TagUpdate(c *gin.Context) {
 		return
 	}
 	tag.Color = t.Color
-	err = service.AllService.TagService.Update(tag)
+	err = service.AllService.TagService.Update(u, tag)
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -307,6 +365,9 @@ func (a *Ab) TagDel(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	for _, name := range *t {
 		tag := service.AllService.TagService.InfoByUserIdAndNameAndCollectionId(uid, name, cid)
@@ -314,7 +375,7 @@ func (a *Ab) TagDel(c *gin.Context) {
 			response.Error(c, response.TranslateMsg(c, "ItemNotFound"))
 			return
 		}
-		err = service.AllService.TagService.Delete(tag)
+		err = service.AllService.TagService.Delete(u, tag)
 		if err != nil {
 			response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 			return
@@ -393,11 +454,15 @@ func (a *Ab) SharedProfiles(c *gin.Context) {
 	user := service.AllService.UserService.CurUser(c)
 	myAbCollectionList := service.AllService.AddressBookService.ListCollectionByUserId(user.Id)
 	for _, ab := range myAbCollectionList.AddressBookCollection {
+		rule := model.ShareAddressBookRuleRuleFullControl
+		if ab.IsSmart() {
+			rule = model.ShareAddressBookRuleRuleRead
+		}
 		res = append(res, &api.SharedProfilesPayload{
 			Guid:  a.ComposeGuid(user.GroupId, user.Id, ab.Id),
 			Name:  ab.Name,
 			Owner: user.Username,
-			Rule:  model.ShareAddressBookRuleRuleFullControl,
+			Rule:  rule,
 		})
 	}
 
@@ -432,11 +497,15 @@ func (a *Ab) SharedProfiles(c *gin.Context) {
 		if !ok {
 			continue
 		}
+		rule := allAbIds[collection.Id]
+		if collection.IsSmart() {
+			rule = model.ShareAddressBookRuleRuleRead
+		}
 		res = append(res, &api.SharedProfilesPayload{
 			Guid:  a.ComposeGuid(_u.GroupId, _u.Id, collection.Id),
 			Name:  collection.Name,
 			Owner: _u.Username,
-			Rule:  allAbIds[collection.Id],
+			Rule:  rule,
 		})
 	}
 
@@ -548,11 +617,20 @@ func (a *Ab) Peers(c *gin.Context) {
 		return
 	}
 
-	al := service.AllService.AddressBookService.ListByUserIdAndCollectionId(uid, cid, 1, 1000)
+	var al *model.AddressBookList
+	if collection := service.AllService.AddressBookService.CollectionInfoById(cid); collection.IsSmart() {
+		//智能地址簿按筛选条件实时生成
+		al = service.AllService.AddressBookService.SmartPeers(collection)
+	} else {
+		al = service.AllService.AddressBookService.ListByUserIdAndCollectionId(uid, cid, 1, 1000)
+	}
+	rev := service.AllService.AddressBookService.Revision(uid, cid)
+	a.setRevision(c, rev)
 	c.JSON(http.StatusOK, gin.H{
 		"total":            al.Total,
 		"data":             al.AddressBooks,
 		"licensed_devices": 99999,
+		"revision":         rev,
 	})
 }
 
@@ -590,6 +668,9 @@ func (a *Ab) PeerAdd(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	//fmt.Println(f)
 	f.UserId = uid
@@ -604,7 +685,7 @@ func (a *Ab) PeerAdd(c *gin.Context) {
 		}
 	}
 
-	err = service.AllService.AddressBookService.AddAddressBook(ab)
+	err = service.AllService.AddressBookService.AddAddressBook(u, ab)
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -643,6 +724,9 @@ func (a *Ab) PeerDel(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 
 	for _, id := range *f {
 		ab := service.AllService.AddressBookService.InfoByUserIdAndIdAndCid(uid, id, cid)
@@ -650,7 +734,7 @@ func (a *Ab) PeerDel(c *gin.Context) {
 			response.Error(c, response.TranslateMsg(c, "ItemNotFound"))
 			return
 		}
-		err = service.AllService.AddressBookService.Delete(ab)
+		err = service.AllService.AddressBookService.Delete(u, ab)
 		if err != nil {
 			response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 			return
@@ -692,6 +776,9 @@ func (a *Ab) PeerUpdate(c *gin.Context) {
 		response.Error(c, response.TranslateMsg(c, "NoAccess"))
 		return
 	}
+	if !a.checkRevision(c, uid, cid) {
+		return
+	}
 	//fmt.Println(f)
 	//判断f["Id"]是否存在
 	fid, ok := f["id"]
@@ -718,7 +805,7 @@ func (a *Ab) PeerUpdate(c *gin.Context) {
 	if tags, _ok := f["tags"]; _ok {
 		f["tags"], _ = json.Marshal(tags)
 	}
-	err = service.AllService.AddressBookService.UpdateByMap(ab, f)
+	err = service.AllService.AddressBookService.UpdateByMap(u, ab, f)
 	if err != nil {
 		response.Error(c, response.TranslateMsg(c, "OperationFailed")+err.Error())
 		return
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/crypt"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func newAbTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	if _, ok := schema.GetSerializer(crypt.SerializerName); !ok {
+		crypt.RegisterSerializer(crypt.NewKeyring("", nil))
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&model.User{}, &model.Peer{}, &model.AddressBook{}, &model.Tag{}, &model.AddressBookCollection{},
+		&model.AddressBookRevision{}, &model.AddressBookSnapshot{}, &model.AddressBookChange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDB, oldLogger, oldConfig, oldAll := service.DB, service.Logger, service.Config, service.AllService
+	service.DB, service.Logger, service.Config, service.AllService = db, log.New(), &config.Config{}, new(service.Service)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		service.DB, service.Logger, service.Config, service.AllService = oldDB, oldLogger, oldConfig, oldAll
+	})
+	return db
+}
+
+// TestUpAbKeepsPassword 旧客户端提交的地址簿不包含密码等字段, 不能清空已保存的值
+func TestUpAbKeepsPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newAbTestDB(t)
+	u := &model.User{Username: "u"}
+	db.Create(u)
+	db.Create(&model.AddressBook{Id: "123", UserId: u.Id, Alias: "a", Password: "secret", LoginName: "admin", SameServer: true, Tags: []byte(`[]`)})
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"tags":       []string{},
+		"tag_colors": "{}",
+		"peers": []map[string]interface{}{
+			{"id": "123", "username": "user", "hostname": "host", "platform": "Windows", "alias": "b", "tags": []string{}, "hash": "h"},
+		},
+	})
+	body, _ := json.Marshal(map[string]string{"data": string(data)})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/ab", strings.NewReader(string(body)))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("curUser", u)
+	(&Ab{}).UpAb(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	got := &model.AddressBook{}
+	db.Where("user_id = ? and id = ?", u.Id, "123").First(got)
+	if got.Alias != "b" || got.Hash != "h" {
+		t.Fatalf("submitted fields not saved: %+v", got)
+	}
+	if got.Password != "secret" || got.LoginName != "admin" || !got.SameServer {
+		t.Fatalf("stored fields cleared: password %q, loginName %q, sameServer %v", got.Password, got.LoginName, got.SameServer)
+	}
+}
@@ -4,19 +4,14 @@ import (
 	"github.com/gin-gonic/gin"
 	requstform "github.com/lejianwen/rustdesk-api/v2/http/request/api"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
-	"github.com/lejianwen/rustdesk-api/v2/model"
 	"github.com/lejianwen/rustdesk-api/v2/service"
 	"net/http"
-	"time"
 	"sync"
 )
 
 type Index struct {
 }
 
-// 缓存变量
-var peerCache sync.Map
-
 // 定时将缓存中的数据更新到数据库的间隔
 //var updateInterval = 1 * time.Hour
 
@@ -36,15 +31,10 @@ func (i *Index) Index(c *gin.Context) {
 	)
 }
 
-// UpdateCacheToDB 遍历缓存并将数据更新到数据库
+// UpdateCacheToDB 将缓存的心跳更新到数据库, 见 service.PeerHeartbeats
 func UpdateCacheToDB(wg *sync.WaitGroup) {
 	defer wg.Done() // 当函数结束时，通知 WaitGroup 完成任务
-	peerCache.Range(func(key, value interface{}) bool {
-		peer := value.(*model.Peer) // 从缓存中取出 model.Peer
-		service.AllService.PeerService.Update(peer) // 更新到数据库
-		peerCache.Delete(key) // 更新后从缓存中删除
-		return true
-	})
+	service.AllService.PeerService.FlushHeartbeats()
 }
 
 //func init() {
@@ -80,29 +70,8 @@ func (i *Index) Heartbeat(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{})
 		return
 	}
-	// 先从缓存中查找对应的 `peer` 数据
-	peerInterface, ok := peerCache.Load(info.Uuid)
-	var peer *model.Peer
-
-	// 如果缓存中不存在数据，从数据库中查找
-	if !ok {
-		peer = service.AllService.PeerService.FindByUuid(info.Uuid)
-		if peer == nil || peer.RowId == 0 {
-			// 如果数据库中也找不到，返回空响应
-			c.JSON(http.StatusOK, gin.H{})
-			return
-		}
-	} else {
-		// 如果缓存中存在数据，转换类型
-		peer = peerInterface.(*model.Peer)
-	}
-
-	// 更新 `LastOnlineTime` 和 `LastOnlineIp` 字段
-	peer.LastOnlineTime = time.Now().Unix()
-	peer.LastOnlineIp = c.ClientIP()
-
-	// 将更新后的数据重新存入缓存
-	peerCache.Store(info.Uuid, peer)
+	// 缓存中没有时会从数据库中确认设备存在, 更新 `LastOnlineTime` 和 `LastOnlineIp` 字段到缓存
+	service.AllService.PeerService.Heartbeat(info.Uuid, c.ClientIP())
 	c.JSON(http.StatusOK, gin.H{})
 }
 
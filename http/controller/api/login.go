@@ -134,9 +134,16 @@ func (l *Login) LoginOptions(c *gin.Context) {
 func (l *Login) Logout(c *gin.Context) {
 	u := service.AllService.UserService.CurUser(c)
 	token, ok := c.Get("token")
+	logoutUrl := ""
 	if ok {
+		logoutUrl = service.AllService.UserService.OidcLogoutUrl(token.(string))
 		service.AllService.UserService.Logout(u, token.(string))
 	}
+	if logoutUrl != "" {
+		// oidc登录的, 返回IdP的登出地址
+		c.JSON(http.StatusOK, gin.H{"logout_url": logoutUrl})
+		return
+	}
 	c.JSON(http.StatusOK, nil)
 
 }
@@ -109,6 +109,13 @@ func (o *Oauth) OidcAuthQueryPre(c *gin.Context) (*model.User, *model.UserToken)
 		return nil, nil
 	}
 
+	// 记录IdP会话, 用于 RP-initiated logout 和 back-channel logout
+	if v.IdToken != "" || v.Sid != "" {
+		if err := service.AllService.UserService.BindOidcSession(ut, v.Op, v.OpenId, v.Sid, v.IdToken); err != nil {
+			global.Logger.Warn("BindOidcSession error: ", err)
+		}
+	}
+
 	// 返回用户令牌
 	return u, ut
 }
@@ -238,7 +245,12 @@ func (o *Oauth) OauthCallback(c *gin.Context) {
 				})
 				return
 			}
+		} else {
+			// 每次登录都根据IdP的claim更新管理员和分组
+			service.AllService.UserService.SyncOauthClaims(user, oauthUser, op)
 		}
+		// 记录IdP会话, 用于登出
+		oauthCache.UpdateFromOauthUser(oauthUser)
 		oauthCache.UserId = user.Id
 		oauthService.SetOauthCache(cacheKey, oauthCache, 0)
 		// 如果是webadmin，登录成功后跳转到webadmin
@@ -268,6 +280,40 @@ func (o *Oauth) OauthCallback(c *gin.Context) {
 
 }
 
+// BackchannelLogout IdP发起的登出
+// @Tags Oauth
+// @Summary BackchannelLogout
+// @Description OIDC back-channel logout, 吊销IdP会话对应的token
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param op path string true "op"
+// @Param logout_token formData string true "logout_token"
+// @Success 200
+// @Failure 400
+// @Router /oidc/backchannel-logout/{op} [post]
+func (o *Oauth) BackchannelLogout(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	op := c.Param("op")
+	logoutToken := c.PostForm("logout_token")
+	if op == "" || logoutToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	err, sub, sid := service.AllService.OauthService.VerifyLogoutToken(op, logoutToken)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	n, err := service.AllService.UserService.LogoutByOidcSession(op, sub, sid)
+	if err != nil {
+		global.Logger.Error("BackchannelLogout error: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	global.Logger.Info("BackchannelLogout op: ", op, " sub: ", sub, " sid: ", sid, " revoked: ", n)
+	c.Status(http.StatusOK)
+}
+
 type MessageParams struct {
 	Lang  string `json:"lang" form:"lang"`
 	Title string `json:"title" form:"title"`
@@ -3,6 +3,7 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/lejianwen/rustdesk-api/v2/global"
 	requstform "github.com/lejianwen/rustdesk-api/v2/http/request/api"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
 	"github.com/lejianwen/rustdesk-api/v2/service"
@@ -51,6 +52,9 @@ func (p *Peer) SysInfo(c *gin.Context) {
 			return
 		}
 	}
+	if err := service.AllService.AddressBookService.SyncMyDevices(pe.UserId); err != nil {
+		global.Logger.Warn("sync my devices failed: ", err)
+	}
 	//SYSINFO_UPDATED 上传成功
 	//ID_NOT_FOUND 下次心跳会上传
 	//直接响应文本
@@ -5,8 +5,8 @@ import (
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
 	"github.com/lejianwen/rustdesk-api/v2/http/response/api"
+	"github.com/lejianwen/rustdesk-api/v2/model"
 	"github.com/lejianwen/rustdesk-api/v2/service"
-	"time"
 )
 
 type WebClient struct {
@@ -64,13 +64,12 @@ func (i *WebClient) SharedPeer(c *gin.Context) {
 		response.Fail(c, 101, "share not found")
 		return
 	}
-	if sr.Expire != 0 {
-		//判断是否过期,created_at + expire > now
-		ca := time.Time(sr.CreatedAt)
-		if ca.Add(time.Second * time.Duration(sr.Expire)).Before(time.Now()) {
-			response.Fail(c, 101, "share expired")
-			return
-		}
+	u := i.optionalUser(c)
+	result := service.AllService.ShareRecordService.Access(sr, c.ClientIP(), u)
+	service.AllService.ShareRecordService.AddAccessLog(sr, u, c.ClientIP(), c.Request.UserAgent(), result)
+	if result != model.ShareAccessOk {
+		response.Fail(c, 101, "share "+result)
+		return
 	}
 
 	ab := service.AllService.AddressBookService.InfoByUserIdAndId(sr.UserId, sr.PeerId)
@@ -89,6 +88,26 @@ func (i *WebClient) SharedPeer(c *gin.Context) {
 	})
 }
 
+// optionalUser 分享链接可以不登录访问, 带了有效的token时取出用户
+func (i *WebClient) optionalUser(c *gin.Context) *model.User {
+	token := c.GetHeader("Authorization")
+	if len(token) <= 7 {
+		return nil
+	}
+	token = token[7:]
+	if len(global.Jwt.Key) > 0 {
+		uid, _ := service.AllService.UserService.VerifyJWT(token)
+		if uid == 0 {
+			return nil
+		}
+	}
+	u, _ := service.AllService.UserService.InfoByAccessToken(token)
+	if u.Id == 0 || !service.AllService.UserService.CheckUserEnable(u) {
+		return nil
+	}
+	return u
+}
+
 // ServerConfigV2 服务配置
 // @Tags WEBCLIENT_V2
 // @Summary 服务配置
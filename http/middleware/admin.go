@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
 	"github.com/lejianwen/rustdesk-api/v2/service"
+	"net/http"
 )
 
 // BackendUserAuth 后台权限验证中间件
@@ -17,6 +18,23 @@ func BackendUserAuth() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 个人访问令牌
+		if service.AllService.AccessTokenService.IsAccessToken(token) {
+			user, code := accessTokenAuth(c, token)
+			if code == http.StatusForbidden {
+				response.Fail(c, 403, "无权限")
+				c.Abort()
+				return
+			}
+			if code != 0 {
+				response.Fail(c, 403, "请先登录")
+				c.Abort()
+				return
+			}
+			c.Set("curUser", user)
+			c.Next()
+			return
+		}
 		user, ut := service.AllService.UserService.InfoByAccessToken(token)
 		if user.Id == 0 {
 			response.Fail(c, 403, "请先登录")
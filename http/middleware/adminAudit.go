@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/global"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 记录的请求体和响应的最大长度
+const (
+	auditMaxBody     = 64 << 10
+	auditMaxResponse = 64
+)
+
+var auditCodeRe = regexp.MustCompile(`^\{"code":(-?\d+)`)
+
+// auditWriter 保留响应的开头, 用于取出 response.Response 的code
+type auditWriter struct {
+	gin.ResponseWriter
+	head bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if n := auditMaxResponse - w.head.Len(); n > 0 {
+		w.head.Write(b[:min(n, len(b))])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// AdminAudit 记录后台修改类的请求, 放在 BackendUserAuth 之后
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		as := service.AllService.AdminAuditService
+		var body []byte
+		if c.ContentType() == "application/json" && c.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, auditMaxBody))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		}
+		bodyMap := map[string]interface{}{}
+		_ = json.Unmarshal(body, &bodyMap)
+
+		route := c.FullPath()
+		target := as.Target(route)
+		id := auditTargetId(c, bodyMap)
+		before := as.Snapshot(target, id)
+
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		code := auditCode(w)
+		after := as.Snapshot(target, id)
+		if before == nil && after == nil && code == 0 && strings.HasSuffix(route, "/create") {
+			// 创建时没有id, 记录提交的内容
+			after = bodyMap
+		}
+		l := &model.AdminAuditLog{
+			Ip:       c.ClientIP(),
+			Method:   c.Request.Method,
+			Route:    route,
+			Path:     c.Request.URL.Path,
+			Target:   target,
+			TargetId: id,
+			Request:  as.RedactBody(body),
+			Changes:  as.Diff(before, after),
+			Code:     code,
+		}
+		if u := service.AllService.UserService.CurUser(c); u != nil {
+			l.UserId = u.Id
+			l.Username = u.Username
+		}
+		if err := as.Create(l); err != nil {
+			global.Logger.Error("save admin audit log failed: ", err)
+		}
+	}
+}
+
+// auditTargetId 对象的id, 取路径参数或请求体中的 id/row_id
+func auditTargetId(c *gin.Context, body map[string]interface{}) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	for _, k := range []string{"id", "row_id"} {
+		if v, ok := body[k].(float64); ok && v > 0 {
+			return strconv.FormatUint(uint64(v), 10)
+		}
+	}
+	return ""
+}
+
+// auditCode 响应中的code, 不是 response.Response 时使用http状态码, 2xx为0
+func auditCode(w *auditWriter) int {
+	if m := auditCodeRe.FindSubmatch(w.head.Bytes()); m != nil {
+		code, _ := strconv.Atoi(string(m[1]))
+		return code
+	}
+	if w.Status() >= http.StatusBadRequest {
+		return w.Status()
+	}
+	return 0
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/service"
 	"net/http"
 )
 
@@ -11,6 +12,12 @@ func Limiter() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		loginLimiter := global.LoginLimiter
 		clientIp := c.ClientIP()
+		// 后台配置的封禁规则
+		if service.AllService.IpRuleService.Blocked(clientIp) {
+			response.Fail(c, http.StatusForbidden, response.TranslateMsg(c, "Banned"))
+			c.Abort()
+			return
+		}
 		banned, _ := loginLimiter.CheckSecurityStatus(clientIp)
 		if banned {
 			response.Fail(c, http.StatusLocked, response.TranslateMsg(c, "Banned"))
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/http/response"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"net/http"
+)
+
+// Permission 按角色权限验证, GET 请求需要 read 权限, 其他需要 write 权限, 管理员拥有全部权限
+func Permission(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := service.AllService.UserService.CurUser(c)
+		action := model.ActionWrite
+		if c.Request.Method == http.MethodGet {
+			action = model.ActionRead
+		}
+		if !service.AllService.RoleService.HasPermission(u, resource, action) {
+			response.Fail(c, 403, "无权限")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lejianwen/rustdesk-api/v2/global"
 	"github.com/lejianwen/rustdesk-api/v2/service"
+	"net/http"
 )
 
 func RustAuth() gin.HandlerFunc {
@@ -31,6 +32,21 @@ func RustAuth() gin.HandlerFunc {
 
 		//验证token
 
+		// 个人访问令牌, 不是jwt
+		if service.AllService.AccessTokenService.IsAccessToken(token) {
+			user, code := accessTokenAuth(c, token)
+			if code != 0 {
+				c.JSON(code, gin.H{
+					"error": http.StatusText(code),
+				})
+				c.Abort()
+				return
+			}
+			c.Set("curUser", user)
+			c.Next()
+			return
+		}
+
 		//检查是否设置了jwt key
 		if len(global.Jwt.Key) > 0 {
 			uid, _ := service.AllService.UserService.VerifyJWT(token)
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/service"
+	"net/http"
+	"strings"
+)
+
+// routeScopes 个人访问令牌可以访问的路由及需要的scope, 空字符串表示任意scope都可以
+// 不在表中的路由只有 admin:* 可以访问
+var routeScopes = map[string]string{
+	// admin
+	"GET /api/admin/user/current":                       "",
+	"GET /api/admin/peer/list":                          model.ScopePeersRead,
+	"GET /api/admin/peer/detail/:id":                    model.ScopePeersRead,
+	"POST /api/admin/peer/create":                       model.ScopePeersWrite,
+	"POST /api/admin/peer/update":                       model.ScopePeersWrite,
+	"POST /api/admin/peer/delete":                       model.ScopePeersWrite,
+	"POST /api/admin/peer/batchDelete":                  model.ScopePeersWrite,
+	"GET /api/admin/my/peer/list":                       model.ScopePeersRead,
+	"GET /api/admin/address_book/list":                  model.ScopeAbRead,
+	"POST /api/admin/address_book/create":               model.ScopeAbWrite,
+	"POST /api/admin/address_book/update":               model.ScopeAbWrite,
+	"POST /api/admin/address_book/delete":               model.ScopeAbWrite,
+	"POST /api/admin/address_book/batchCreate":          model.ScopeAbWrite,
+	"POST /api/admin/address_book/batchCreateFromPeers": model.ScopeAbWrite,
+	"GET /api/admin/my/address_book/list":               model.ScopeAbRead,
+	"POST /api/admin/my/address_book/create":            model.ScopeAbWrite,
+	"POST /api/admin/my/address_book/update":            model.ScopeAbWrite,
+	"POST /api/admin/my/address_book/delete":            model.ScopeAbWrite,
+	"GET /api/admin/audit_conn/list":                    model.ScopeAuditRead,
+	"GET /api/admin/audit_file/list":                    model.ScopeAuditRead,
+	"GET /api/admin/login_log/list":                     model.ScopeAuditRead,
+	// api
+	"GET /api/user/info":               "",
+	"POST /api/currentUser":            "",
+	"GET /api/users":                   model.ScopePeersRead,
+	"GET /api/peers":                   model.ScopePeersRead,
+	"GET /api/device-group/accessible": model.ScopePeersRead,
+	"GET /api/ab":                      model.ScopeAbRead,
+	"POST /api/ab":                     model.ScopeAbWrite,
+	"POST /api/ab/personal":            model.ScopeAbRead,
+	"POST /api/ab/settings":            model.ScopeAbRead,
+	"POST /api/ab/shared/profiles":     model.ScopeAbRead,
+	"POST /api/ab/peers":               model.ScopeAbRead,
+	"POST /api/ab/tags/:guid":          model.ScopeAbRead,
+	"POST /api/ab/peer/add/:guid":      model.ScopeAbWrite,
+	"DELETE /api/ab/peer/:guid":        model.ScopeAbWrite,
+	"PUT /api/ab/peer/update/:guid":    model.ScopeAbWrite,
+	"POST /api/ab/tag/add/:guid":       model.ScopeAbWrite,
+	"PUT /api/ab/tag/rename/:guid":     model.ScopeAbWrite,
+	"PUT /api/ab/tag/update/:guid":     model.ScopeAbWrite,
+	"DELETE /api/ab/tag/:guid":         model.ScopeAbWrite,
+}
+
+// accessTokenRoutePrefixes 令牌管理的路由, 使用令牌认证时无论scope都不能访问
+// 避免泄露的令牌创建新的令牌, 在自己被撤销或过期后继续使用
+var accessTokenRoutePrefixes = []string{
+	"/api/admin/access_token/",
+	"/api/admin/my/access_token/",
+}
+
+// isAccessTokenRoute 是否为令牌管理的路由
+func isAccessTokenRoute(path string) bool {
+	for _, p := range accessTokenRoutePrefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessTokenAuth 个人访问令牌认证, code为0表示通过, 401未认证, 403 scope不足
+func accessTokenAuth(c *gin.Context, token string) (*model.User, int) {
+	user, at := service.AllService.AccessTokenService.InfoByToken(token)
+	if user.Id == 0 || !service.AllService.UserService.CheckUserEnable(user) {
+		return nil, http.StatusUnauthorized
+	}
+	if isAccessTokenRoute(c.FullPath()) {
+		return nil, http.StatusForbidden
+	}
+	if !at.HasScope(model.ScopeAdminAll) {
+		scope, ok := routeScopes[c.Request.Method+" "+c.FullPath()]
+		if !ok || (scope != "" && !at.HasScope(scope)) {
+			return nil, http.StatusForbidden
+		}
+	}
+	service.AllService.AccessTokenService.Touch(at, c.ClientIP())
+	c.Set("accessToken", at)
+	return user, 0
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import "testing"
+
+func TestIsAccessTokenRoute(t *testing.T) {
+	cases := map[string]bool{
+		"/api/admin/my/access_token/create": true,
+		"/api/admin/my/access_token/list":   true,
+		"/api/admin/my/access_token/delete": true,
+		"/api/admin/access_token/list":      true,
+		"/api/admin/access_token/delete":    true,
+		"/api/admin/peer/list":              false,
+		"/api/admin/user/current":           false,
+	}
+	for path, want := range cases {
+		if got := isAccessTokenRoute(path); got != want {
+			t.Errorf("isAccessTokenRoute(%q) = %v, want %v", path, got, want)
+		}
+	}
+	// 令牌管理的路由不能在 scope 表中放行
+	for route := range routeScopes {
+		for i := 0; i < len(route); i++ {
+			if route[i] == ' ' && isAccessTokenRoute(route[i+1:]) {
+				t.Errorf("token management route %q is allowed for access tokens", route)
+			}
+		}
+	}
+}
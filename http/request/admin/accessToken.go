@@ -0,0 +1,12 @@
+package admin
+
+type AccessTokenQuery struct {
+	UserId uint `form:"user_id"`
+	PageQuery
+}
+
+type AccessTokenForm struct {
+	Name      string   `json:"name" validate:"required,lte=64"`
+	Scopes    []string `json:"scopes" validate:"required,gt=0"`
+	ExpiredAt int64    `json:"expired_at"` // 0 为永不过期
+}
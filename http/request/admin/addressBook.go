@@ -90,11 +90,43 @@ type AddressBookQuery struct {
 	PageQuery
 }
 
+type AddressBookExportQuery struct {
+	UserId       uint   `form:"user_id"`
+	CollectionId uint   `form:"collection_id"`
+	Format       string `form:"format" validate:"omitempty,oneof=json csv"`
+}
+
+// AddressBookImportForm data 为导出的文件内容
+type AddressBookImportForm struct {
+	UserId       uint   `json:"user_id"`
+	CollectionId uint   `json:"collection_id"`
+	Format       string `json:"format" validate:"omitempty,oneof=json csv"`
+	Mode         string `json:"mode" validate:"omitempty,oneof=skip overwrite merge"`
+	Data         string `json:"data" validate:"required"`
+}
+
+type AddressBookChangeQuery struct {
+	UserId       uint   `form:"user_id"`
+	CollectionId *int   `form:"collection_id"`
+	Target       string `form:"target"`
+	TargetId     string `form:"target_id"`
+	PageQuery
+}
+
+// AddressBookRestoreForm scope 为 entry 时恢复单个设备/标签, 为 collection 时恢复整个地址簿, 都恢复到这次修改之前
+type AddressBookRestoreForm struct {
+	ChangeId uint   `json:"change_id" validate:"required,gt=0"`
+	Scope    string `json:"scope" validate:"omitempty,oneof=entry collection"`
+}
+
 type ShareByWebClientForm struct {
 	Id           string `json:"id" validate:"required"`
 	PasswordType string `json:"password_type" validate:"required,oneof=once fixed"` //只能是once,fixed
 	Password     string `json:"password" validate:"required"`
 	Expire       int64  `json:"expire"`
+	MaxUses      int64  `json:"max_uses" validate:"gte=0"` // 0 不限制
+	AllowedCidrs string `json:"allowed_cidrs"`             // 逗号分隔
+	RequireLogin bool   `json:"require_login"`
 }
 
 func (sbwcf ShareByWebClientForm) ToShareRecord() *model.ShareRecord {
@@ -104,6 +136,9 @@ func (sbwcf ShareByWebClientForm) ToShareRecord() *model.ShareRecord {
 		PasswordType: sbwcf.PasswordType,
 		Password:     sbwcf.Password,
 		Expire:       sbwcf.Expire,
+		MaxUses:      sbwcf.MaxUses,
+		AllowedCidrs: sbwcf.AllowedCidrs,
+		RequireLogin: &sbwcf.RequireLogin,
 	}
 }
 
@@ -133,3 +168,58 @@ type BatchUpdateTagsForm struct {
 	RowIds []uint   `json:"row_ids"`
 	Tags   []string `json:"tags"`
 }
+
+// AddressBookBulkForm selector 选择要操作的条目, 其他为操作的参数
+type AddressBookBulkForm struct {
+	UserId             uint                          `json:"user_id"` // 管理员使用, 0为不限制用户
+	Selector           model.AddressBookBulkSelector `json:"selector"`
+	Action             string                        `json:"action" validate:"required,oneof=move copy add_tags remove_tags set_alias set_force_always_relay set_rdp_port set_rdp_username delete"`
+	TargetCollectionId uint                          `json:"target_collection_id"`
+	Tags               []string                      `json:"tags"`
+	Alias              string                        `json:"alias"`
+	ForceAlwaysRelay   bool                          `json:"force_always_relay"`
+	RdpPort            string                        `json:"rdp_port"`
+	RdpUsername        string                        `json:"rdp_username"`
+}
+
+func (f AddressBookBulkForm) ToAction() *model.AddressBookBulkAction {
+	return &model.AddressBookBulkAction{
+		Action:           f.Action,
+		CollectionId:     f.TargetCollectionId,
+		Tags:             f.Tags,
+		Alias:            f.Alias,
+		ForceAlwaysRelay: f.ForceAlwaysRelay,
+		RdpPort:          f.RdpPort,
+		RdpUsername:      f.RdpUsername,
+	}
+}
+
+type AddressBookInvitationForm struct {
+	CollectionId uint   `json:"collection_id" validate:"required,gt=0"`
+	Email        string `json:"email" validate:"required,email"`
+	Rule         int    `json:"rule" validate:"required,gte=1,lte=3"`
+	ExpiredAt    int64  `json:"expired_at"` // 为0时默认7天后过期
+}
+
+type AddressBookInvitationQuery struct {
+	CollectionId uint   `form:"collection_id"`
+	Status       string `form:"status"` // pending/accepted/expired
+	Email        string `form:"email"`
+	UserId       uint   `form:"user_id"`
+	PageQuery
+}
+
+type AddressBookInvitationIdForm struct {
+	Id uint `json:"id" validate:"required,gt=0"`
+}
+
+type InvitationTokenForm struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type InvitationRegisterForm struct {
+	Token           string `json:"token" validate:"required"`
+	Username        string `json:"username" validate:"required,gte=2,lte=32"`
+	Password        string `json:"password" validate:"required,gte=4,lte=32"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,gte=4,lte=32"`
+}
@@ -0,0 +1,15 @@
+package admin
+
+// AdminAuditLogQuery start/end 为unix秒
+type AdminAuditLogQuery struct {
+	UserId   uint   `form:"user_id"`
+	Username string `form:"username"`
+	Target   string `form:"target"`
+	TargetId string `form:"target_id"`
+	Route    string `form:"route"`
+	Ip       string `form:"ip"`
+	Failed   bool   `form:"failed"` // 只看失败的请求
+	Start    int64  `form:"start"`
+	End      int64  `form:"end"`
+	PageQuery
+}
@@ -3,15 +3,17 @@ package admin
 import "github.com/lejianwen/rustdesk-api/v2/model"
 
 type GroupForm struct {
-	Id   uint   `json:"id"`
-	Name string `json:"name" validate:"required"`
-	Type int    `json:"type"`
+	Id        uint   `json:"id"`
+	Name      string `json:"name" validate:"required"`
+	Type      int    `json:"type"`
+	MyDevices *bool  `json:"my_devices"`
 }
 
 func (gf *GroupForm) FromGroup(group *model.Group) *GroupForm {
 	gf.Id = group.Id
 	gf.Name = group.Name
 	gf.Type = group.Type
+	gf.MyDevices = group.MyDevices
 	return gf
 }
 
@@ -20,6 +22,7 @@ func (gf *GroupForm) ToGroup() *model.Group {
 	group.Id = gf.Id
 	group.Name = gf.Name
 	group.Type = gf.Type
+	group.MyDevices = gf.MyDevices
 	return group
 }
 
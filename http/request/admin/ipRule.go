@@ -0,0 +1,28 @@
+package admin
+
+import "github.com/lejianwen/rustdesk-api/v2/model"
+
+type IpRuleForm struct {
+	Id        uint   `json:"id"`
+	Cidr      string `json:"cidr" validate:"required,max=64"`
+	Scope     string `json:"scope" validate:"omitempty,oneof=all api relay"`
+	Reason    string `json:"reason"`
+	ExpiresAt int64  `json:"expires_at" validate:"gte=0"`
+}
+
+func (f *IpRuleForm) ToIpRule() *model.IpRule {
+	r := &model.IpRule{
+		Cidr:      f.Cidr,
+		Scope:     f.Scope,
+		Reason:    f.Reason,
+		ExpiresAt: f.ExpiresAt,
+	}
+	r.Id = f.Id
+	return r
+}
+
+type IpRuleQuery struct {
+	Cidr  string `form:"cidr"`
+	Scope string `form:"scope"`
+	PageQuery
+}
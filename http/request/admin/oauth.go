@@ -26,6 +26,20 @@ type OauthForm struct {
 	AutoRegister *bool  `json:"auto_register"`
 	PkceEnable   *bool  `json:"pkce_enable"`
 	PkceMethod   string `json:"pkce_method"`
+	RoleClaim    string `json:"role_claim"`
+	AdminRoles   string `json:"admin_roles"`
+	GroupMapping string `json:"group_mapping"`
+	// PostLogoutRedirectUrl optional, used by RP-initiated logout
+	PostLogoutRedirectUrl string `json:"post_logout_redirect_url" validate:"omitempty,url"`
+	// 以下仅用于 oauth2 类型
+	AuthUrl      string `json:"auth_url" validate:"omitempty,url"`
+	TokenUrl     string `json:"token_url" validate:"omitempty,url"`
+	UserInfoUrl  string `json:"user_info_url" validate:"omitempty,url"`
+	IdPath       string `json:"id_path"`
+	UsernamePath string `json:"username_path"`
+	EmailPath    string `json:"email_path"`
+	NamePath     string `json:"name_path"`
+	AvatarPath   string `json:"avatar_path"`
 }
 
 func (of *OauthForm) ToOauth() *model.Oauth {
@@ -40,7 +54,19 @@ func (of *OauthForm) ToOauth() *model.Oauth {
 		Scopes:       of.Scopes,
 		PkceEnable:   of.PkceEnable,
 		PkceMethod:   of.PkceMethod,
+		RoleClaim:    of.RoleClaim,
+		AdminRoles:   of.AdminRoles,
+		GroupMapping: of.GroupMapping,
 	}
 	oa.Id = of.Id
+	oa.PostLogoutRedirectUrl = of.PostLogoutRedirectUrl
+	oa.AuthUrl = of.AuthUrl
+	oa.TokenUrl = of.TokenUrl
+	oa.UserInfoUrl = of.UserInfoUrl
+	oa.IdPath = of.IdPath
+	oa.UsernamePath = of.UsernamePath
+	oa.EmailPath = of.EmailPath
+	oa.NamePath = of.NamePath
+	oa.AvatarPath = of.AvatarPath
 	return oa
 }
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"time"
+)
+
+type RelayServerForm struct {
+	Id         uint             `json:"id"`
+	Name       string           `json:"name" validate:"required,max=64"`
+	Region     string           `json:"region"`
+	AdminAddr  string           `json:"admin_addr" validate:"required_without=CmdTarget,omitempty,hostname_port"`
+	CmdTarget  string           `json:"cmd_target"`
+	PublicAddr string           `json:"public_addr" validate:"required"`
+	Status     model.StatusCode `json:"status" validate:"required,oneof=1 2"`
+	Remark     string           `json:"remark"`
+}
+
+func (f *RelayServerForm) ToRelayServer() *model.RelayServer {
+	r := &model.RelayServer{
+		Name:       f.Name,
+		Region:     f.Region,
+		AdminAddr:  f.AdminAddr,
+		CmdTarget:  f.CmdTarget,
+		PublicAddr: f.PublicAddr,
+		Status:     f.Status,
+		Remark:     f.Remark,
+	}
+	r.Id = f.Id
+	return r
+}
+
+type RelayServerQuery struct {
+	Name   string `form:"name"`
+	Region string `form:"region"`
+	PageQuery
+}
+
+// RelayCmdForm ids 和 region 都为空时发送到所有启用的中继
+type RelayCmdForm struct {
+	Ids    []uint `json:"ids"`
+	Region string `json:"region"`
+	Cmd    string `json:"cmd" validate:"required"`
+	Option string `json:"option"`
+}
+
+// RelayUsageRange unix秒, end 为空时为当前时间, start 为空时为 end 前24小时
+type RelayUsageRange struct {
+	Start int64 `form:"start"`
+	End   int64 `form:"end"`
+}
+
+// Range 补全默认的时间范围
+func (q *RelayUsageRange) Range() (int64, int64) {
+	end := q.End
+	if end <= 0 {
+		end = time.Now().Unix()
+	}
+	start := q.Start
+	if start <= 0 {
+		start = end - 86400
+	}
+	return start, end
+}
+
+// RelayUsageQuery relay_ids 为空时查询所有中继, step 为降采样的秒数, 为空时自动计算
+type RelayUsageQuery struct {
+	RelayIds []uint `form:"relay_ids"`
+	Step     int64  `form:"step"`
+	RelayUsageRange
+}
+
+type RelayUsageConnQuery struct {
+	RelayId uint   `form:"relay_id"`
+	ConnKey string `form:"conn_key"`
+	RelayUsageRange
+	PageQuery
+}
@@ -0,0 +1,22 @@
+package admin
+
+import "github.com/lejianwen/rustdesk-api/v2/model"
+
+type RoleForm struct {
+	Id          uint     `json:"id"`
+	Name        string   `json:"name" validate:"required"`
+	Remark      string   `json:"remark"`
+	Permissions []string `json:"permissions"`
+	UserIds     []uint   `json:"user_ids"`
+	GroupIds    []uint   `json:"group_ids"`
+}
+
+func (rf *RoleForm) ToRole() *model.Role {
+	role := &model.Role{}
+	role.Id = rf.Id
+	role.Name = rf.Name
+	role.Remark = rf.Remark
+	role.UserIds = rf.UserIds
+	role.GroupIds = rf.GroupIds
+	return role
+}
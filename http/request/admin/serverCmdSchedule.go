@@ -0,0 +1,45 @@
+package admin
+
+import "github.com/lejianwen/rustdesk-api/v2/model"
+
+type ServerCmdScheduleForm struct {
+	Id       uint             `json:"id"`
+	Name     string           `json:"name" validate:"required"`
+	Cron     string           `json:"cron" validate:"required"`
+	Timezone string           `json:"timezone"`
+	CmdId    uint             `json:"cmd_id"`
+	Cmd      string           `json:"cmd" validate:"required_without=CmdId"`
+	Option   string           `json:"option"`
+	Params   string           `json:"params"`
+	Target   string           `json:"target" validate:"required"`
+	Server   string           `json:"server"`
+	Status   model.StatusCode `json:"status" validate:"required,oneof=1 2"`
+}
+
+func (f *ServerCmdScheduleForm) ToServerCmdSchedule() *model.ServerCmdSchedule {
+	s := &model.ServerCmdSchedule{
+		Name:     f.Name,
+		Cron:     f.Cron,
+		Timezone: f.Timezone,
+		CmdId:    f.CmdId,
+		Cmd:      f.Cmd,
+		Option:   f.Option,
+		Params:   f.Params,
+		Target:   f.Target,
+		Server:   f.Server,
+		Status:   f.Status,
+	}
+	s.Id = f.Id
+	return s
+}
+
+type ServerCmdScheduleQuery struct {
+	Name string `form:"name"`
+	PageQuery
+}
+
+type ServerCmdRunQuery struct {
+	ScheduleId uint   `form:"schedule_id"`
+	Status     string `form:"status"`
+	PageQuery
+}
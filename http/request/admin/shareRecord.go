@@ -13,3 +13,8 @@ type ShareRecordForm struct {
 type PeerShareRecordBatchDeleteForm struct {
 	Ids []uint `json:"ids" validate:"required"`
 }
+
+type ShareAccessLogQuery struct {
+	ShareRecordId uint `json:"share_record_id" form:"share_record_id" validate:"required,gt=0"`
+	PageQuery
+}
@@ -8,8 +8,17 @@ type AddressBookFormData struct {
 	TagColors string               `json:"tag_colors"`
 }
 
+func (abfd *AddressBookFormData) ToExport() *model.AddressBookExport {
+	return &model.AddressBookExport{
+		Tags:      abfd.Tags,
+		Peers:     abfd.Peers,
+		TagColors: abfd.TagColors,
+	}
+}
+
 type AddressBookForm struct {
-	Data string `json:"data" example:"{\"tags\":[\"tag1\",\"tag2\",\"tag3\"],\"peers\":[{\"id\":\"abc\",\"username\":\"abv-l\",\"hostname\":\"\",\"platform\":\"Windows\",\"alias\":\"\",\"tags\":[\"tag1\",\"tag2\"],\"hash\":\"hash\"}],\"tag_colors\":\"{\\\"tag1\\\":4288585374,\\\"tag2\\\":4278238420,\\\"tag3\\\":4291681337}\"}"`
+	Data     string `json:"data" example:"{\"tags\":[\"tag1\",\"tag2\",\"tag3\"],\"peers\":[{\"id\":\"abc\",\"username\":\"abv-l\",\"hostname\":\"\",\"platform\":\"Windows\",\"alias\":\"\",\"tags\":[\"tag1\",\"tag2\"],\"hash\":\"hash\"}],\"tag_colors\":\"{\\\"tag1\\\":4288585374,\\\"tag2\\\":4278238420,\\\"tag3\\\":4291681337}\"}"`
+	Revision *int64 `json:"revision"` // 修改前的版本号, 也可以用 If-Match 头
 }
 
 type PeerForm struct {
@@ -48,6 +48,18 @@ func Error(c *gin.Context, message string) {
 	})
 }
 
+// Download 以附件形式返回文件, ext 为空时为json
+func Download(c *gin.Context, name, ext string, data []byte) {
+	contentType := "application/json; charset=utf-8"
+	if ext == "" {
+		ext = "json"
+	} else if ext == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+ext))
+	c.Data(http.StatusOK, contentType, data)
+}
+
 type ServerConfigResponse struct {
 	IdServer    string `json:"id_server"`
 	Key         string `json:"key"`
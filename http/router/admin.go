@@ -7,6 +7,7 @@ import (
 	"github.com/lejianwen/rustdesk-api/v2/http/controller/admin"
 	"github.com/lejianwen/rustdesk-api/v2/http/controller/admin/my"
 	"github.com/lejianwen/rustdesk-api/v2/http/middleware"
+	"github.com/lejianwen/rustdesk-api/v2/model"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -22,10 +23,13 @@ func Init(g *gin.Engine) {
 	adg := g.Group("/api/admin")
 	LoginBind(adg)
 	adg.POST("/user/register", (&admin.User{}).Register)
+	InvitationBind(adg)
 
 	ConfigBind(adg)
 
 	adg.Use(middleware.BackendUserAuth())
+	// 只记录登录后的修改类请求
+	adg.Use(middleware.AdminAudit())
 	//FileBind(adg)
 	UserBind(adg)
 	GroupBind(adg)
@@ -35,9 +39,13 @@ func Init(g *gin.Engine) {
 	OauthBind(adg)
 	LoginLogBind(adg)
 	AuditBind(adg)
+	AdminAuditLogBind(adg)
 	AddressBookCollectionBind(adg)
 	AddressBookCollectionRuleBind(adg)
+	AddressBookChangeBind(adg)
+	AddressBookInvitationBind(adg)
 	UserTokenBind(adg)
+	AccessTokenBind(adg)
 
 	//deprecated by ConfigBind
 	//rs := &admin.Rustdesk{}
@@ -49,19 +57,78 @@ func Init(g *gin.Engine) {
 	MyBind(adg)
 
 	RustdeskCmdBind(adg)
+	RelayServerBind(adg)
+	IpRuleBind(adg)
+	ServerCmdScheduleBind(adg)
 	DeviceGroupBind(adg)
+	RoleBind(adg)
 	//访问静态文件
 	//g.StaticFS("/upload", http.Dir(global.Config.Gin.ResourcesPath+"/upload"))
 }
 
+func RoleBind(adg *gin.RouterGroup) {
+	// 角色只有管理员可以管理
+	aR := adg.Group("/role").Use(middleware.AdminPrivilege())
+	{
+		cont := &admin.Role{}
+		aR.GET("/list", cont.List)
+		aR.GET("/detail/:id", cont.Detail)
+		aR.GET("/permissions", cont.Permissions)
+		aR.POST("/create", cont.Create)
+		aR.POST("/update", cont.Update)
+		aR.POST("/delete", cont.Delete)
+	}
+}
+
 func RustdeskCmdBind(adg *gin.RouterGroup) {
 	cont := &admin.Rustdesk{}
-	rg := adg.Group("/rustdesk")
+	rg := adg.Group("/rustdesk").Use(middleware.Permission(model.ResourceServerCmd))
 	rg.POST("/sendCmd", cont.SendCmd)
 	rg.GET("/cmdList", cont.CmdList)
 	rg.POST("/cmdDelete", cont.CmdDelete)
+	rg.GET("/hosts", cont.Hosts)
 	rg.POST("/cmdCreate", cont.CmdCreate)
 }
+func RelayServerBind(rg *gin.RouterGroup) {
+	aR := rg.Group("/relay_server").Use(middleware.Permission(model.ResourceServerCmd))
+	{
+		cont := &admin.RelayServer{}
+		aR.GET("/list", cont.List)
+		aR.GET("/detail/:id", cont.Detail)
+		aR.POST("/create", cont.Create)
+		aR.POST("/update", cont.Update)
+		aR.POST("/delete", cont.Delete)
+		aR.POST("/sendCmd", cont.SendCmd)
+		aR.POST("/sync", cont.Sync)
+		aR.GET("/usage", cont.Usage)
+		aR.GET("/usage/conns", cont.UsageConns)
+	}
+}
+func IpRuleBind(rg *gin.RouterGroup) {
+	aR := rg.Group("/ip_rule").Use(middleware.Permission(model.ResourceServerCmd))
+	{
+		cont := &admin.IpRule{}
+		aR.GET("/list", cont.List)
+		aR.GET("/detail/:id", cont.Detail)
+		aR.POST("/create", cont.Create)
+		aR.POST("/update", cont.Update)
+		aR.POST("/delete", cont.Delete)
+		aR.POST("/sync", cont.Sync)
+	}
+}
+func ServerCmdScheduleBind(rg *gin.RouterGroup) {
+	aR := rg.Group("/server_cmd_schedule").Use(middleware.Permission(model.ResourceServerCmd))
+	{
+		cont := &admin.ServerCmdSchedule{}
+		aR.GET("/list", cont.List)
+		aR.GET("/detail/:id", cont.Detail)
+		aR.POST("/create", cont.Create)
+		aR.POST("/update", cont.Update)
+		aR.POST("/delete", cont.Delete)
+		aR.POST("/run", cont.Run)
+		aR.GET("/runs", cont.Runs)
+	}
+}
 func LoginBind(rg *gin.RouterGroup) {
 	cont := &admin.Login{}
 	rg.POST("/login", cont.Login)
@@ -82,7 +149,7 @@ func UserBind(rg *gin.RouterGroup) {
 		//aR.GET("/myPeer", cont.MyPeer)
 		aR.POST("/groupUsers", cont.GroupUsers)
 	}
-	aRP := rg.Group("/user").Use(middleware.AdminPrivilege())
+	aRP := rg.Group("/user").Use(middleware.Permission(model.ResourceUsers))
 	{
 		cont := &admin.User{}
 		aRP.GET("/list", cont.List)
@@ -95,7 +162,7 @@ func UserBind(rg *gin.RouterGroup) {
 }
 
 func GroupBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/group").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/group").Use(middleware.Permission(model.ResourceGroups))
 	{
 		cont := &admin.Group{}
 		aR.GET("/list", cont.List)
@@ -107,7 +174,7 @@ func GroupBind(rg *gin.RouterGroup) {
 }
 
 func DeviceGroupBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/device_group").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/device_group").Use(middleware.Permission(model.ResourceGroups))
 	{
 		cont := &admin.DeviceGroup{}
 		aR.GET("/list", cont.List)
@@ -119,7 +186,7 @@ func DeviceGroupBind(rg *gin.RouterGroup) {
 }
 
 func TagBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/tag").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/tag").Use(middleware.Permission(model.ResourceAddressBooks))
 	{
 		cont := &admin.Tag{}
 		aR.GET("/list", cont.List)
@@ -136,7 +203,7 @@ func AddressBookBind(rg *gin.RouterGroup) {
 		cont := &admin.AddressBook{}
 		aR.POST("/shareByWebClient", cont.ShareByWebClient)
 
-		arp := aR.Use(middleware.AdminPrivilege())
+		arp := aR.Use(middleware.Permission(model.ResourceAddressBooks))
 		arp.GET("/list", cont.List)
 		//arp.GET("/detail/:id", cont.Detail)
 		arp.POST("/create", cont.Create)
@@ -144,13 +211,16 @@ func AddressBookBind(rg *gin.RouterGroup) {
 		arp.POST("/delete", cont.Delete)
 		arp.POST("/batchCreate", cont.BatchCreate)
 		arp.POST("/batchCreateFromPeers", cont.BatchCreateFromPeers)
+		arp.GET("/export", cont.Export)
+		arp.POST("/import", cont.Import)
+		arp.POST("/bulk", cont.Bulk)
 
 	}
 }
 func PeerBind(rg *gin.RouterGroup) {
 	aR := rg.Group("/peer")
 	aR.POST("/simpleData", (&admin.Peer{}).SimpleData)
-	aR.Use(middleware.AdminPrivilege())
+	aR.Use(middleware.Permission(model.ResourcePeers))
 	{
 		cont := &admin.Peer{}
 		aR.GET("/list", cont.List)
@@ -172,7 +242,7 @@ func OauthBind(rg *gin.RouterGroup) {
 		aR.POST("/unbind", cont.Unbind)
 		aR.GET("/info", cont.Info)
 	}
-	arp := aR.Use(middleware.AdminPrivilege())
+	arp := aR.Use(middleware.Permission(model.ResourceOauth))
 	{
 		cont := &admin.Oauth{}
 		arp.GET("/list", cont.List)
@@ -186,36 +256,69 @@ func OauthBind(rg *gin.RouterGroup) {
 }
 func LoginLogBind(rg *gin.RouterGroup) {
 	cont := &admin.LoginLog{}
-	aR := rg.Group("/login_log").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/login_log").Use(middleware.Permission(model.ResourceAudit))
 	aR.GET("/list", cont.List)
 	aR.POST("/delete", cont.Delete)
 	aR.POST("/batchDelete", cont.BatchDelete)
 }
 func AuditBind(rg *gin.RouterGroup) {
 	cont := &admin.Audit{}
-	aR := rg.Group("/audit_conn").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/audit_conn").Use(middleware.Permission(model.ResourceAudit))
 	aR.GET("/list", cont.ConnList)
 	aR.POST("/delete", cont.ConnDelete)
 	aR.POST("/batchDelete", cont.BatchConnDelete)
-	afR := rg.Group("/audit_file").Use(middleware.AdminPrivilege())
+	afR := rg.Group("/audit_file").Use(middleware.Permission(model.ResourceAudit))
 	afR.GET("/list", cont.FileList)
 	afR.POST("/delete", cont.FileDelete)
 	afR.POST("/batchDelete", cont.BatchFileDelete)
 }
+func AdminAuditLogBind(rg *gin.RouterGroup) {
+	cont := &admin.AdminAuditLog{}
+	aR := rg.Group("/admin_audit_log").Use(middleware.Permission(model.ResourceAudit))
+	aR.GET("/list", cont.List)
+	aR.GET("/detail/:id", cont.Detail)
+}
 func AddressBookCollectionBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/address_book_collection").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/address_book_collection").Use(middleware.Permission(model.ResourceAddressBooks))
 	{
 		cont := &admin.AddressBookCollection{}
 		aR.GET("/list", cont.List)
 		aR.GET("/detail/:id", cont.Detail)
+		aR.GET("/peers/:id", cont.Peers)
 		aR.POST("/create", cont.Create)
 		aR.POST("/update", cont.Update)
 		aR.POST("/delete", cont.Delete)
 	}
 
 }
+func AddressBookChangeBind(rg *gin.RouterGroup) {
+	aR := rg.Group("/address_book_change").Use(middleware.Permission(model.ResourceAddressBooks))
+	{
+		cont := &admin.AddressBookChange{}
+		aR.GET("/list", cont.List)
+		aR.POST("/restore", cont.Restore)
+	}
+}
+
+// InvitationBind 被邀请人查看邀请和注册不需要登录
+func InvitationBind(rg *gin.RouterGroup) {
+	cont := &admin.Invitation{}
+	rg.POST("/invitation/info", cont.Info)
+	rg.POST("/invitation/register", cont.Register)
+}
+func AddressBookInvitationBind(rg *gin.RouterGroup) {
+	rg.POST("/invitation/accept", (&admin.Invitation{}).Accept)
+	aR := rg.Group("/address_book_invitation").Use(middleware.Permission(model.ResourceAddressBooks))
+	{
+		cont := &admin.AddressBookInvitation{}
+		aR.GET("/list", cont.List)
+		aR.POST("/create", cont.Create)
+		aR.POST("/resend", cont.Resend)
+		aR.POST("/delete", cont.Delete)
+	}
+}
 func AddressBookCollectionRuleBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/address_book_collection_rule").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/address_book_collection_rule").Use(middleware.Permission(model.ResourceAddressBooks))
 	{
 		cont := &admin.AddressBookCollectionRule{}
 		aR.GET("/list", cont.List)
@@ -226,12 +329,18 @@ func AddressBookCollectionRuleBind(rg *gin.RouterGroup) {
 	}
 }
 func UserTokenBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/user_token").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/user_token").Use(middleware.Permission(model.ResourceTokens))
 	cont := &admin.UserToken{}
 	aR.GET("/list", cont.List)
 	aR.POST("/delete", cont.Delete)
 	aR.POST("/batchDelete", cont.BatchDelete)
 }
+func AccessTokenBind(rg *gin.RouterGroup) {
+	aR := rg.Group("/access_token").Use(middleware.Permission(model.ResourceTokens))
+	cont := &admin.AccessToken{}
+	aR.GET("/list", cont.List)
+	aR.POST("/delete", cont.Delete)
+}
 func ConfigBind(rg *gin.RouterGroup) {
 	aR := rg.Group("/config")
 	rs := &admin.Config{}
@@ -263,6 +372,16 @@ func MyBind(rg *gin.RouterGroup) {
 		rg.GET("/my/share_record/list", cont.List)
 		rg.POST("/my/share_record/delete", cont.Delete)
 		rg.POST("/my/share_record/batchDelete", cont.BatchDelete)
+		rg.POST("/my/share_record/revoke", cont.Revoke)
+		rg.GET("/my/share_record/access_log", cont.AccessLog)
+	}
+
+	{
+		cont := &my.AddressBookInvitation{}
+		rg.GET("/my/address_book_invitation/list", cont.List)
+		rg.POST("/my/address_book_invitation/create", cont.Create)
+		rg.POST("/my/address_book_invitation/resend", cont.Resend)
+		rg.POST("/my/address_book_invitation/delete", cont.Delete)
 	}
 
 	{
@@ -273,6 +392,9 @@ func MyBind(rg *gin.RouterGroup) {
 		rg.POST("/my/address_book/delete", cont.Delete)
 		rg.POST("/my/address_book/batchCreateFromPeers", cont.BatchCreateFromPeers)
 		rg.POST("/my/address_book/batchUpdateTags", cont.BatchUpdateTags)
+		rg.GET("/my/address_book/export", cont.Export)
+		rg.POST("/my/address_book/import", cont.Import)
+		rg.POST("/my/address_book/bulk", cont.Bulk)
 	}
 
 	{
@@ -286,6 +408,7 @@ func MyBind(rg *gin.RouterGroup) {
 	{
 		cont := &my.AddressBookCollection{}
 		rg.GET("/my/address_book_collection/list", cont.List)
+		rg.GET("/my/address_book_collection/peers/:id", cont.Peers)
 		rg.POST("/my/address_book_collection/create", cont.Create)
 		rg.POST("/my/address_book_collection/update", cont.Update)
 		rg.POST("/my/address_book_collection/delete", cont.Delete)
@@ -298,6 +421,11 @@ func MyBind(rg *gin.RouterGroup) {
 		rg.POST("/my/address_book_collection_rule/update", cont.Update)
 		rg.POST("/my/address_book_collection_rule/delete", cont.Delete)
 	}
+	{
+		cont := &my.AddressBookChange{}
+		rg.GET("/my/address_book_change/list", cont.List)
+		rg.POST("/my/address_book_change/restore", cont.Restore)
+	}
 	{
 		cont := &my.Peer{}
 		rg.GET("/my/peer/list", cont.List)
@@ -310,15 +438,24 @@ func MyBind(rg *gin.RouterGroup) {
 		rg.POST("/my/login_log/delete", cont.Delete)
 		rg.POST("/my/login_log/batchDelete", cont.BatchDelete)
 	}
+
+	{
+		cont := &my.AccessToken{}
+		rg.GET("/my/access_token/list", cont.List)
+		rg.POST("/my/access_token/create", cont.Create)
+		rg.POST("/my/access_token/delete", cont.Delete)
+	}
 }
 
 func ShareRecordBind(rg *gin.RouterGroup) {
-	aR := rg.Group("/share_record").Use(middleware.AdminPrivilege())
+	aR := rg.Group("/share_record").Use(middleware.Permission(model.ResourcePeers))
 	{
 		cont := &admin.ShareRecord{}
 		aR.GET("/list", cont.List)
 		aR.POST("/delete", cont.Delete)
 		aR.POST("/batchDelete", cont.BatchDelete)
+		aR.POST("/revoke", cont.Revoke)
+		aR.GET("/access_log", cont.AccessLog)
 	}
 
 }
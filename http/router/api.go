@@ -49,6 +49,8 @@ func ApiInit(g *gin.Engine) {
 		frg.GET("/oauth/callback", o.OauthCallback)
 		frg.GET("/oauth/login", o.OauthCallback)
 		frg.GET("/oauth/msg", o.Message)
+		// [method:POST] [uri:/api/oidc/backchannel-logout/:op] IdP发起的登出
+		frg.POST("/oidc/backchannel-logout/:op", o.BackchannelLogout)
 	}
 	{
 		pe := &api.Peer{}
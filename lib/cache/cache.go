@@ -2,14 +2,29 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
+	"reflect"
 )
 
 type Handler interface {
 	Get(key string, value interface{}) error
 	Set(key string, value interface{}, exp int) error
+	// Delete 删除, key 不存在时不返回错误
+	Delete(key string) error
+	Exists(key string) (bool, error)
+	// TTL 剩余的过期时间(秒), key 不存在时返回 -1
+	TTL(key string) (int, error)
+	// Incr 原子地加上 delta 并返回新值, key 不存在时从0开始并在 exp 秒后过期, 已存在时不改变过期时间
+	Incr(key string, delta int64, exp int) (int64, error)
+	// GetOrSet 读取到 value, 不存在时调用 fn 生成并写入
+	// 同一个进程内同一个 key 并发时只调用一次 fn, 避免缓存失效时大量请求同时穿透
+	GetOrSet(key string, value interface{}, exp int, fn func() (interface{}, error)) error
 	Gc() error
 }
 
+// ErrNotInteger Incr 的 key 中保存的不是整数
+var ErrNotInteger = errors.New("cache: value is not an integer")
+
 // MaxTimeOut 最大超时时间
 
 const (
@@ -69,3 +84,48 @@ func DecodeValue(value string, rtv interface{}) error {
 	err := json.Unmarshal(([]byte)(value), rtv)
 	return err
 }
+
+// loader 读取并返回 key 是否存在, 用于实现 GetOrSet
+type loader interface {
+	load(key string, value interface{}) (bool, error)
+	Set(key string, value interface{}, exp int) error
+}
+
+func getOrSet(h loader, g *flight, key string, value interface{}, exp int, fn func() (interface{}, error)) error {
+	if ok, err := h.load(key, value); err != nil || ok {
+		return err
+	}
+	v, err := g.Do(key, func() (interface{}, error) {
+		v, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		return v, h.Set(key, v, exp)
+	})
+	if err != nil {
+		return err
+	}
+	return assign(v, value)
+}
+
+// assign 把 src 赋值给指针 dst, 类型不同时通过json转换
+func assign(src interface{}, dst interface{}) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.New("value must be a pointer")
+	}
+	sv := reflect.ValueOf(src)
+	if sv.IsValid() && sv.Type().AssignableTo(dv.Elem().Type()) {
+		dv.Elem().Set(sv)
+		return nil
+	}
+	if sv.IsValid() && sv.Kind() == reflect.Ptr && !sv.IsNil() && sv.Elem().Type().AssignableTo(dv.Elem().Type()) {
+		dv.Elem().Set(sv.Elem())
+		return nil
+	}
+	str, err := EncodeValue(src)
+	if err != nil {
+		return err
+	}
+	return DecodeValue(str, dst)
+}
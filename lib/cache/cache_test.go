@@ -1,10 +1,14 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSimpleCache(t *testing.T) {
@@ -90,3 +94,113 @@ func TestRedisCacheGet(t *testing.T) {
 	}
 	fmt.Println("res", res)
 }
+
+// testHandlerOps Delete/Exists/TTL/Incr/GetOrSet 在各个实现中的行为要一致
+func testHandlerOps(t *testing.T, h Handler) {
+	if ok, _ := h.Exists("ops-missing"); ok {
+		t.Fatalf("不存在的key")
+	}
+	if ttl, _ := h.TTL("ops-missing"); ttl != -1 {
+		t.Fatalf("不存在的key的TTL应为-1, %d", ttl)
+	}
+	_ = h.Set("ops-key", "v", 100)
+	if ok, _ := h.Exists("ops-key"); !ok {
+		t.Fatalf("key应存在")
+	}
+	if ttl, _ := h.TTL("ops-key"); ttl <= 0 || ttl > MaxTimeOut {
+		t.Fatalf("TTL错误 %d", ttl)
+	}
+	if err := h.Delete("ops-key"); err != nil {
+		t.Fatalf("删除失败 %v", err)
+	}
+	if ok, _ := h.Exists("ops-key"); ok {
+		t.Fatalf("key应已删除")
+	}
+	if err := h.Delete("ops-key"); err != nil {
+		t.Fatalf("删除不存在的key不应报错 %v", err)
+	}
+
+	_ = h.Delete("ops-counter")
+	if n, err := h.Incr("ops-counter", 2, 100); err != nil || n != 2 {
+		t.Fatalf("Incr错误 %d %v", n, err)
+	}
+	if n, err := h.Incr("ops-counter", 3, 100); err != nil || n != 5 {
+		t.Fatalf("Incr错误 %d %v", n, err)
+	}
+	_ = h.Set("ops-str", "abc", 100)
+	if _, err := h.Incr("ops-str", 1, 100); err != ErrNotInteger {
+		t.Fatalf("非整数应返回 ErrNotInteger, %v", err)
+	}
+
+	_ = h.Delete("ops-gos")
+	calls := 0
+	fn := func() (interface{}, error) {
+		calls++
+		return "generated", nil
+	}
+	for i := 0; i < 2; i++ {
+		res := ""
+		if err := h.GetOrSet("ops-gos", &res, 100, fn); err != nil || res != "generated" {
+			t.Fatalf("GetOrSet错误 %q %v", res, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fn应只调用一次, %d", calls)
+	}
+	_ = h.Delete("ops-str")
+	_ = h.Delete("ops-counter")
+	_ = h.Delete("ops-gos")
+}
+
+func TestMemoryHandlerOps(t *testing.T) {
+	testHandlerOps(t, NewMemoryCache(0))
+}
+
+func TestFileHandlerOps(t *testing.T) {
+	fc := NewFileCache()
+	fc.SetDir(t.TempDir())
+	testHandlerOps(t, fc)
+}
+
+func TestSimpleHandlerOps(t *testing.T) {
+	testHandlerOps(t, NewSimpleCache())
+}
+
+func TestGetOrSetSingleflight(t *testing.T) {
+	mc := NewMemoryCache(0)
+	var calls int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := 0
+			err := mc.GetOrSet("sf", &res, 100, func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return 42, nil
+			})
+			if err != nil || res != 42 {
+				t.Errorf("GetOrSet错误 %d %v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("fn应只调用一次, %d", calls)
+	}
+}
+
+func TestGetOrSetError(t *testing.T) {
+	mc := NewMemoryCache(0)
+	res := ""
+	err := mc.GetOrSet("err", &res, 100, func() (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	if err == nil {
+		t.Fatalf("应返回fn的错误")
+	}
+	if ok, _ := mc.Exists("err"); ok {
+		t.Fatalf("出错时不应写入")
+	}
+}
@@ -3,7 +3,9 @@ package cache
 import (
 	"crypto/md5"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -12,6 +14,7 @@ type FileCache struct {
 	mu    sync.Mutex
 	locks map[string]*sync.Mutex
 	Dir   string
+	sf    flight
 }
 
 func (fc *FileCache) getLock(key string) *sync.Mutex {
@@ -81,6 +84,94 @@ func (c *FileCache) Set(key string, value interface{}, exp int) error {
 	return err
 }
 
+// expiration 未过期的文件的过期时间(保存在修改时间中), 已过期的文件会被删除
+func (c *FileCache) expiration(f string) (time.Time, bool) {
+	fileInfo, err := os.Stat(f)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !time.Now().Before(fileInfo.ModTime()) {
+		os.Remove(f)
+		return time.Time{}, false
+	}
+	return fileInfo.ModTime(), true
+}
+
+func (c *FileCache) load(key string, value interface{}) (bool, error) {
+	f := c.fileName(key)
+	if _, ok := c.expiration(f); !ok {
+		return false, nil
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return false, nil
+	}
+	return true, DecodeValue(string(data), value)
+}
+
+func (c *FileCache) Delete(key string) error {
+	f := c.fileName(key)
+	lock := c.getLock(f)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *FileCache) Exists(key string) (bool, error) {
+	_, ok := c.expiration(c.fileName(key))
+	return ok, nil
+}
+
+func (c *FileCache) TTL(key string) (int, error) {
+	exp, ok := c.expiration(c.fileName(key))
+	if !ok {
+		return -1, nil
+	}
+	return int(math.Ceil(time.Until(exp).Seconds())), nil
+}
+
+// Incr 只在同一个进程内是原子的, 多个进程共用目录时不保证
+func (c *FileCache) Incr(key string, delta int64, exp int) (int64, error) {
+	f := c.fileName(key)
+	lock := c.getLock(f)
+	lock.Lock()
+	defer lock.Unlock()
+
+	expAt, ok := c.expiration(f)
+	if !ok {
+		if exp <= 0 {
+			exp = MaxTimeOut
+		}
+		expAt = time.Now().Add(time.Duration(exp) * time.Second)
+		return delta, c.writeFile(f, strconv.FormatInt(delta, 10), expAt)
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += delta
+	return n, c.writeFile(f, strconv.FormatInt(n, 10), expAt)
+}
+
+func (c *FileCache) GetOrSet(key string, value interface{}, exp int, fn func() (interface{}, error)) error {
+	return getOrSet(c, &c.sf, key, value, exp, fn)
+}
+
+// writeFile 写入并把修改时间设置为过期时间, 需要持有文件的锁
+func (c *FileCache) writeFile(f string, value string, expAt time.Time) error {
+	if err := os.WriteFile(f, []byte(value), 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(f, expAt, expAt)
+}
+
 func (c *FileCache) SetDir(path string) {
 	c.Dir = path
 }
@@ -5,6 +5,7 @@ import (
 	"container/list"
 	"errors"
 	"reflect"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -17,6 +18,7 @@ type MemoryCache struct {
 	mu        sync.Mutex
 	maxBytes  int64
 	usedBytes int64
+	sf        flight
 }
 
 type CacheItem struct {
@@ -90,13 +92,18 @@ func (m *MemoryCache) Get(key string, value interface{}) error {
 }
 
 func (m *MemoryCache) Set(key string, value interface{}, exp int) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	v, err := EncodeValue(value)
 	if err != nil {
 		return err
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.set(key, v, exp)
+}
+
+// set 需要持有锁
+func (m *MemoryCache) set(key string, v string, exp int) error {
 	//key 所占用的内存
 	keyBytes := int64(len(key))
 	//value所占用的内存空间大小
@@ -134,6 +141,80 @@ func (m *MemoryCache) Set(key string, value interface{}, exp int) error {
 	return nil
 }
 
+// item 未过期的缓存项, 已过期的会被删除; 需要持有锁
+func (m *MemoryCache) item(key string) (*CacheItem, bool) {
+	item, ok := m.data[key]
+	if !ok {
+		return nil, false
+	}
+	if item.Expiration < time.Now().UnixNano() {
+		m.deleteItem(item)
+		return nil, false
+	}
+	return item, true
+}
+
+func (m *MemoryCache) load(key string, value interface{}) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.item(key)
+	if !ok {
+		return false, nil
+	}
+	m.ll.MoveToBack(item.ListEle)
+	return true, DecodeValue(item.Value, value)
+}
+
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.data[key]; ok {
+		m.deleteItem(item)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.item(key)
+	return ok, nil
+}
+
+func (m *MemoryCache) TTL(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.item(key)
+	if !ok {
+		return -1, nil
+	}
+	// 向上取整, 未过期的key至少返回1
+	return int((item.Expiration - time.Now().UnixNano() + int64(time.Second) - 1) / int64(time.Second)), nil
+}
+
+func (m *MemoryCache) Incr(key string, delta int64, exp int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.item(key)
+	if !ok {
+		return delta, m.set(key, strconv.FormatInt(delta, 10), exp)
+	}
+	n, err := strconv.ParseInt(item.Value, 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += delta
+	v := strconv.FormatInt(n, 10)
+	m.usedBytes += int64(len(v)) - int64(len(item.Value))
+	item.Value = v
+	m.ll.MoveToBack(item.ListEle)
+	return n, nil
+}
+
+func (m *MemoryCache) GetOrSet(key string, value interface{}, exp int, fn func() (interface{}, error)) error {
+	return getOrSet(m, &m.sf, key, value, exp, fn)
+}
+
 func (m *MemoryCache) RemoveOldest() {
 	for m.maxBytes != 0 && m.usedBytes > m.maxBytes {
 		elem := m.ll.Front()
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"strings"
 	"time"
 )
 
@@ -10,8 +11,12 @@ var ctx = context.Background()
 
 type RedisCache struct {
 	rdb *redis.Client
+	sf  flight
 }
 
+// 新建的key没有过期时间, 这时设置过期时间
+var redisIncrScript = redis.NewScript(`local n = redis.call("incrby", KEYS[1], ARGV[1]) if redis.call("ttl", KEYS[1]) == -1 then redis.call("expire", KEYS[1], ARGV[2]) end return n`)
+
 func RedisCacheInit(conf *redis.Options) *RedisCache {
 	c := &RedisCache{}
 	c.rdb = redis.NewClient(conf)
@@ -39,6 +44,56 @@ func (c *RedisCache) Set(key string, value interface{}, exp int) error {
 	return err1
 }
 
+func (c *RedisCache) load(key string, value interface{}) (bool, error) {
+	data, err := c.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, DecodeValue(data, value)
+}
+
+func (c *RedisCache) Delete(key string) error {
+	return c.rdb.Del(ctx, key).Err()
+}
+
+func (c *RedisCache) Exists(key string) (bool, error) {
+	n, err := c.rdb.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (c *RedisCache) TTL(key string) (int, error) {
+	d, err := c.rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return -1, err
+	}
+	switch d {
+	case -2:
+		return -1, nil
+	case -1:
+		// 不是通过 Set 写入的, 没有过期时间
+		return MaxTimeOut, nil
+	}
+	return int(d / time.Second), nil
+}
+
+func (c *RedisCache) Incr(key string, delta int64, exp int) (int64, error) {
+	if exp <= 0 {
+		exp = MaxTimeOut
+	}
+	n, err := redisIncrScript.Run(ctx, c.rdb, []string{key}, delta, exp).Int64()
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, ErrNotInteger
+	}
+	return n, err
+}
+
+func (c *RedisCache) GetOrSet(key string, value interface{}, exp int, fn func() (interface{}, error)) error {
+	return getOrSet(c, &c.sf, key, value, exp, fn)
+}
+
 func (c *RedisCache) Gc() error {
 	return nil
 }
@@ -13,6 +13,7 @@ type SimpleCache struct {
 	mu        sync.Mutex
 	maxBytes  int64
 	usedBytes int64
+	sf        flight
 }
 
 func (s *SimpleCache) Get(key string, value interface{}) error {
@@ -54,6 +55,59 @@ func (s *SimpleCache) Set(key string, value interface{}, exp int) error {
 	s.data[key] = val.Interface()
 	return nil
 }
+func (s *SimpleCache) load(key string, value interface{}) (bool, error) {
+	s.mu.Lock()
+	v, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, assign(v, value)
+}
+
+func (s *SimpleCache) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *SimpleCache) Exists(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data[key]
+	return ok, nil
+}
+
+// TTL 不会过期, 存在时返回 MaxTimeOut
+func (s *SimpleCache) TTL(key string) (int, error) {
+	if ok, _ := s.Exists(key); !ok {
+		return -1, nil
+	}
+	return MaxTimeOut, nil
+}
+
+func (s *SimpleCache) Incr(key string, delta int64, exp int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		s.data[key] = delta
+		return delta, nil
+	}
+	vv := reflect.ValueOf(v)
+	if !vv.CanInt() {
+		return 0, ErrNotInteger
+	}
+	n := vv.Int() + delta
+	s.data[key] = n
+	return n, nil
+}
+
+func (s *SimpleCache) GetOrSet(key string, value interface{}, exp int, fn func() (interface{}, error)) error {
+	return getOrSet(s, &s.sf, key, value, exp, fn)
+}
+
 func (s *SimpleCache) Gc() error {
 	return nil
 }
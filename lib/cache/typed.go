@@ -0,0 +1,45 @@
+package cache
+
+// Typed 类型安全的缓存, key 会加上 prefix, 使用相同的过期时间
+//
+//	users := cache.NewTyped[model.User](global.Cache, "user:", 60)
+//	u, err := users.GetOrSet("1", func() (model.User, error) { ... })
+type Typed[T any] struct {
+	h      Handler
+	prefix string
+	exp    int
+}
+
+func NewTyped[T any](h Handler, prefix string, exp int) *Typed[T] {
+	return &Typed[T]{h: h, prefix: prefix, exp: exp}
+}
+
+// Get 第二个返回值表示 key 是否存在
+func (t *Typed[T]) Get(key string) (T, bool, error) {
+	var v T
+	ok, err := t.h.Exists(t.prefix + key)
+	if err != nil || !ok {
+		return v, false, err
+	}
+	if err = t.h.Get(t.prefix+key, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+func (t *Typed[T]) Set(key string, v T) error {
+	return t.h.Set(t.prefix+key, v, t.exp)
+}
+
+func (t *Typed[T]) Delete(key string) error {
+	return t.h.Delete(t.prefix + key)
+}
+
+// GetOrSet 不存在时调用 fn 生成并写入, fn 返回错误时不写入
+func (t *Typed[T]) GetOrSet(key string, fn func() (T, error)) (T, error) {
+	var v T
+	err := t.h.GetOrSet(t.prefix+key, &v, t.exp, func() (interface{}, error) {
+		return fn()
+	})
+	return v, err
+}
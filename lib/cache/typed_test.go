@@ -0,0 +1,35 @@
+package cache
+
+import "testing"
+
+type typedUser struct {
+	Id   uint
+	Name string
+}
+
+func TestTyped(t *testing.T) {
+	users := NewTyped[typedUser](NewMemoryCache(0), "user:", 100)
+	if _, ok, err := users.Get("1"); ok || err != nil {
+		t.Fatalf("不存在的key %v %v", ok, err)
+	}
+	if err := users.Set("1", typedUser{Id: 1, Name: "a"}); err != nil {
+		t.Fatalf("写入失败 %v", err)
+	}
+	u, ok, err := users.Get("1")
+	if !ok || err != nil || u.Name != "a" {
+		t.Fatalf("读取错误 %v %v %v", u, ok, err)
+	}
+	u, err = users.GetOrSet("2", func() (typedUser, error) {
+		return typedUser{Id: 2, Name: "b"}, nil
+	})
+	if err != nil || u.Id != 2 {
+		t.Fatalf("GetOrSet错误 %v %v", u, err)
+	}
+	if u, _, _ = users.Get("2"); u.Name != "b" {
+		t.Fatalf("GetOrSet应写入缓存 %v", u)
+	}
+	_ = users.Delete("2")
+	if _, ok, _ = users.Get("2"); ok {
+		t.Fatalf("应已删除")
+	}
+}
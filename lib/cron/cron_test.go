@@ -0,0 +1,68 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	loc := time.UTC
+	at := func(s string) time.Time {
+		tm, _ := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		return tm
+	}
+	// 2024-01-01 是周一
+	cases := []struct {
+		spec, from, want string
+	}{
+		{"* * * * *", "2024-01-01 10:00", "2024-01-01 10:01"},
+		{"0 9 * * 1-5", "2024-01-01 09:00", "2024-01-02 09:00"},
+		{"0 9 * * mon-fri", "2024-01-05 10:00", "2024-01-08 09:00"},
+		{"0 18 * * *", "2024-01-01 17:59", "2024-01-01 18:00"},
+		{"*/15 * * * *", "2024-01-01 10:16", "2024-01-01 10:30"},
+		{"5/20 * * * *", "2024-01-01 10:26", "2024-01-01 10:45"},
+		{"0 0 1 * *", "2024-01-15 00:00", "2024-02-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		{"@hourly", "2024-01-01 10:30", "2024-01-01 11:00"},
+		{"0 0 1,15 * *", "2024-01-02 00:00", "2024-01-15 00:00"},
+		// 日和周都有限制时满足其一即可
+		{"0 0 13 * 5", "2024-01-01 00:00", "2024-01-05 00:00"},
+		{"0 0 31 dec *", "2024-01-01 00:00", "2024-12-31 00:00"},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", c.spec, err)
+			continue
+		}
+		if got := s.Next(at(c.from)); !got.Equal(at(c.want)) {
+			t.Errorf("Next(%q, %s) = %s, want %s", c.spec, c.from, got.Format("2006-01-02 15:04"), c.want)
+		}
+	}
+	s, _ := Parse("0 0 30 2 *")
+	if got := s.Next(at("2024-01-01 00:00")); !got.IsZero() {
+		t.Errorf("Feb 30 should never match, got %s", got)
+	}
+}
+
+func TestNextTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+	s, _ := Parse("0 9 * * *")
+	from := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC).In(loc) // 上海 10:00
+	want := time.Date(2024, 1, 2, 9, 0, 0, 0, loc)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
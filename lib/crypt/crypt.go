@@ -0,0 +1,166 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Prefix 加密后的值的前缀, 没有前缀的值视为明文(加密之前的旧数据)
+const Prefix = "enc:v1:"
+
+var (
+	ErrNoKey      = errors.New("crypt: master key is not configured")
+	ErrUnknownKey = errors.New("crypt: unknown master key")
+	ErrInvalid    = errors.New("crypt: invalid encrypted value")
+)
+
+// Keyring 信封加密, 每个值使用随机的数据密钥(DEK)加密, DEK再用主密钥加密后和密文一起保存
+// 主密钥轮换时, 旧的主密钥放入 old keys 用于解密, 新写入的值使用当前主密钥
+type Keyring struct {
+	currentId string
+	keys      map[string][]byte
+}
+
+// NewKeyring current为空时不加密, 只能解密明文
+func NewKeyring(current string, old []string) *Keyring {
+	k := &Keyring{keys: map[string][]byte{}}
+	for _, o := range old {
+		if o != "" {
+			id, key := deriveKey(o)
+			k.keys[id] = key
+		}
+	}
+	if current != "" {
+		id, key := deriveKey(current)
+		k.keys[id] = key
+		k.currentId = id
+	}
+	return k
+}
+
+// deriveKey 主密钥可以是任意长度的字符串, 通过sha256得到AES-256密钥, id用于区分不同的主密钥
+func deriveKey(s string) (string, []byte) {
+	key := sha256.Sum256([]byte(s))
+	sum := sha256.Sum256(key[:])
+	return hex.EncodeToString(sum[:4]), key[:]
+}
+
+// Enabled 是否配置了主密钥
+func (k *Keyring) Enabled() bool {
+	return k != nil && k.currentId != ""
+}
+
+// IsEncrypted 是否是加密后的值
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// NeedsRekey 明文或者不是用当前主密钥加密的值需要重新加密
+func (k *Keyring) NeedsRekey(s string) bool {
+	if !k.Enabled() || s == "" {
+		return false
+	}
+	if !IsEncrypted(s) {
+		return true
+	}
+	return !strings.HasPrefix(s, Prefix+k.currentId+":")
+}
+
+// Encrypt 加密, 没有配置主密钥时原样返回
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	if !k.Enabled() || plain == "" {
+		return plain, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.currentId], dek)
+	if err != nil {
+		return "", err
+	}
+	ct, err := seal(dek, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return Prefix + k.currentId + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ct), nil
+}
+
+// Decrypt 解密, 明文原样返回
+func (k *Keyring) Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	if k == nil || len(k.keys) == 0 {
+		return "", ErrNoKey
+	}
+	parts := strings.Split(strings.TrimPrefix(s, Prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrInvalid
+	}
+	key, ok := k.keys[parts[0]]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	enc := base64.RawStdEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	ct, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	dek, err := open(key, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dek, ct)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// seal AES-256-GCM, 输出 nonce|密文
+func seal(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrInvalid
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return plain, nil
+}
@@ -0,0 +1,80 @@
+package crypt
+
+import (
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := NewKeyring("master-key", nil)
+	enc, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(enc) || enc == "secret" {
+		t.Fatalf("value is not encrypted: %s", enc)
+	}
+	enc2, _ := k.Encrypt("secret")
+	if enc == enc2 {
+		t.Fatal("ciphertext should be random")
+	}
+	plain, err := k.Decrypt(enc)
+	if err != nil || plain != "secret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+	// 明文原样返回
+	plain, err = k.Decrypt("legacy")
+	if err != nil || plain != "legacy" {
+		t.Fatalf("Decrypt plaintext = %q, %v", plain, err)
+	}
+	if enc, _ = k.Encrypt(""); enc != "" {
+		t.Fatal("empty value should not be encrypted")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	old := NewKeyring("old-key", nil)
+	enc, _ := old.Encrypt("secret")
+
+	k := NewKeyring("new-key", []string{"old-key"})
+	if !k.NeedsRekey(enc) || !k.NeedsRekey("plain") {
+		t.Fatal("old and plain values should need rekey")
+	}
+	plain, err := k.Decrypt(enc)
+	if err != nil || plain != "secret" {
+		t.Fatalf("Decrypt with old key = %q, %v", plain, err)
+	}
+	enc2, _ := k.Encrypt(plain)
+	if k.NeedsRekey(enc2) {
+		t.Fatal("value encrypted with the current key should not need rekey")
+	}
+
+	// 没有旧密钥时不能解密
+	if _, err = NewKeyring("new-key", nil).Decrypt(enc); err != ErrUnknownKey {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	if _, err = NewKeyring("", nil).Decrypt(enc); err != ErrNoKey {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+	// 篡改
+	b := []byte(enc2)
+	i := len(b) - 5
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	if _, err = k.Decrypt(string(b)); err == nil {
+		t.Fatal("tampered value should fail")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	k := NewKeyring("", nil)
+	enc, err := k.Encrypt("secret")
+	if err != nil || enc != "secret" {
+		t.Fatalf("disabled keyring should keep plaintext, got %q, %v", enc, err)
+	}
+	if k.NeedsRekey("secret") {
+		t.Fatal("disabled keyring should not rekey")
+	}
+}
@@ -0,0 +1,48 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// SerializerName 字段加上 `gorm:"serializer:encrypted"` 即可透明加解密
+// 注意: Update/Updates 使用 map 时不会经过 serializer
+const SerializerName = "encrypted"
+
+// Serializer gorm serializer, 只支持string字段
+type Serializer struct {
+	Keyring *Keyring
+}
+
+// RegisterSerializer 需要在gorm解析model之前注册
+func RegisterSerializer(k *Keyring) {
+	schema.RegisterSerializer(SerializerName, &Serializer{Keyring: k})
+}
+
+func (s *Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var raw string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("crypt: unsupported value type %T", dbValue)
+	}
+	plain, err := s.Keyring.Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	return field.Set(ctx, dst, plain)
+}
+
+func (s *Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	v, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("crypt: unsupported field type %T", fieldValue)
+	}
+	return s.Keyring.Encrypt(v)
+}
@@ -2,10 +2,14 @@ package lock
 
 import (
 	"sync"
+	"time"
 )
 
+// Local 进程内的锁, 单实例部署时使用
 type Local struct {
-	Locks *sync.Map
+	Locks  *sync.Map
+	mu     sync.Mutex
+	leases map[string]time.Time
 }
 
 func (l *Local) Lock(key string) {
@@ -25,8 +29,23 @@ func (l *Local) GetLock(key string) *sync.Mutex {
 	return lock.(*sync.Mutex)
 }
 
+func (l *Local) TryLease(key string, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if exp, ok := l.leases[key]; ok && now.Before(exp) {
+		return false
+	}
+	if l.leases == nil {
+		l.leases = make(map[string]time.Time)
+	}
+	l.leases[key] = now.Add(ttl)
+	return true
+}
+
 func NewLocal() *Local {
 	return &Local{
-		Locks: &sync.Map{},
+		Locks:  &sync.Map{},
+		leases: make(map[string]time.Time),
 	}
 }
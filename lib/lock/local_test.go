@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestLocal_GetLock(t *testing.T) {
@@ -69,6 +70,23 @@ func TestLocal_Lock(t *testing.T) {
 	fmt.Println(i)
 
 }
+func TestLocal_TryLease(t *testing.T) {
+	l := NewLocal()
+	if !l.TryLease("job", 50*time.Millisecond) {
+		t.Fatal("first lease should succeed")
+	}
+	if l.TryLease("job", 50*time.Millisecond) {
+		t.Fatal("lease is held")
+	}
+	if !l.TryLease("other", 50*time.Millisecond) {
+		t.Fatal("other key should succeed")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !l.TryLease("job", 50*time.Millisecond) {
+		t.Fatal("lease should expire")
+	}
+}
+
 func TestSyncMap(t *testing.T) {
 	m := sync.Map{}
 	wg := sync.WaitGroup{}
@@ -1,9 +1,11 @@
 package lock
 
-import "sync"
+import "time"
 
 type Locker interface {
-	GetLock(key string) *sync.Mutex
 	Lock(key string)
 	UnLock(key string)
+	// TryLease 获取一个到期自动释放的租约, 租约期间其他的调用返回false
+	// 用于多实例部署时定时任务只在一个实例上执行
+	TryLease(key string, ttl time.Duration) bool
 }
@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-redis/redis/v8"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseTTL redis锁的默认租约时间
+const DefaultLeaseTTL = 30 * time.Second
+
+const redisRetryInterval = 50 * time.Millisecond
+
+var (
+	// 只有持有者(token相同)才能续约和释放, 避免租约过期后释放了别人的锁
+	redisRenewScript  = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+	redisUnlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+)
+
+// Redis 基于redis的分布式锁, 多实例部署时使用
+// 锁带有租约(TTL), 持有期间定时续约, 进程异常退出后租约到期自动释放
+type Redis struct {
+	rdb    redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+	local  *Local   // 同一个进程内先排队, 同一个key只有一个goroutine去竞争redis
+	held   sync.Map // key => *redisHold
+	// OnError redis出错时的回调, 如记录日志
+	OnError func(err error)
+}
+
+type redisHold struct {
+	token string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func NewRedis(rdb redis.UniversalClient, prefix string, ttl time.Duration) *Redis {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &Redis{
+		rdb:    rdb,
+		prefix: prefix,
+		ttl:    ttl,
+		local:  NewLocal(),
+	}
+}
+
+// Lock 阻塞直到获取锁
+// redis持续出错超过一个租约时间时退化为只持有进程内的锁, 避免redis故障时请求一直挂起
+func (r *Redis) Lock(key string) {
+	r.local.Lock(key)
+	ctx := context.Background()
+	token := newToken()
+	var failingSince time.Time
+	for {
+		ok, err := r.rdb.SetNX(ctx, r.prefix+key, token, r.ttl).Result()
+		if err == nil && ok {
+			break
+		}
+		if err != nil {
+			r.error(err)
+			if failingSince.IsZero() {
+				failingSince = time.Now()
+			} else if time.Since(failingSince) > r.ttl {
+				return
+			}
+		} else {
+			failingSince = time.Time{}
+		}
+		time.Sleep(redisRetryInterval)
+	}
+	h := &redisHold{token: token, stop: make(chan struct{}), done: make(chan struct{})}
+	r.held.Store(key, h)
+	go r.renew(key, h)
+}
+
+func (r *Redis) UnLock(key string) {
+	v, ok := r.held.LoadAndDelete(key)
+	if ok {
+		h := v.(*redisHold)
+		close(h.stop)
+		<-h.done
+		if err := redisUnlockScript.Run(context.Background(), r.rdb, []string{r.prefix + key}, h.token).Err(); err != nil {
+			r.error(err)
+		}
+	}
+	r.local.UnLock(key)
+}
+
+// TryLease 租约不会主动释放, 到期后其他实例才能再次获取; redis出错时返回true, 宁可重复执行也不要不执行
+func (r *Redis) TryLease(key string, ttl time.Duration) bool {
+	ok, err := r.rdb.SetNX(context.Background(), r.prefix+"lease:"+key, newToken(), ttl).Result()
+	if err != nil {
+		r.error(err)
+		return true
+	}
+	return ok
+}
+
+// renew 每1/3个租约时间续约一次, 锁已经不属于自己时停止
+func (r *Redis) renew(key string, h *redisHold) {
+	defer close(h.done)
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			n, err := redisRenewScript.Run(context.Background(), r.rdb, []string{r.prefix + key}, h.token, r.ttl.Milliseconds()).Int()
+			if err != nil {
+				r.error(err)
+				continue
+			}
+			if n == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (r *Redis) error(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,110 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/lib/redistest"
+)
+
+// newRedisTestServer 注册锁用到的脚本
+func newRedisTestServer(t *testing.T) *redistest.Server {
+	srv := redistest.NewServer(t)
+	srv.Script(redisUnlockScript.Hash(), func(s *redistest.Server, keys, args []string) interface{} {
+		if v, ok := s.Get(keys[0]); ok && v == args[0] {
+			return s.Del(keys[0])
+		}
+		return int64(0)
+	})
+	srv.Script(redisRenewScript.Hash(), func(s *redistest.Server, keys, args []string) interface{} {
+		if v, ok := s.Get(keys[0]); ok && v == args[0] {
+			ms, _ := time.ParseDuration(args[1] + "ms")
+			return s.PExpire(keys[0], ms)
+		}
+		return int64(0)
+	})
+	return srv
+}
+
+// lockAsync 在goroutine中加锁, 获取到锁后关闭返回的channel
+func lockAsync(l *Redis, key string) chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		l.Lock(key)
+		close(ch)
+	}()
+	return ch
+}
+
+func TestRedisLockExclusive(t *testing.T) {
+	srv := newRedisTestServer(t)
+	a := NewRedis(srv.Client(t), "test:", time.Minute)
+	b := NewRedis(srv.Client(t), "test:", time.Minute)
+	a.Lock("k")
+	got := lockAsync(b, "k")
+	select {
+	case <-got:
+		t.Fatal("b acquired a lock held by a")
+	case <-time.After(200 * time.Millisecond):
+	}
+	a.UnLock("k")
+	select {
+	case <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("b did not acquire the lock after a released it")
+	}
+	b.UnLock("k")
+	if _, ok := srv.Get("test:k"); ok {
+		t.Fatal("lock key not deleted on unlock")
+	}
+}
+
+func TestRedisLockLeaseExpires(t *testing.T) {
+	srv := newRedisTestServer(t)
+	// 租约很长, 测试期间不会续约, 相当于持有者已经退出
+	a := NewRedis(srv.Client(t), "test:", time.Hour)
+	b := NewRedis(srv.Client(t), "test:", time.Hour)
+	a.Lock("k")
+	got := lockAsync(b, "k")
+	select {
+	case <-got:
+		t.Fatal("b acquired the lock before the lease expired")
+	case <-time.After(200 * time.Millisecond):
+	}
+	srv.FastForward(time.Hour + time.Second)
+	select {
+	case <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("b did not acquire the lock after the lease expired")
+	}
+	token, _ := srv.Get("test:k")
+
+	// a的租约已经过期, 释放时不能删除b的锁
+	a.UnLock("k")
+	if v, ok := srv.Get("test:k"); !ok || v != token {
+		t.Fatalf("non-owner unlock changed the lock: %q %v", v, ok)
+	}
+	c := NewRedis(srv.Client(t), "test:", time.Hour)
+	select {
+	case <-lockAsync(c, "k"):
+		t.Fatal("c acquired the lock still held by b")
+	case <-time.After(200 * time.Millisecond):
+	}
+	b.UnLock("k")
+}
+
+func TestRedisTryLease(t *testing.T) {
+	srv := newRedisTestServer(t)
+	a := NewRedis(srv.Client(t), "test:", 0)
+	b := NewRedis(srv.Client(t), "test:", 0)
+	if !a.TryLease("job", time.Minute) {
+		t.Fatal("first lease not granted")
+	}
+	if b.TryLease("job", time.Minute) || a.TryLease("job", time.Minute) {
+		t.Fatal("lease granted twice")
+	}
+	srv.FastForward(time.Minute)
+	if !b.TryLease("job", time.Minute) {
+		t.Fatal("lease not granted after expiry")
+	}
+}
@@ -0,0 +1,113 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotConfigured = errors.New("mail: smtp is not configured")
+
+// Smtp 使用smtp发送纯文本邮件
+type Smtp struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Tls      bool // 直接使用tls连接, 否则服务器支持时使用STARTTLS
+}
+
+// Enabled 是否配置了smtp
+func (s *Smtp) Enabled() bool {
+	return s != nil && s.Host != ""
+}
+
+// Send 发送邮件
+func (s *Smtp) Send(to, subject, body string) error {
+	if !s.Enabled() {
+		return ErrNotConfigured
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return errors.New("mail: invalid from address: " + s.From)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return errors.New("mail: invalid address: " + to)
+	}
+	msg := BuildMessage(from, rcpt, subject, body, time.Now())
+
+	port := s.Port
+	if port == 0 {
+		port = 587
+		if s.Tls {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	if !s.Tls {
+		return smtp.SendMail(addr, auth, from.Address, []string{rcpt.Address}, msg)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: s.Host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err = c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err = c.Rcpt(rcpt.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// BuildMessage 生成邮件内容, 主题使用 RFC 2047 编码, 正文为utf-8纯文本
+func BuildMessage(from, to *mail.Address, subject, body string, date time.Time) []byte {
+	subject = strings.NewReplacer("\r", "", "\n", " ").Replace(subject)
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	var b bytes.Buffer
+	b.WriteString("From: " + from.String() + "\r\n")
+	b.WriteString("To: " + to.String() + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return b.Bytes()
+}
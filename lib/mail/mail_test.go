@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "RustDesk", Address: "noreply@example.com"}
+	to := &mail.Address{Address: "partner@example.org"}
+	msg := string(BuildMessage(from, to, "邀请\r\nBcc: evil@example.com", "line1\nline2", time.Unix(0, 0).UTC()))
+
+	head, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatal("no header separator")
+	}
+	if strings.Contains(head, "\r\nBcc:") {
+		t.Error("header injected through subject")
+	}
+	if !strings.Contains(head, "Subject: =?utf-8?q?") {
+		t.Errorf("subject not encoded: %s", head)
+	}
+	if !strings.Contains(head, "To: <partner@example.org>") {
+		t.Errorf("bad To header: %s", head)
+	}
+	if body != "line1\r\nline2" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSendNotConfigured(t *testing.T) {
+	s := &Smtp{}
+	if err := s.Send("a@example.com", "s", "b"); err != ErrNotConfigured {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,461 @@
+// Package redistest 测试用的内存redis, 只实现了项目中用到的命令
+// lua脚本不解析, 按sha1注册对应的go函数
+package redistest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ScriptFunc 脚本的实现, 不持有服务端的锁, 通过 Get/Del/PExpire 读写数据
+type ScriptFunc func(s *Server, keys, args []string) interface{}
+
+type entry struct {
+	str      string
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+// Server 一个监听本地端口的redis服务端
+type Server struct {
+	mu      sync.Mutex
+	data    map[string]*entry
+	offset  time.Duration
+	scripts map[string]ScriptFunc
+	ln      net.Listener
+}
+
+type status string
+
+// NewServer 启动服务端, 测试结束后关闭
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{data: map[string]*entry{}, scripts: map[string]ScriptFunc{}, ln: ln}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Client 连接到服务端的客户端, 多次调用相当于多个实例
+func (s *Server) Client(t *testing.T) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{Addr: s.ln.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// Script 注册脚本, sha 为 redis.Script.Hash()
+func (s *Server) Script(sha string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[sha] = fn
+}
+
+// FastForward 时间前进d, 用于测试过期
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(key)
+	if e == nil || e.zset != nil {
+		return "", false
+	}
+	return e.str, true
+}
+
+func (s *Server) Del(key string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(key) == nil {
+		return 0
+	}
+	delete(s.data, key)
+	return 1
+}
+
+func (s *Server) PExpire(key string, d time.Duration) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(key)
+	if e == nil {
+		return 0
+	}
+	e.expireAt = s.now().Add(d)
+	return 1
+}
+
+// TTL 剩余的过期时间, 不存在时为-2, 没有过期时间时为-1
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(key)
+	if e == nil {
+		return -2
+	}
+	if e.expireAt.IsZero() {
+		return -1
+	}
+	return e.expireAt.Sub(s.now())
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// lookup 取未过期的key, 需要持有锁
+func (s *Server) lookup(key string) *entry {
+	e, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !s.now().Before(e.expireAt) {
+		delete(s.data, key)
+		return nil
+	}
+	return e
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var queue [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToLower(args[0])
+		switch {
+		case cmd == "multi":
+			inMulti = true
+			queue = nil
+			writeReply(w, status("OK"))
+		case cmd == "exec":
+			res := make([]interface{}, 0, len(queue))
+			for _, q := range queue {
+				res = append(res, s.exec(q))
+			}
+			inMulti = false
+			queue = nil
+			writeReply(w, res)
+		case inMulti:
+			queue = append(queue, args)
+			writeReply(w, status("QUEUED"))
+		default:
+			writeReply(w, s.exec(args))
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(args []string) interface{} {
+	cmd := strings.ToLower(args[0])
+	switch cmd {
+	case "eval", "evalsha":
+		return s.eval(cmd, args[1:])
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch cmd {
+	case "ping":
+		return status("PONG")
+	case "get":
+		if len(args) != 2 {
+			return errArgs(cmd)
+		}
+		e := s.lookup(args[1])
+		if e == nil {
+			return nil
+		}
+		return e.str
+	case "set":
+		return s.set(args[1:])
+	case "del":
+		var n int64
+		for _, k := range args[1:] {
+			if s.lookup(k) != nil {
+				delete(s.data, k)
+				n++
+			}
+		}
+		return n
+	case "pexpire":
+		if len(args) != 3 {
+			return errArgs(cmd)
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		e := s.lookup(args[1])
+		if e == nil {
+			return int64(0)
+		}
+		e.expireAt = s.now().Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+	case "zadd":
+		if len(args) < 4 || len(args)%2 != 0 {
+			return errArgs(cmd)
+		}
+		e := s.lookup(args[1])
+		if e == nil {
+			e = &entry{zset: map[string]float64{}}
+			s.data[args[1]] = e
+		}
+		var n int64
+		for i := 2; i < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				return err
+			}
+			if _, ok := e.zset[args[i+1]]; !ok {
+				n++
+			}
+			e.zset[args[i+1]] = score
+		}
+		return n
+	case "zcard":
+		e := s.lookup(args[1])
+		if e == nil {
+			return int64(0)
+		}
+		return int64(len(e.zset))
+	case "zcount", "zremrangebyscore":
+		if len(args) != 4 {
+			return errArgs(cmd)
+		}
+		min, err := parseBound(args[2])
+		if err != nil {
+			return err
+		}
+		max, err := parseBound(args[3])
+		if err != nil {
+			return err
+		}
+		e := s.lookup(args[1])
+		if e == nil {
+			return int64(0)
+		}
+		var n int64
+		for m, score := range e.zset {
+			if min.above(score) && max.below(score) {
+				n++
+				if cmd == "zremrangebyscore" {
+					delete(e.zset, m)
+				}
+			}
+		}
+		return n
+	}
+	return fmt.Errorf("unknown command '%s'", cmd)
+}
+
+// set 支持 EX/PX/NX
+func (s *Server) set(args []string) interface{} {
+	if len(args) < 2 {
+		return errArgs("set")
+	}
+	e := &entry{str: args[1]}
+	nx := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				return errArgs("set")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return err
+			}
+			unit := time.Second
+			if strings.ToLower(args[i]) == "px" {
+				unit = time.Millisecond
+			}
+			e.expireAt = s.now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			return errors.New("syntax error")
+		}
+	}
+	if nx && s.lookup(args[0]) != nil {
+		return nil
+	}
+	s.data[args[0]] = e
+	return status("OK")
+}
+
+func (s *Server) eval(cmd string, args []string) interface{} {
+	if len(args) < 2 {
+		return errArgs(cmd)
+	}
+	sha := args[0]
+	if cmd == "eval" {
+		sum := sha1.Sum([]byte(args[0]))
+		sha = hex.EncodeToString(sum[:])
+	}
+	s.mu.Lock()
+	fn, ok := s.scripts[sha]
+	s.mu.Unlock()
+	if !ok {
+		if cmd == "evalsha" {
+			return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		return errors.New("unknown script")
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 0 || n > len(args)-2 {
+		return errArgs(cmd)
+	}
+	return fn(s, args[2:2+n], args[2+n:])
+}
+
+func errArgs(cmd string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", cmd)
+}
+
+// bound 分数范围的一端, 以 ( 开头时不包含
+type bound struct {
+	v         float64
+	exclusive bool
+}
+
+func parseBound(s string) (bound, error) {
+	b := bound{}
+	if strings.HasPrefix(s, "(") {
+		b.exclusive = true
+		s = s[1:]
+	}
+	switch s {
+	case "-inf":
+		b.v = math.Inf(-1)
+	case "+inf", "inf":
+		b.v = math.Inf(1)
+	default:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return b, errors.New("ERR min or max is not a float")
+		}
+		b.v = v
+	}
+	return b, nil
+}
+
+func (b bound) above(score float64) bool {
+	if b.exclusive {
+		return score > b.v
+	}
+	return score >= b.v
+}
+
+func (b bound) below(score float64) bool {
+	if b.exclusive {
+		return score < b.v
+	}
+	return score <= b.v
+}
+
+// readCommand 读取一条RESP数组格式的命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, errors.New("invalid multibulk length")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errors.New("expected bulk string")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case error:
+		msg := v.Error()
+		if !strings.HasPrefix(msg, "ERR ") && !strings.HasPrefix(msg, "NOSCRIPT ") {
+			msg = "ERR " + msg
+		}
+		w.WriteString("-" + msg + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		writeReply(w, fmt.Errorf("unsupported reply %T", v))
+	}
+}
@@ -0,0 +1,71 @@
+// Package servercmd 和hbbs/hbbr的命令端口交互, 并把已知命令的输出解析为结构化数据
+package servercmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultIdle 收到数据后超过这个时间没有新数据视为输出结束, 兼容不主动关闭连接的版本
+const DefaultIdle = 300 * time.Millisecond
+
+// Exchange 发送命令并读取全部输出, 直到对方关闭连接、空闲超过 idle 或者 ctx 结束
+// ctx 结束时返回已读取的部分和 ctx.Err()
+func Exchange(ctx context.Context, conn net.Conn, cmd string, idle time.Duration) (string, error) {
+	stop := context.AfterFunc(ctx, func() {
+		// 打断阻塞中的读写
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	if d, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(d)
+	}
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", err
+	}
+	return readAll(ctx, conn, idle)
+}
+
+func readAll(ctx context.Context, conn net.Conn, idle time.Duration) (string, error) {
+	var buf bytes.Buffer
+	chunk := make([]byte, 4096)
+	for {
+		if err := ctx.Err(); err != nil {
+			return buf.String(), err
+		}
+		var deadline time.Time
+		if d, ok := ctx.Deadline(); ok {
+			deadline = d
+		}
+		if buf.Len() > 0 && idle > 0 {
+			if d := time.Now().Add(idle); deadline.IsZero() || d.Before(deadline) {
+				deadline = d
+			}
+		}
+		_ = conn.SetReadDeadline(deadline)
+		n, err := conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return buf.String(), ctx.Err()
+		}
+		if errors.Is(err, io.EOF) {
+			return buf.String(), nil
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() && buf.Len() > 0 {
+			// 空闲超时, 输出已经结束
+			return buf.String(), nil
+		}
+		return buf.String(), err
+	}
+}
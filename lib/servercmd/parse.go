@@ -0,0 +1,218 @@
+package servercmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// aliases 命令的别名, 注意 b 和 B 是不同的命令
+var aliases = map[string]string{
+	"u":  "usage",
+	"ib": "ip-blocker",
+	"ic": "ip-changes",
+	"rs": "relay-servers",
+	"b":  "blacklist",
+	"B":  "blocklist",
+}
+
+// Canonical 把别名转为完整的命令名
+func Canonical(cmd string) string {
+	if c, ok := aliases[cmd]; ok {
+		return c
+	}
+	return cmd
+}
+
+// Output 解析后的输出, Data 的类型由命令决定
+type Output struct {
+	Cmd      string      `json:"cmd"`
+	Data     interface{} `json:"data"`
+	Unparsed []string    `json:"unparsed,omitempty"` // 无法解析的行
+}
+
+// UsageItem hbbr usage 的一行, 格式为 "<key>: <elapsed>s <total>MB <highest>kb/s <avg>kb/s <speed>kb/s"
+type UsageItem struct {
+	Key         string  `json:"key"`     // 连接的标识, 一般为 ip:port
+	Elapsed     int64   `json:"elapsed"` // 秒
+	TotalMB     float64 `json:"total_mb"`
+	HighestKbps int64   `json:"highest_kbps"`
+	AvgKbps     int64   `json:"avg_kbps"`
+	SpeedKbps   int64   `json:"speed_kbps"`
+}
+
+// IpCheck blacklist/blocklist <ip> 的结果
+type IpCheck struct {
+	Ip     string `json:"ip"`
+	Listed bool   `json:"listed"`
+}
+
+type IpBlockerItem struct {
+	Ip    string   `json:"ip"`
+	Count int64    `json:"count"` // 注册失败的次数
+	Ids   []string `json:"ids"`   // 这个ip注册过的id
+}
+
+// IpBlocker hbbs ip-blocker 的结果, Total 为被记录的ip总数, 每次最多列出10个
+type IpBlocker struct {
+	Total    int             `json:"total"`
+	Items    []IpBlockerItem `json:"items"`
+	NotFound []string        `json:"not_found,omitempty"`
+}
+
+type IpChangesItem struct {
+	Id      string           `json:"id"`
+	Elapsed int64            `json:"elapsed"` // 秒
+	Ips     map[string]int64 `json:"ips"`
+}
+
+// IpChanges hbbs ip-changes 的结果, Total 为记录的id总数, 每次最多列出10个
+type IpChanges struct {
+	Total    int             `json:"total"`
+	Items    []IpChangesItem `json:"items"`
+	NotFound []string        `json:"not_found,omitempty"`
+}
+
+var (
+	usageRe     = regexp.MustCompile(`^(.+): (\d+)s ([\d.]+)MB (\d+)kb/s (\d+)kb/s (\d+)kb/s$`)
+	ipBlockerRe = regexp.MustCompile(`^(\S+) \((\d+), .*?\) \(\{(.*?)\}, .*\)$`)
+	ipChangesRe = regexp.MustCompile(`^(?:(\S+) )?(\d+)s \{(.*)\}$`)
+	notFoundRe  = regexp.MustCompile(`^(\S+) not found$`)
+)
+
+// Parse 解析已知命令的输出, arg 为命令的参数; 未知的命令返回nil
+// 带参数的设置类命令(如 relay-servers <list>)没有输出时 Data 为nil
+func Parse(cmd, arg, raw string) *Output {
+	out := &Output{Cmd: Canonical(strings.TrimSpace(cmd))}
+	arg = strings.TrimSpace(arg)
+	lines := splitLines(raw)
+	switch out.Cmd {
+	case "usage":
+		items := []UsageItem{}
+		for _, l := range lines {
+			m := usageRe.FindStringSubmatch(l)
+			if m == nil {
+				out.Unparsed = append(out.Unparsed, l)
+				continue
+			}
+			it := UsageItem{Key: m[1]}
+			it.Elapsed, _ = strconv.ParseInt(m[2], 10, 64)
+			it.TotalMB, _ = strconv.ParseFloat(m[3], 64)
+			it.HighestKbps, _ = strconv.ParseInt(m[4], 10, 64)
+			it.AvgKbps, _ = strconv.ParseInt(m[5], 10, 64)
+			it.SpeedKbps, _ = strconv.ParseInt(m[6], 10, 64)
+			items = append(items, it)
+		}
+		out.Data = items
+	case "relay-servers", "blacklist", "blocklist":
+		if arg != "" {
+			// relay-servers <list> 是设置, 没有输出; blacklist <ip> 输出 true/false
+			if len(lines) == 1 && (lines[0] == "true" || lines[0] == "false") {
+				out.Data = IpCheck{Ip: arg, Listed: lines[0] == "true"}
+			} else {
+				out.Unparsed = lines
+			}
+			break
+		}
+		if lines == nil {
+			lines = []string{}
+		}
+		out.Data = lines
+	case "ip-blocker":
+		res := IpBlocker{Items: []IpBlockerItem{}}
+		rest := parseTotal(lines, &res.Total)
+		for _, l := range rest {
+			if m := notFoundRe.FindStringSubmatch(l); m != nil {
+				res.NotFound = append(res.NotFound, m[1])
+				continue
+			}
+			m := ipBlockerRe.FindStringSubmatch(l)
+			if m == nil {
+				out.Unparsed = append(out.Unparsed, l)
+				continue
+			}
+			it := IpBlockerItem{Ip: m[1], Ids: parseStringSet(m[3])}
+			it.Count, _ = strconv.ParseInt(m[2], 10, 64)
+			res.Items = append(res.Items, it)
+		}
+		out.Data = res
+	case "ip-changes":
+		res := IpChanges{Items: []IpChangesItem{}}
+		rest := parseTotal(lines, &res.Total)
+		for _, l := range rest {
+			if m := notFoundRe.FindStringSubmatch(l); m != nil {
+				res.NotFound = append(res.NotFound, m[1])
+				continue
+			}
+			m := ipChangesRe.FindStringSubmatch(l)
+			if m == nil {
+				out.Unparsed = append(out.Unparsed, l)
+				continue
+			}
+			// 查询单个id时输出中没有id
+			it := IpChangesItem{Id: m[1], Ips: parseIntMap(m[3])}
+			if it.Id == "" {
+				it.Id = arg
+			}
+			it.Elapsed, _ = strconv.ParseInt(m[2], 10, 64)
+			res.Items = append(res.Items, it)
+		}
+		out.Data = res
+	default:
+		return nil
+	}
+	return out
+}
+
+func splitLines(raw string) []string {
+	var res []string
+	for _, l := range strings.Split(raw, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// parseTotal 第一行为总数
+func parseTotal(lines []string, total *int) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return lines
+	}
+	*total = n
+	return lines[1:]
+}
+
+// parseStringSet 解析rust的HashSet<String>, 如 "a", "b"
+func parseStringSet(s string) []string {
+	res := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.Trim(strings.TrimSpace(v), `"`); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// parseIntMap 解析rust的HashMap<String, i32>, 如 "1.2.3.4": 1, "5.6.7.8": 2
+func parseIntMap(s string) map[string]int64 {
+	res := map[string]int64{}
+	for _, kv := range strings.Split(s, ",") {
+		// ipv6的key中也有冒号, 取最后一个
+		i := strings.LastIndex(kv, ":")
+		if i < 0 {
+			continue
+		}
+		k := kv[:i]
+		n, err := strconv.ParseInt(strings.TrimSpace(kv[i+1:]), 10, 64)
+		if err != nil {
+			continue
+		}
+		res[strings.Trim(strings.TrimSpace(k), `"`)] = n
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package servercmd
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUsage(t *testing.T) {
+	raw := "1.2.3.4:5678: 12s 1.50MB 800kb/s 120kb/s 0kb/s\n[::1]:80: 3s 0.01MB 10kb/s 5kb/s 5kb/s\ngarbage\n"
+	out := Parse("u", "", raw)
+	items := out.Data.([]UsageItem)
+	if len(items) != 2 {
+		t.Fatalf("items = %+v", items)
+	}
+	want := UsageItem{Key: "1.2.3.4:5678", Elapsed: 12, TotalMB: 1.5, HighestKbps: 800, AvgKbps: 120, SpeedKbps: 0}
+	if items[0] != want {
+		t.Errorf("items[0] = %+v", items[0])
+	}
+	if items[1].Key != "[::1]:80" {
+		t.Errorf("items[1].Key = %s", items[1].Key)
+	}
+	if !reflect.DeepEqual(out.Unparsed, []string{"garbage"}) {
+		t.Errorf("unparsed = %v", out.Unparsed)
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	if out := Parse("rs", "", "a.example.com\nb.example.com\n"); !reflect.DeepEqual(out.Data, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("relay-servers = %v", out.Data)
+	}
+	if out := Parse("B", "", ""); out.Cmd != "blocklist" || !reflect.DeepEqual(out.Data, []string{}) {
+		t.Errorf("blocklist = %+v", out)
+	}
+	if out := Parse("blacklist", "1.2.3.4", "true\n"); out.Data != (IpCheck{Ip: "1.2.3.4", Listed: true}) {
+		t.Errorf("blacklist <ip> = %+v", out.Data)
+	}
+	if out := Parse("rs", "a,b", ""); out.Data != nil || out.Unparsed != nil {
+		t.Errorf("relay-servers <list> = %+v", out)
+	}
+	if Parse("h", "", "help") != nil {
+		t.Error("unknown command parsed")
+	}
+}
+
+func TestParseIpBlocker(t *testing.T) {
+	raw := "2\n" +
+		`1.2.3.4 (3, Instant { tv_sec: 1, tv_nsec: 2 }) ({"123456", "654321"}, Instant { tv_sec: 1, tv_nsec: 2 })` + "\n" +
+		`5.6.7.8 (0, Instant { tv_sec: 1, tv_nsec: 2 }) ({}, Instant { tv_sec: 1, tv_nsec: 2 })` + "\n" +
+		"9.9.9.9 not found\n"
+	res := Parse("ib", "", raw).Data.(IpBlocker)
+	if res.Total != 2 || len(res.Items) != 2 {
+		t.Fatalf("res = %+v", res)
+	}
+	if res.Items[0].Count != 3 || !reflect.DeepEqual(res.Items[0].Ids, []string{"123456", "654321"}) {
+		t.Errorf("items[0] = %+v", res.Items[0])
+	}
+	if len(res.Items[1].Ids) != 0 || !reflect.DeepEqual(res.NotFound, []string{"9.9.9.9"}) {
+		t.Errorf("res = %+v", res)
+	}
+}
+
+func TestParseIpChanges(t *testing.T) {
+	raw := "1\n" + `123456 30s {"1.2.3.4": 2, "::1": 1}` + "\n"
+	res := Parse("ic", "", raw).Data.(IpChanges)
+	if res.Total != 1 || len(res.Items) != 1 {
+		t.Fatalf("res = %+v", res)
+	}
+	want := IpChangesItem{Id: "123456", Elapsed: 30, Ips: map[string]int64{"1.2.3.4": 2, "::1": 1}}
+	if !reflect.DeepEqual(res.Items[0], want) {
+		t.Errorf("items[0] = %+v", res.Items[0])
+	}
+	one := Parse("ic", "123456", "1\n"+`5s {"1.2.3.4": 1}`).Data.(IpChanges)
+	if one.Items[0].Id != "123456" || one.Items[0].Elapsed != 5 {
+		t.Errorf("single = %+v", one)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	server, client := net.Pipe()
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := server.Read(buf)
+		if string(buf[:n]) == "usage " {
+			server.Write([]byte(long[:5000]))
+			server.Write([]byte(long[5000:]))
+		}
+		server.Close()
+	}()
+	res, err := Exchange(context.Background(), client, "usage ", DefaultIdle)
+	if err != nil || res != long {
+		t.Errorf("len = %d, err = %v", len(res), err)
+	}
+
+	// 不关闭连接也不输出时在 ctx 结束后返回
+	server, client = net.Pipe()
+	go func() {
+		buf := make([]byte, 64)
+		server.Read(buf)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Exchange(ctx, client, "h", DefaultIdle); err != context.DeadlineExceeded {
+		t.Errorf("err = %v", err)
+	}
+}
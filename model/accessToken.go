@@ -0,0 +1,62 @@
+package model
+
+import "strings"
+
+// AccessTokenPrefix 个人访问令牌的前缀, 用于和登录token区分
+const AccessTokenPrefix = "rdpat_"
+
+const (
+	ScopePeersRead  = "peers:read"
+	ScopePeersWrite = "peers:write"
+	ScopeAbRead     = "ab:read"
+	ScopeAbWrite    = "ab:write"
+	ScopeAuditRead  = "audit:read"
+	ScopeAdminAll   = "admin:*" // 等同于用户本身的全部权限, 只有管理员可以创建
+)
+
+// AccessTokenScopes 所有可用的scope
+var AccessTokenScopes = []string{ScopePeersRead, ScopePeersWrite, ScopeAbRead, ScopeAbWrite, ScopeAuditRead, ScopeAdminAll}
+
+// AccessToken 个人访问令牌, 用于脚本等自动化场景, 只保存token的hash
+type AccessToken struct {
+	IdModel
+	UserId     uint   `json:"user_id" gorm:"default:0;not null;index"`
+	Name       string `json:"name" gorm:"default:'';not null;"`
+	TokenHash  string `json:"-" gorm:"default:'';not null;uniqueIndex;size:64"`
+	Prefix     string `json:"prefix" gorm:"default:'';not null;"`    // token的前几位, 方便识别
+	Scopes     string `json:"scopes" gorm:"default:'';not null;"`    // 逗号分隔
+	ExpiredAt  int64  `json:"expired_at" gorm:"default:0;not null;"` // 0 为永不过期
+	LastUsedAt int64  `json:"last_used_at" gorm:"default:0;not null;"`
+	LastUsedIp string `json:"last_used_ip" gorm:"default:'';not null;"`
+	TimeModel
+}
+
+// ScopeList 返回scope列表
+func (at *AccessToken) ScopeList() []string {
+	var res []string
+	for _, s := range strings.Split(at.Scopes, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// HasScope admin:* 包含所有scope, xx:write 包含 xx:read
+func (at *AccessToken) HasScope(scope string) bool {
+	for _, s := range at.ScopeList() {
+		if s == scope || s == ScopeAdminAll {
+			return true
+		}
+		if strings.HasSuffix(s, ":write") && strings.TrimSuffix(s, ":write")+":read" == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type AccessTokenList struct {
+	AccessTokens []*AccessToken `json:"list"`
+	Pagination
+}
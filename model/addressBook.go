@@ -1,6 +1,12 @@
 package model
 
-import "github.com/lejianwen/rustdesk-api/v2/model/custom_types"
+import (
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model/custom_types"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // final String id;
 // String hash; // personal ab hash password
@@ -22,7 +28,7 @@ type AddressBook struct {
 	RowId            uint                   `gorm:"primaryKey" json:"row_id"`
 	Id               string                 `json:"id" gorm:"default:0;not null;index"`
 	Username         string                 `json:"username" gorm:"default:'';not null;"`
-	Password         string                 `json:"password" gorm:"default:'';not null;"`
+	Password         string                 `json:"password" gorm:"default:'';not null;size:512;serializer:encrypted"`
 	Hostname         string                 `json:"hostname" gorm:"default:'';not null;"`
 	Alias            string                 `json:"alias" gorm:"default:'';not null;"`
 	Platform         string                 `json:"platform" gorm:"default:'';not null;"`
@@ -47,10 +53,31 @@ type AddressBookList struct {
 
 type AddressBookCollection struct {
 	IdModel
-	UserId uint   `json:"user_id" gorm:"default:0;not null;index"`
-	Name   string `json:"name" gorm:"default:'';not null;" validate:"required"`
+	UserId uint                    `json:"user_id" gorm:"default:0;not null;index"`
+	Name   string                  `json:"name" gorm:"default:'';not null;" validate:"required"`
+	Smart  *AddressBookSmartFilter `json:"smart,omitempty" gorm:"type:text;serializer:json"` // 不为空时是智能地址簿, 内容由筛选条件生成
+	Auto   int                     `json:"auto" gorm:"default:0;not null;"`                  // 系统维护的地址簿, 见 AddressBookCollectionAuto*
 	TimeModel
 }
+
+const (
+	AddressBookCollectionAutoMyDevices = 1 // 我的设备
+)
+
+// IsSmart 是否智能地址簿
+func (c *AddressBookCollection) IsSmart() bool {
+	return c != nil && c.Smart != nil
+}
+
+// AddressBookSmartFilter 智能地址簿的筛选条件, 为空的条件不筛选
+type AddressBookSmartFilter struct {
+	DeviceGroupIds []uint   `json:"device_group_ids"`
+	Os             string   `json:"os"`            // 包含即可, 如 linux
+	UserIds        []uint   `json:"user_ids"`      // 设备所属用户
+	Hostname       string   `json:"hostname"`      // 支持通配符 * 和 ?, 如 dc2-*
+	OnlineWithin   int64    `json:"online_within"` // 秒, 在这段时间内在线过
+	Tags           []string `json:"tags"`          // 所有者默认地址簿中的标签, 有其中一个即可
+}
 type AddressBookCollectionList struct {
 	AddressBookCollection []*AddressBookCollection `json:"list"`
 	Pagination
@@ -62,6 +89,14 @@ type AddressBookCollectionRule struct {
 	Rule         int  `json:"rule" gorm:"default:0;not null;" validate:"required,gte=1,lte=3"` // 0: 无 1: 读 2: 读写  3: 完全控制
 	Type         int  `json:"type" gorm:"default:1;not null;" validate:"required,gte=1,lte=2"` // 1: 个人 2: 群组
 	ToId         uint `json:"to_id" gorm:"default:0;not null;" validate:"required,gt=0"`
+	// 生效时间, 为0不限制, 过期的规则会被定时删除
+	ValidFrom  int64 `json:"valid_from" gorm:"default:0;not null;"`
+	ValidUntil int64 `json:"valid_until" gorm:"default:0;not null;index"`
+	// 每周的生效时段, 都为空时不限制; 结束时间小于开始时间表示跨天, 如 22:00-06:00
+	Weekdays  string `json:"weekdays" gorm:"default:'';not null;"`   // 逗号分隔, 0或7为周日, 为空表示每天, 如 1,2,3,4,5
+	StartTime string `json:"start_time" gorm:"default:'';not null;"` // HH:MM, 为空表示 00:00
+	EndTime   string `json:"end_time" gorm:"default:'';not null;"`   // HH:MM, 为空表示 24:00
+	Timezone  string `json:"timezone" gorm:"default:'';not null;"`   // 时段的时区, 如 Asia/Shanghai, 为空使用服务器时区
 	TimeModel
 }
 type AddressBookCollectionRuleList struct {
@@ -78,3 +113,125 @@ const (
 	ShareAddressBookRuleRuleReadWrite   = 2
 	ShareAddressBookRuleRuleFullControl = 3
 )
+
+// HasWindow 是否设置了每周的生效时段
+func (r *AddressBookCollectionRule) HasWindow() bool {
+	return r.Weekdays != "" || r.StartTime != "" || r.EndTime != ""
+}
+
+// CheckSchedule 检查生效时间的设置
+func (r *AddressBookCollectionRule) CheckSchedule() error {
+	if r.ValidFrom < 0 || r.ValidUntil < 0 {
+		return errors.New("invalid valid_from or valid_until")
+	}
+	if r.ValidFrom > 0 && r.ValidUntil > 0 && r.ValidUntil <= r.ValidFrom {
+		return errors.New("valid_until must be after valid_from")
+	}
+	if _, err := r.location(); err != nil {
+		return err
+	}
+	if _, err := r.weekdays(); err != nil {
+		return err
+	}
+	start, end, err := r.window()
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return errors.New("start_time and end_time can not be the same")
+	}
+	return nil
+}
+
+// Active 规则在 t 时是否生效
+func (r *AddressBookCollectionRule) Active(t time.Time) bool {
+	if r.ValidFrom > 0 && t.Unix() < r.ValidFrom {
+		return false
+	}
+	if r.ValidUntil > 0 && t.Unix() >= r.ValidUntil {
+		return false
+	}
+	if !r.HasWindow() {
+		return true
+	}
+	loc, err := r.location()
+	if err != nil {
+		return false
+	}
+	days, err := r.weekdays()
+	if err != nil {
+		return false
+	}
+	start, end, err := r.window()
+	if err != nil {
+		return false
+	}
+	lt := t.In(loc)
+	now := lt.Hour()*60 + lt.Minute()
+	day := int(lt.Weekday())
+	if start < end {
+		return days[day] && now >= start && now < end
+	}
+	// 跨天, 凌晨的部分属于前一天的时段
+	if now >= start {
+		return days[day]
+	}
+	if now < end {
+		return days[(day+6)%7]
+	}
+	return false
+}
+
+func (r *AddressBookCollectionRule) location() (*time.Location, error) {
+	if r.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+// weekdays 生效的星期, 下标为 time.Weekday
+func (r *AddressBookCollectionRule) weekdays() ([7]bool, error) {
+	var days [7]bool
+	if strings.TrimSpace(r.Weekdays) == "" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	for _, d := range strings.Split(r.Weekdays, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(d))
+		if err != nil || n < 0 || n > 7 {
+			return days, errors.New("invalid weekdays: " + r.Weekdays)
+		}
+		days[n%7] = true
+	}
+	return days, nil
+}
+
+// window 每天生效时段的开始和结束, 单位为分钟
+func (r *AddressBookCollectionRule) window() (start, end int, err error) {
+	start, end = 0, 24*60
+	if r.StartTime != "" {
+		if start, err = parseClock(r.StartTime); err != nil {
+			return
+		}
+	}
+	if r.EndTime != "" {
+		end, err = parseClock(r.EndTime)
+	}
+	return
+}
+
+// parseClock 解析 HH:MM, 返回分钟数
+func parseClock(s string) (int, error) {
+	t := strings.Split(strings.TrimSpace(s), ":")
+	if len(t) != 2 {
+		return 0, errors.New("invalid time: " + s)
+	}
+	h, err1 := strconv.Atoi(t[0])
+	m, err2 := strconv.Atoi(t[1])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m > 0) {
+		return 0, errors.New("invalid time: " + s)
+	}
+	return h*60 + m, nil
+}
@@ -0,0 +1,67 @@
+package model
+
+// 地址簿批量操作
+const (
+	AddressBookBulkMove                = "move"                   // 移动到其他地址簿
+	AddressBookBulkCopy                = "copy"                   // 复制到其他地址簿
+	AddressBookBulkAddTags             = "add_tags"               // 添加标签
+	AddressBookBulkRemoveTags          = "remove_tags"            // 移除标签
+	AddressBookBulkSetAlias            = "set_alias"              // 按模板设置别名
+	AddressBookBulkSetForceAlwaysRelay = "set_force_always_relay" // 设置强制中继
+	AddressBookBulkSetRdpPort          = "set_rdp_port"
+	AddressBookBulkSetRdpUsername      = "set_rdp_username"
+	AddressBookBulkDelete              = "delete"
+)
+
+// 单个条目的处理结果
+const (
+	AddressBookBulkOk      = "ok"
+	AddressBookBulkSkipped = "skipped" // 无需修改, 如已经在目标地址簿中
+	AddressBookBulkFailed  = "failed"
+)
+
+// AddressBookBulkSelector 选择要操作的条目, 条件之间为且
+type AddressBookBulkSelector struct {
+	RowIds       []uint `json:"row_ids"`
+	CollectionId *uint  `json:"collection_id"` // 0为默认地址簿, 为空时不限制
+	Tag          string `json:"tag"`
+	Id           string `json:"id"`       // 模糊匹配
+	Hostname     string `json:"hostname"` // 支持通配符 * ?, 没有通配符时模糊匹配
+	Username     string `json:"username"` // 模糊匹配
+	Alias        string `json:"alias"`    // 模糊匹配
+}
+
+// Empty 没有任何条件, 避免误操作全部条目
+func (s *AddressBookBulkSelector) Empty() bool {
+	return len(s.RowIds) == 0 && s.CollectionId == nil && s.Tag == "" &&
+		s.Id == "" && s.Hostname == "" && s.Username == "" && s.Alias == ""
+}
+
+// AddressBookBulkAction 批量操作及其参数, 只使用和 Action 对应的参数
+type AddressBookBulkAction struct {
+	Action           string   `json:"action"`
+	CollectionId     uint     `json:"target_collection_id"` // move/copy 的目标地址簿, 0为默认地址簿
+	Tags             []string `json:"tags"`                 // add_tags/remove_tags
+	Alias            string   `json:"alias"`                // set_alias, 支持 {id} {hostname} {username} {platform} {alias} {n}
+	ForceAlwaysRelay bool     `json:"force_always_relay"`
+	RdpPort          string   `json:"rdp_port"`
+	RdpUsername      string   `json:"rdp_username"`
+}
+
+type AddressBookBulkItem struct {
+	RowId    uint   `json:"row_id"`
+	Id       string `json:"id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	NewRowId uint   `json:"new_row_id,omitempty"` // copy 创建的条目
+}
+
+// AddressBookBulkResult 有失败的条目时整体回滚, Applied 为false
+type AddressBookBulkResult struct {
+	Applied bool                   `json:"applied"`
+	Total   int                    `json:"total"`
+	Ok      int                    `json:"ok"`
+	Skipped int                    `json:"skipped"`
+	Failed  int                    `json:"failed"`
+	Items   []*AddressBookBulkItem `json:"items"`
+}
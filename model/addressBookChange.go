@@ -0,0 +1,44 @@
+package model
+
+// 修改历史的对象
+const (
+	AddressBookChangeTargetPeer       = "peer"
+	AddressBookChangeTargetTag        = "tag"
+	AddressBookChangeTargetCollection = "collection"
+)
+
+// 修改历史的动作
+const (
+	AddressBookChangeCreate = "create"
+	AddressBookChangeUpdate = "update"
+	AddressBookChangeDelete = "delete"
+)
+
+// AddressBookChange 地址簿修改历史, 只追加不修改
+// Before/After 为修改前后的json, 新增时 Before 为空, 删除时 After 为空
+// 删除地址簿时 Before 为 AddressBookCollectionState
+type AddressBookChange struct {
+	IdModel
+	UserId       uint   `json:"user_id" gorm:"default:0;not null;index:idx_ab_change"` // 地址簿所有者
+	CollectionId uint   `json:"collection_id" gorm:"default:0;not null;index:idx_ab_change"`
+	OperatorId   uint   `json:"operator_id" gorm:"default:0;not null;"` // 0 为系统
+	Operator     string `json:"operator" gorm:"default:'';not null;"`
+	Target       string `json:"target" gorm:"default:'';not null;"`
+	TargetId     string `json:"target_id" gorm:"default:'';not null;"` // 设备id/标签名/地址簿id
+	Action       string `json:"action" gorm:"default:'';not null;"`
+	Before       string `json:"before" gorm:"type:text;serializer:encrypted"`
+	After        string `json:"after" gorm:"type:text;serializer:encrypted"`
+	TimeModel
+}
+
+type AddressBookChangeList struct {
+	AddressBookChanges []*AddressBookChange `json:"list"`
+	Pagination
+}
+
+// AddressBookCollectionState 删除地址簿时保存的完整内容, 用于恢复
+type AddressBookCollectionState struct {
+	Collection *AddressBookCollection       `json:"collection"`
+	Rules      []*AddressBookCollectionRule `json:"rules"`
+	AddressBookExport
+}
@@ -0,0 +1,27 @@
+package model
+
+// AddressBookExport 地址簿导入导出格式, 和客户端上传的 AddressBookFormData 一致
+type AddressBookExport struct {
+	Tags      []string       `json:"tags"`
+	Peers     []*AddressBook `json:"peers"`
+	TagColors string         `json:"tag_colors"` // json, 如 {"tag1":4288585374}
+}
+
+// 导入时已存在的设备的处理方式
+const (
+	AddressBookImportSkip      = "skip"      // 跳过
+	AddressBookImportOverwrite = "overwrite" // 覆盖
+	AddressBookImportMerge     = "merge"     // 保留原有信息, 合并标签
+)
+
+const (
+	AddressBookExportJson = "json"
+	AddressBookExportCsv  = "csv"
+)
+
+type AddressBookImportResult struct {
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Skipped     int `json:"skipped"`
+	TagsCreated int `json:"tags_created"`
+}
@@ -0,0 +1,40 @@
+package model
+
+// 邀请的状态
+const (
+	AddressBookInvitationPending  = "pending"
+	AddressBookInvitationAccepted = "accepted"
+	AddressBookInvitationExpired  = "expired"
+)
+
+// AddressBookInvitation 通过邮件邀请外部用户共享地址簿, 接受后为其创建个人共享规则, 只保存token的hash
+type AddressBookInvitation struct {
+	IdModel
+	UserId         uint   `json:"user_id" gorm:"default:0;not null;index"` // 邀请人, 即地址簿所有者
+	CollectionId   uint   `json:"collection_id" gorm:"default:0;not null;index"`
+	Email          string `json:"email" gorm:"default:'';not null;index"`
+	Rule           int    `json:"rule" gorm:"default:0;not null;"`
+	TokenHash      string `json:"-" gorm:"default:'';not null;uniqueIndex;size:64"`
+	ExpiredAt      int64  `json:"expired_at" gorm:"default:0;not null;"`
+	AcceptedAt     int64  `json:"accepted_at" gorm:"default:0;not null;"`
+	AcceptedUserId uint   `json:"accepted_user_id" gorm:"default:0;not null;"`
+	SentAt         int64  `json:"sent_at" gorm:"default:0;not null;"` // 最后一次发送邮件的时间, 0表示未发送
+	Status         string `json:"status" gorm:"-"`
+	TimeModel
+}
+
+// StatusAt 邀请在 now 时的状态
+func (i *AddressBookInvitation) StatusAt(now int64) string {
+	if i.AcceptedAt > 0 {
+		return AddressBookInvitationAccepted
+	}
+	if i.ExpiredAt > 0 && now >= i.ExpiredAt {
+		return AddressBookInvitationExpired
+	}
+	return AddressBookInvitationPending
+}
+
+type AddressBookInvitationList struct {
+	AddressBookInvitations []*AddressBookInvitation `json:"list"`
+	Pagination
+}
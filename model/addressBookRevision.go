@@ -0,0 +1,20 @@
+package model
+
+// AddressBookRevision 地址簿(用户+collection)的版本号, 每次修改加一, 用于乐观锁
+type AddressBookRevision struct {
+	IdModel
+	UserId       uint  `json:"user_id" gorm:"default:0;not null;uniqueIndex:idx_ab_revision"`
+	CollectionId uint  `json:"collection_id" gorm:"default:0;not null;uniqueIndex:idx_ab_revision"`
+	Revision     int64 `json:"revision" gorm:"default:0;not null;"`
+	TimeModel
+}
+
+// AddressBookSnapshot 地址簿某个版本的快照, 用于三方合并, 不包含密码
+type AddressBookSnapshot struct {
+	IdModel
+	UserId       uint   `json:"user_id" gorm:"default:0;not null;index:idx_ab_snapshot"`
+	CollectionId uint   `json:"collection_id" gorm:"default:0;not null;index:idx_ab_snapshot"`
+	Revision     int64  `json:"revision" gorm:"default:0;not null;index:idx_ab_snapshot"`
+	Data         string `json:"data" gorm:"type:text;not null;"` // AddressBookExport json
+	TimeModel
+}
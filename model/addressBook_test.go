@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddressBookCollectionRuleActive(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+	at := func(s string) time.Time {
+		tm, _ := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		return tm
+	}
+	// 2024-01-01 是周一
+	workday := &AddressBookCollectionRule{Weekdays: "1,2,3,4,5", StartTime: "08:00", EndTime: "18:00", Timezone: "Asia/Shanghai"}
+	night := &AddressBookCollectionRule{Weekdays: "5", StartTime: "22:00", EndTime: "06:00", Timezone: "Asia/Shanghai"}
+	bounded := &AddressBookCollectionRule{ValidFrom: at("2024-01-01 00:00").Unix(), ValidUntil: at("2024-01-02 00:00").Unix()}
+	cases := []struct {
+		r    *AddressBookCollectionRule
+		t    string
+		want bool
+	}{
+		{workday, "2024-01-01 08:00", true},
+		{workday, "2024-01-01 17:59", true},
+		{workday, "2024-01-01 18:00", false},
+		{workday, "2024-01-01 07:59", false},
+		{workday, "2024-01-06 10:00", false}, // 周六
+		{night, "2024-01-05 23:00", true},    // 周五晚上
+		{night, "2024-01-06 05:00", true},    // 周五的时段延续到周六凌晨
+		{night, "2024-01-06 23:00", false},
+		{night, "2024-01-05 05:00", false}, // 周四的时段
+		{bounded, "2023-12-31 23:59", false},
+		{bounded, "2024-01-01 12:00", true},
+		{bounded, "2024-01-02 00:00", false},
+		{&AddressBookCollectionRule{}, "2024-01-01 12:00", true},
+	}
+	for i, c := range cases {
+		if got := c.r.Active(at(c.t)); got != c.want {
+			t.Errorf("case %d: Active(%s) = %v, want %v", i, c.t, got, c.want)
+		}
+	}
+	// 时区不同时按规则的时区计算
+	if !workday.Active(at("2024-01-01 09:00").UTC()) {
+		t.Error("timezone not applied")
+	}
+}
+
+func TestAddressBookCollectionRuleCheckSchedule(t *testing.T) {
+	bad := []*AddressBookCollectionRule{
+		{ValidFrom: 10, ValidUntil: 5},
+		{Weekdays: "1,8"},
+		{Weekdays: "mon"},
+		{StartTime: "8"},
+		{StartTime: "25:00"},
+		{StartTime: "08:00", EndTime: "08:00"},
+		{Timezone: "Nowhere/City"},
+	}
+	for i, r := range bad {
+		if r.CheckSchedule() == nil {
+			t.Errorf("case %d: want error", i)
+		}
+	}
+	good := []*AddressBookCollectionRule{
+		{},
+		{ValidUntil: 5},
+		{Weekdays: "0,6,7", StartTime: "22:00", EndTime: "06:00"},
+		{StartTime: "00:00", EndTime: "24:00", Timezone: "UTC"},
+	}
+	for i, r := range good {
+		if err := r.CheckSchedule(); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+// AdminAuditLog 后台的操作记录, 记录 /api/admin 下所有修改类的请求
+// Request 和 Changes 中的密码、密钥、token等字段已脱敏
+type AdminAuditLog struct {
+	IdModel
+	UserId   uint   `json:"user_id" gorm:"default:0;not null;index"`
+	Username string `json:"username" gorm:"default:'';not null;"`
+	Ip       string `json:"ip" gorm:"default:'';not null;"`
+	Method   string `json:"method" gorm:"default:'';not null;"`
+	Route    string `json:"route" gorm:"default:'';not null;index"` // 路由, 如 /api/admin/oauth/update
+	Path     string `json:"path" gorm:"default:'';not null;"`
+	Target   string `json:"target" gorm:"default:'';not null;index:idx_admin_audit_target"` // 操作的对象类型, 如 oauth
+	TargetId string `json:"target_id" gorm:"default:'';not null;index:idx_admin_audit_target"`
+	Request  string `json:"request" gorm:"type:text"`
+	Changes  string `json:"changes" gorm:"type:text"`        // json格式的 []AdminAuditChange
+	Code     int    `json:"code" gorm:"default:0;not null;"` // 响应中的code, 0为成功
+	TimeModel
+}
+
+type AdminAuditLogList struct {
+	AdminAuditLogs []*AdminAuditLog `json:"list"`
+	Pagination
+}
+
+// AdminAuditChange 对象的一个字段修改前后的值, 脱敏的字段只记录是否修改
+type AdminAuditChange struct {
+	Field    string      `json:"field"`
+	Before   interface{} `json:"before"`
+	After    interface{} `json:"after"`
+	Redacted bool        `json:"redacted,omitempty"`
+}
@@ -22,3 +22,20 @@ func (mt AutoTime) MarshalJSON() ([]byte, error) {
 	b := time.Time(mt).AppendFormat([]byte{}, "\"2006-01-02 15:04:05\"")
 	return b, nil
 }
+
+func (mt *AutoTime) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" || s == `""` {
+		return nil
+	}
+	t, err := time.ParseInLocation("\"2006-01-02 15:04:05\"", s, time.Local)
+	if err != nil {
+		// 兼容 RFC3339
+		t, err = time.Parse("\""+time.RFC3339+"\"", s)
+		if err != nil {
+			return err
+		}
+	}
+	*mt = AutoTime(t)
+	return nil
+}
@@ -7,8 +7,9 @@ const (
 
 type Group struct {
 	IdModel
-	Name string `json:"name" gorm:"default:'';not null;"`
-	Type int    `json:"type" gorm:"default:1;not null;"`
+	Name      string `json:"name" gorm:"default:'';not null;"`
+	Type      int    `json:"type" gorm:"default:1;not null;"`
+	MyDevices *bool  `json:"my_devices" gorm:"default:0;not null;"` // 组内用户自动维护"我的设备"地址簿
 	TimeModel
 }
 
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// IpRule 的生效范围
+const (
+	IpRuleScopeAll   = "all"   // api和hbbr
+	IpRuleScopeApi   = "api"   // 只在api的 middleware.Limiter 中拦截
+	IpRuleScopeRelay = "relay" // 只同步到hbbr的 blocklist
+)
+
+// IpRule 封禁的ip或网段
+// hbbr的 blocklist 只能匹配单个ip, 网段只在api中生效; hbbs没有封禁指令, 删除规则时会解除hbbs ip-blocker 的自动封禁
+type IpRule struct {
+	IdModel
+	Cidr      string `json:"cidr" gorm:"default:'';not null;uniqueIndex;size:64"` // ip或cidr, 如 1.2.3.4, 10.0.0.0/8
+	Scope     string `json:"scope" gorm:"default:'all';not null;"`
+	Reason    string `json:"reason" gorm:"default:'';not null;"`
+	ExpiresAt int64  `json:"expires_at" gorm:"default:0;not null;index"` // unix秒, 0为永久, 过期的规则会被定时删除
+	TimeModel
+}
+
+type IpRuleList struct {
+	IpRules []*IpRule `json:"list"`
+	Pagination
+}
+
+// IpRuleSyncResult 规则同步到hbbr/hbbs的结果
+type IpRuleSyncResult struct {
+	Relays    []*RelayCmdResult  `json:"relays"`
+	IdServers []*RelaySyncResult `json:"id_servers,omitempty"`
+}
+
+// ParseIpRule 解析ip或cidr, 单个ip返回 /32 或 /128 的网段; ipv4映射的ipv6地址按ipv4处理
+func ParseIpRule(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if p.Addr().Is4In6() && p.Bits() >= 96 {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// Check 检查并规范化 Cidr 和 Scope
+func (r *IpRule) Check() error {
+	p, err := ParseIpRule(r.Cidr)
+	if err != nil {
+		return err
+	}
+	if p.IsSingleIP() {
+		r.Cidr = p.Addr().String()
+	} else {
+		r.Cidr = p.String()
+	}
+	if r.Scope == "" {
+		r.Scope = IpRuleScopeAll
+	}
+	if r.Scope != IpRuleScopeAll && r.Scope != IpRuleScopeApi && r.Scope != IpRuleScopeRelay {
+		return errors.New("invalid scope")
+	}
+	if r.ExpiresAt < 0 {
+		return errors.New("invalid expires_at")
+	}
+	return nil
+}
+
+// Expired 在 t 时是否已经过期
+func (r *IpRule) Expired(t time.Time) bool {
+	return r.ExpiresAt > 0 && t.Unix() >= r.ExpiresAt
+}
+
+// InApi 是否在api中拦截
+func (r *IpRule) InApi() bool {
+	return r.Scope == IpRuleScopeAll || r.Scope == IpRuleScopeApi
+}
+
+// RelayIp 同步到hbbr blocklist 的ip, 网段或不同步到hbbr时返回空
+func (r *IpRule) RelayIp() string {
+	if r.Scope != IpRuleScopeAll && r.Scope != IpRuleScopeRelay {
+		return ""
+	}
+	p, err := ParseIpRule(r.Cidr)
+	if err != nil || !p.IsSingleIP() {
+		return ""
+	}
+	return p.Addr().String()
+}
+
+// LocksOut 规则是否会拦截 ip 的api请求, 匹配所有地址(/0)的规则总是返回true
+// 拦截是全局的, 用于防止管理员封禁自己后只能修改数据库恢复
+func (r *IpRule) LocksOut(ip string) bool {
+	if !r.InApi() {
+		return false
+	}
+	p, err := ParseIpRule(r.Cidr)
+	if err != nil {
+		return false
+	}
+	if p.Bits() == 0 {
+		return true
+	}
+	a, err := netip.ParseAddr(ip)
+	return err == nil && p.Contains(a.Unmap())
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIpRuleCheck(t *testing.T) {
+	cases := map[string]string{
+		"1.2.3.4":            "1.2.3.4",
+		" 10.1.2.3/8 ":       "10.0.0.0/8",
+		"::ffff:1.2.3.4":     "1.2.3.4",
+		"::ffff:1.2.3.0/120": "1.2.3.0/24",
+		"2001:db8::1/32":     "2001:db8::/32",
+		"1.2.3.4/32":         "1.2.3.4",
+	}
+	for in, want := range cases {
+		r := &IpRule{Cidr: in}
+		if err := r.Check(); err != nil {
+			t.Errorf("Check(%q) error: %v", in, err)
+			continue
+		}
+		if r.Cidr != want || r.Scope != IpRuleScopeAll {
+			t.Errorf("Check(%q) = %q %q, want %q", in, r.Cidr, r.Scope, want)
+		}
+	}
+	for _, r := range []*IpRule{{Cidr: "1.2.3"}, {Cidr: "1.2.3.4/33"}, {Cidr: "1.2.3.4", Scope: "hbbs"}, {Cidr: "1.2.3.4", ExpiresAt: -1}} {
+		if err := r.Check(); err == nil {
+			t.Errorf("Check(%+v) should fail", *r)
+		}
+	}
+}
+
+func TestIpRuleRelayIp(t *testing.T) {
+	cases := []struct {
+		rule *IpRule
+		want string
+		api  bool
+	}{
+		{&IpRule{Cidr: "1.2.3.4", Scope: IpRuleScopeAll}, "1.2.3.4", true},
+		{&IpRule{Cidr: "1.2.3.4", Scope: IpRuleScopeRelay}, "1.2.3.4", false},
+		{&IpRule{Cidr: "1.2.3.4", Scope: IpRuleScopeApi}, "", true},
+		{&IpRule{Cidr: "10.0.0.0/8", Scope: IpRuleScopeAll}, "", true},
+	}
+	for _, c := range cases {
+		if got := c.rule.RelayIp(); got != c.want {
+			t.Errorf("RelayIp(%+v) = %q, want %q", *c.rule, got, c.want)
+		}
+		if got := c.rule.InApi(); got != c.api {
+			t.Errorf("InApi(%+v) = %v, want %v", *c.rule, got, c.api)
+		}
+	}
+}
+
+func TestIpRuleExpired(t *testing.T) {
+	now := time.Unix(1000, 0)
+	if (&IpRule{}).Expired(now) {
+		t.Error("rule without expiry should not expire")
+	}
+	if (&IpRule{ExpiresAt: 1001}).Expired(now) {
+		t.Error("rule should not be expired yet")
+	}
+	if !(&IpRule{ExpiresAt: 1000}).Expired(now) {
+		t.Error("rule should be expired")
+	}
+}
+
+func TestIpRuleLocksOut(t *testing.T) {
+	cases := []struct {
+		rule IpRule
+		ip   string
+		want bool
+	}{
+		{IpRule{Cidr: "1.2.3.4", Scope: IpRuleScopeAll}, "1.2.3.4", true},
+		{IpRule{Cidr: "1.2.3.0/24", Scope: IpRuleScopeApi}, "::ffff:1.2.3.9", true},
+		{IpRule{Cidr: "1.2.3.0/24", Scope: IpRuleScopeRelay}, "1.2.3.9", false},
+		{IpRule{Cidr: "1.2.4.0/24", Scope: IpRuleScopeAll}, "1.2.3.9", false},
+		{IpRule{Cidr: "0.0.0.0/0", Scope: IpRuleScopeAll}, "1.2.3.9", true},
+		{IpRule{Cidr: "::/0", Scope: IpRuleScopeAll}, "1.2.3.9", true},
+		{IpRule{Cidr: "5.6.7.8", Scope: IpRuleScopeAll}, "", false},
+	}
+	for _, c := range cases {
+		if got := c.rule.LocksOut(c.ip); got != c.want {
+			t.Errorf("LocksOut(%s %s, %q) = %v, want %v", c.rule.Cidr, c.rule.Scope, c.ip, got, c.want)
+		}
+	}
+}
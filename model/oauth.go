@@ -1,7 +1,10 @@
 package model
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
 	"strconv"
 	"strings"
 )
@@ -14,6 +17,7 @@ const (
 	OauthTypeGoogle  string = "google"
 	OauthTypeOidc    string = "oidc"
 	OauthTypeWebauth string = "webauth"
+	OauthTypeOauth2  string = "oauth2" // generic oauth2, eg: gitlab, gitea, feishu
 	PKCEMethodS256   string = "S256"
 	PKCEMethodPlain  string = "plain"
 )
@@ -21,7 +25,7 @@ const (
 // Validate the oauth type
 func ValidateOauthType(oauthType string) error {
 	switch oauthType {
-	case OauthTypeGithub, OauthTypeGoogle, OauthTypeOidc, OauthTypeWebauth:
+	case OauthTypeGithub, OauthTypeGoogle, OauthTypeOidc, OauthTypeWebauth, OauthTypeOauth2:
 		return nil
 	default:
 		return errors.New("invalid Oauth type")
@@ -31,6 +35,8 @@ func ValidateOauthType(oauthType string) error {
 const (
 	UserEndpointGithub string = "https://api.github.com/user"
 	IssuerGoogle       string = "https://accounts.google.com"
+	// BackChannelLogoutEvent the event which must be present in a back-channel logout token
+	BackChannelLogoutEvent string = "http://schemas.openid.net/event/backchannel-logout"
 )
 
 type Oauth struct {
@@ -38,16 +44,74 @@ type Oauth struct {
 	Op           string `json:"op"`
 	OauthType    string `json:"oauth_type"`
 	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	ClientSecret string `json:"client_secret" gorm:"size:512;serializer:encrypted"`
 	RedirectUrl  string `json:"redirect_url"`
 	AutoRegister *bool  `json:"auto_register"`
 	Scopes       string `json:"scopes"`
 	Issuer       string `json:"issuer"`
-	PkceEnable	 *bool  `json:"pkce_enable"`
-	PkceMethod	 string `json:"pkce_method"`
+	PkceEnable   *bool  `json:"pkce_enable"`
+	PkceMethod   string `json:"pkce_method"`
+	RoleClaim    string `json:"role_claim"`    // claim used for mapping, eg: groups, roles, realm_access.roles. empty means disabled
+	AdminRoles   string `json:"admin_roles"`   // claim values granting admin, separated by ,
+	GroupMapping string `json:"group_mapping"` // claim value to local group id, eg: ops:2,helpdesk:3
+	// PostLogoutRedirectUrl is sent to the end_session_endpoint, it must be registered at the IdP
+	PostLogoutRedirectUrl string `json:"post_logout_redirect_url"`
+	// 以下字段仅用于 oauth2 类型, path 格式为 `data.user.id` 或 `$.items[0].id`
+	AuthUrl      string `json:"auth_url"`
+	TokenUrl     string `json:"token_url"`
+	UserInfoUrl  string `json:"user_info_url"`
+	IdPath       string `json:"id_path"`
+	UsernamePath string `json:"username_path"`
+	EmailPath    string `json:"email_path"`
+	NamePath     string `json:"name_path"`
+	AvatarPath   string `json:"avatar_path"`
 	TimeModel
 }
 
+// OauthGroupMap maps a claim value to a local group
+type OauthGroupMap struct {
+	Value   string
+	GroupId uint
+}
+
+// ClaimMappingEnabled if the admin flag and the group should be derived from the IdP claim
+func (oa *Oauth) ClaimMappingEnabled() bool {
+	return strings.TrimSpace(oa.RoleClaim) != ""
+}
+
+// AdminRoleList returns the claim values granting admin
+func (oa *Oauth) AdminRoleList() []string {
+	var res []string
+	for _, v := range strings.Split(oa.AdminRoles, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// GroupMapList parses GroupMapping, the order is kept so the first match wins
+func (oa *Oauth) GroupMapList() ([]OauthGroupMap, error) {
+	var res []OauthGroupMap
+	for _, item := range strings.Split(oa.GroupMapping, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, ":")
+		if i <= 0 {
+			return nil, errors.New("invalid group mapping: " + item)
+		}
+		gid, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+		if err != nil || gid <= 0 {
+			return nil, errors.New("invalid group mapping: " + item)
+		}
+		res = append(res, OauthGroupMap{Value: strings.TrimSpace(item[:i]), GroupId: uint(gid)})
+	}
+	return res, nil
+}
+
 // Helper function to format oauth info, it's used in the update and create method
 func (oa *Oauth) FormatOauthInfo() error {
 	oauthType := strings.TrimSpace(oa.OauthType)
@@ -66,6 +130,17 @@ func (oa *Oauth) FormatOauthInfo() error {
 	if op == "" && oauthType == OauthTypeOidc {
 		oa.Op = OauthTypeOidc
 	}
+	if oauthType == OauthTypeOauth2 {
+		if op == "" {
+			oa.Op = OauthTypeOauth2
+		}
+		if strings.TrimSpace(oa.AuthUrl) == "" || strings.TrimSpace(oa.TokenUrl) == "" || strings.TrimSpace(oa.UserInfoUrl) == "" {
+			return errors.New("auth_url, token_url and user_info_url are required for oauth2")
+		}
+		if strings.TrimSpace(oa.IdPath) == "" {
+			oa.IdPath = "id"
+		}
+	}
 	// check the issuer, if the oauth type is google and the issuer is empty, set the issuer to the default value
 	issuer := strings.TrimSpace(oa.Issuer)
 	// If the oauth type is google and the issuer is empty, set the issuer to the default value
@@ -79,6 +154,10 @@ func (oa *Oauth) FormatOauthInfo() error {
 	if oa.PkceMethod == "" {
 		oa.PkceMethod = PKCEMethodS256
 	}
+	oa.RoleClaim = strings.TrimSpace(oa.RoleClaim)
+	if _, err = oa.GroupMapList(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -89,6 +168,11 @@ type OauthUser struct {
 	Email         string `json:"email"`
 	VerifiedEmail bool   `json:"verified_email,omitempty"`
 	Picture       string `json:"picture,omitempty"`
+	// Claims the raw claims from id_token and userinfo, only used for role and group mapping
+	Claims map[string]interface{} `json:"-" gorm:"-"`
+	// IdToken and Sid are kept for the logout of the IdP session
+	IdToken string `json:"-" gorm:"-"`
+	Sid     string `json:"-" gorm:"-"`
 }
 
 func (ou *OauthUser) ToUser(user *User, overideUsername bool) {
@@ -152,6 +236,40 @@ func (gu *GithubUser) ToOauthUser() *OauthUser {
 	}
 }
 
+// GenericOauthUser userinfo of the oauth2 type, fields are picked by the paths configured in Oauth
+type GenericOauthUser struct {
+	Data map[string]interface{}
+}
+
+// UnmarshalJSON keeps numbers as json.Number, so large ids are not turned into floats
+func (gu *GenericOauthUser) UnmarshalJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(&gu.Data)
+}
+
+func (gu *GenericOauthUser) ToOauthUser(oa *Oauth) *OauthUser {
+	get := func(path string) string {
+		if strings.TrimSpace(path) == "" {
+			return ""
+		}
+		return utils.JsonPathString(gu.Data, path)
+	}
+	email := get(oa.EmailPath)
+	username := get(oa.UsernamePath)
+	// 没有配置或取不到 username 时，降级到 Email
+	if username == "" {
+		username = email
+	}
+	return &OauthUser{
+		OpenId:   get(oa.IdPath),
+		Name:     get(oa.NamePath),
+		Username: strings.ToLower(username),
+		Email:    email,
+		Picture:  get(oa.AvatarPath),
+	}
+}
+
 type OauthList struct {
 	Oauths []*Oauth `json:"list"`
 	Pagination
@@ -0,0 +1,36 @@
+package model
+
+// RelayServer 中继服务器(hbbr), 启用的中继的 PublicAddr 会同步到hbbs的 relay-servers
+type RelayServer struct {
+	IdModel
+	Name       string     `json:"name" gorm:"default:'';not null;uniqueIndex;size:64"`
+	Region     string     `json:"region" gorm:"default:'';not null;index"`
+	AdminAddr  string     `json:"admin_addr" gorm:"default:'';not null;"`  // 命令端口 host:port, 需要目标上转发到本机的代理
+	CmdTarget  string     `json:"cmd_target" gorm:"default:'';not null;"`  // 配置文件中 admin.cmd-targets 的名称, 设置后使用其连接方式(ssh/tls), 忽略 AdminAddr
+	PublicAddr string     `json:"public_addr" gorm:"default:'';not null;"` // 客户端连接的地址 host[:port]
+	Status     StatusCode `json:"status" gorm:"default:1;not null;"`
+	Remark     string     `json:"remark" gorm:"default:'';not null;"`
+	TimeModel
+}
+
+type RelayServerList struct {
+	RelayServers []*RelayServer `json:"list"`
+	Pagination
+}
+
+// RelayCmdResult 向单个中继发送命令的结果
+type RelayCmdResult struct {
+	Id     uint        `json:"id"`
+	Name   string      `json:"name"`
+	Region string      `json:"region"`
+	Output string      `json:"output"`
+	Parsed interface{} `json:"parsed,omitempty"` // 已知命令解析后的结果, 见 servercmd.Parse
+	Error  string      `json:"error,omitempty"`
+}
+
+// RelaySyncResult 发送命令到单个hbbs的结果(如同步 relay-servers), Target 为空表示本机
+type RelaySyncResult struct {
+	Target string `json:"target"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,50 @@
+package model
+
+// RelayUsage 中继的用量采样, 每次采集每个中继一条, 同一次采集的 SampledAt 相同
+// RelayId 为0表示本机的hbbr(没有登记中继时)
+type RelayUsage struct {
+	IdModel
+	RelayId   uint    `json:"relay_id" gorm:"default:0;not null;index:idx_relay_usage,priority:1"`
+	RelayName string  `json:"relay_name" gorm:"default:'';not null;"`
+	Sessions  int     `json:"sessions" gorm:"default:0;not null;"`   // 活动的中继连接数
+	SpeedKbps int64   `json:"speed_kbps" gorm:"default:0;not null;"` // 所有连接的当前速度之和
+	TotalMB   float64 `json:"total_mb" gorm:"default:0;not null;"`   // 活动连接累计的流量之和
+	SampledAt int64   `json:"sampled_at" gorm:"default:0;not null;index:idx_relay_usage,priority:2;index"`
+}
+
+// RelayUsageConn 采样时单个中继连接的用量, 对应 usage 输出的一行
+type RelayUsageConn struct {
+	IdModel
+	UsageId     uint    `json:"usage_id" gorm:"default:0;not null;index"`
+	RelayId     uint    `json:"relay_id" gorm:"default:0;not null;index:idx_relay_usage_conn,priority:1"`
+	ConnKey     string  `json:"conn_key" gorm:"default:'';not null;"` // 连接的标识, 一般为 ip:port
+	Elapsed     int64   `json:"elapsed" gorm:"default:0;not null;"`
+	TotalMB     float64 `json:"total_mb" gorm:"default:0;not null;"`
+	HighestKbps int64   `json:"highest_kbps" gorm:"default:0;not null;"`
+	AvgKbps     int64   `json:"avg_kbps" gorm:"default:0;not null;"`
+	SpeedKbps   int64   `json:"speed_kbps" gorm:"default:0;not null;"`
+	SampledAt   int64   `json:"sampled_at" gorm:"default:0;not null;index:idx_relay_usage_conn,priority:2;index"`
+}
+
+type RelayUsageConnList struct {
+	RelayUsageConns []*RelayUsageConn `json:"list"`
+	Pagination
+}
+
+// RelayUsagePoint 降采样后的一个点, Time 为时间段的开始
+type RelayUsagePoint struct {
+	Time         int64   `json:"time"`
+	Samples      int     `json:"samples"`
+	Sessions     float64 `json:"sessions"` // 平均值
+	MaxSessions  int     `json:"max_sessions"`
+	SpeedKbps    float64 `json:"speed_kbps"` // 平均值
+	MaxSpeedKbps int64   `json:"max_speed_kbps"`
+}
+
+// RelayUsageSeries 单个中继的用量曲线
+type RelayUsageSeries struct {
+	RelayId   uint               `json:"relay_id"`
+	RelayName string             `json:"relay_name"`
+	Step      int64              `json:"step"` // 秒
+	Points    []*RelayUsagePoint `json:"points"`
+}
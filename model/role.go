@@ -0,0 +1,122 @@
+package model
+
+import "strings"
+
+// 权限资源
+const (
+	ResourceUsers        = "users"
+	ResourceGroups       = "groups"
+	ResourcePeers        = "peers"
+	ResourceAddressBooks = "address_books"
+	ResourceAudit        = "audit"
+	ResourceOauth        = "oauth"
+	ResourceServerCmd    = "server_cmd"
+	ResourceTokens       = "tokens"
+)
+
+// 权限动作, write 包含 read
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// PermissionAll 全部权限
+const PermissionAll = "*"
+
+var PermissionResources = []string{
+	ResourceUsers, ResourceGroups, ResourcePeers, ResourceAddressBooks,
+	ResourceAudit, ResourceOauth, ResourceServerCmd, ResourceTokens,
+}
+
+// AllPermissions 所有可分配的权限, 格式为 resource:action
+func AllPermissions() []string {
+	res := make([]string, 0, len(PermissionResources)*2)
+	for _, r := range PermissionResources {
+		res = append(res, Permission(r, ActionRead), Permission(r, ActionWrite))
+	}
+	return res
+}
+
+func Permission(resource, action string) string {
+	return resource + ":" + action
+}
+
+// PermissionRouteNames 权限对应的后台前端路由名
+var PermissionRouteNames = map[string][]string{
+	ResourceUsers:        {"User", "UserList", "UserAdd", "UserEdit"},
+	ResourceGroups:       {"Group", "GroupList", "DeviceGroupList"},
+	ResourcePeers:        {"Peer", "PeerList", "ShareRecordList"},
+	ResourceAddressBooks: {"AddressBook", "AddressBookList", "AddressBookCollection", "AddressBookCollectionRule", "AddressBookChange", "AddressBookInvitation", "Tag", "TagList"},
+	ResourceAudit:        {"Audit", "AuditConnList", "AuditFileList", "LoginLog", "AdminAuditLog"},
+	ResourceOauth:        {"Oauth", "OauthList"},
+	ResourceServerCmd:    {"Rustdesk", "ServerCmd", "RelayServer", "IpRule", "ServerCmdSchedule"},
+	ResourceTokens:       {"UserToken", "AccessToken"},
+}
+
+// Role 后台角色, 可以分配给用户或分组
+type Role struct {
+	IdModel
+	Name        string `json:"name" gorm:"default:'';not null;uniqueIndex"`
+	Remark      string `json:"remark" gorm:"default:'';not null;"`
+	Permissions string `json:"permissions" gorm:"default:'';not null;"` // 逗号分隔, 如 peers:write,address_books:read
+	UserIds     []uint `json:"user_ids" gorm:"-"`
+	GroupIds    []uint `json:"group_ids" gorm:"-"`
+	TimeModel
+}
+
+// PermissionList 返回权限列表
+func (r *Role) PermissionList() []string {
+	var res []string
+	for _, p := range strings.Split(r.Permissions, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// RoleBinding 角色绑定, UserId 和 GroupId 只有一个不为0
+type RoleBinding struct {
+	IdModel
+	RoleId  uint `json:"role_id" gorm:"default:0;not null;index"`
+	UserId  uint `json:"user_id" gorm:"default:0;not null;index"`
+	GroupId uint `json:"group_id" gorm:"default:0;not null;index"`
+	TimeModel
+}
+
+type RoleList struct {
+	Roles []*Role `json:"list"`
+	Pagination
+}
+
+// HasPermission 判断权限列表中是否包含 resource:action
+func HasPermission(perms []string, resource, action string) bool {
+	for _, p := range perms {
+		if p == PermissionAll || p == Permission(resource, action) {
+			return true
+		}
+		if action == ActionRead && p == Permission(resource, ActionWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// CoversPermissions perms 是否包含 want 中的所有权限, want 中的 * 只能由 * 包含
+func CoversPermissions(perms, want []string) bool {
+	all := false
+	for _, p := range perms {
+		all = all || p == PermissionAll
+	}
+	for _, w := range want {
+		if all {
+			return true
+		}
+		i := strings.LastIndex(w, ":")
+		if w == PermissionAll || i < 0 || !HasPermission(perms, w[:i], w[i+1:]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package model
+
+import "testing"
+
+func TestCoversPermissions(t *testing.T) {
+	cases := []struct {
+		perms, want []string
+		res         bool
+	}{
+		{[]string{"users:write"}, nil, true},
+		{[]string{"users:write"}, []string{"users:read", "users:write"}, true},
+		{[]string{"users:read"}, []string{"users:write"}, false},
+		{[]string{"users:write"}, []string{"users:write", "oauth:read"}, false},
+		{[]string{"users:write"}, []string{PermissionAll}, false},
+		{[]string{PermissionAll}, []string{PermissionAll, "oauth:write"}, true},
+	}
+	for _, c := range cases {
+		if got := CoversPermissions(c.perms, c.want); got != c.res {
+			t.Errorf("CoversPermissions(%v, %v) = %v, want %v", c.perms, c.want, got, c.res)
+		}
+	}
+}
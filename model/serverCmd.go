@@ -59,3 +59,16 @@ var SysRelayServerCmds = []*ServerCmd{
 	{Cmd: "single-bandwidth", Alias: "sb", Option: "[value(Mb/s)]", Explain: "single-bandwidth(sb) [value(Mb/s)]", Target: ServerCmdTargetRelayServer},
 	{Cmd: "usage", Alias: "u", Option: "", Explain: "usage(u)", Target: ServerCmdTargetRelayServer},
 }
+
+// ServerCmdHost 可以发送命令的hbbs/hbbr, Name 为空表示本机
+type ServerCmdHost struct {
+	Name   string `json:"name"`
+	Target string `json:"target"` // ServerCmdTargetIdServer 或 ServerCmdTargetRelayServer
+	Addr   string `json:"addr"`
+}
+
+// ServerCmdResult 发送命令的结果, Parsed 为已知命令解析后的结果, 未知命令为空
+type ServerCmdResult struct {
+	Raw    string      `json:"raw"`
+	Parsed interface{} `json:"parsed,omitempty"`
+}
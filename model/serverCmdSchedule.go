@@ -0,0 +1,58 @@
+package model
+
+// 执行的触发方式
+const (
+	ServerCmdRunTriggerSchedule = "schedule"
+	ServerCmdRunTriggerManual   = "manual"
+)
+
+// 执行结果
+const (
+	ServerCmdRunSuccess = "success"
+	ServerCmdRunFailed  = "failed"
+)
+
+// ServerCmdSchedule 定时执行的指令
+// CmdId 不为0时使用自定义指令(ServerCmd)的 Cmd 和 Option, 否则使用自己的
+// Option 为 text/template 模板, 用 Params(json对象) 渲染, 如 Option "{{.speed}}" Params {"speed":"10"}
+// Server 为空时: 21117 发送到所有启用的中继(没有登记中继时为本机), 21115 发送到 id-server 类型的 cmd-targets(没有时为本机)
+type ServerCmdSchedule struct {
+	IdModel
+	Name      string     `json:"name" gorm:"default:'';not null;"`
+	Cron      string     `json:"cron" gorm:"default:'';not null;"`     // 分 时 日 月 周, 如 0 9 * * 1-5
+	Timezone  string     `json:"timezone" gorm:"default:'';not null;"` // 为空使用服务器时区
+	CmdId     uint       `json:"cmd_id" gorm:"default:0;not null;"`
+	Cmd       string     `json:"cmd" gorm:"default:'';not null;"`
+	Option    string     `json:"option" gorm:"default:'';not null;"`
+	Params    string     `json:"params" gorm:"type:text"`
+	Target    string     `json:"target" gorm:"default:'';not null;"` // ServerCmdTargetIdServer 或 ServerCmdTargetRelayServer
+	Server    string     `json:"server" gorm:"default:'';not null;"` // cmd-targets 的名称
+	Status    StatusCode `json:"status" gorm:"default:1;not null;"`
+	LastRunAt int64      `json:"last_run_at" gorm:"default:0;not null;"`
+	NextRunAt int64      `json:"next_run_at" gorm:"default:0;not null;index"` // unix秒, 0为不再执行
+	TimeModel
+}
+
+type ServerCmdScheduleList struct {
+	ServerCmdSchedules []*ServerCmdSchedule `json:"list"`
+	Pagination
+}
+
+// ServerCmdRun 执行历史, 每次执行每个目标一条
+type ServerCmdRun struct {
+	IdModel
+	ScheduleId uint   `json:"schedule_id" gorm:"default:0;not null;index"`
+	Trigger    string `json:"trigger" gorm:"default:'';not null;"`
+	Host       string `json:"host" gorm:"default:'';not null;"` // 中继或cmd-target的名称, 为空是本机
+	Cmd        string `json:"cmd" gorm:"default:'';not null;"`  // 渲染后的完整指令
+	Status     string `json:"status" gorm:"default:'';not null;"`
+	Output     string `json:"output" gorm:"type:text"`
+	Error      string `json:"error" gorm:"type:text"`
+	Duration   int64  `json:"duration" gorm:"default:0;not null;"` // 毫秒
+	TimeModel
+}
+
+type ServerCmdRunList struct {
+	ServerCmdRuns []*ServerCmdRun `json:"list"`
+	Pagination
+}
@@ -1,18 +1,111 @@
 package model
 
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
 type ShareRecord struct {
 	IdModel
 	UserId       uint   `json:"user_id" gorm:"default:0;not null;index"`
 	PeerId       string `json:"peer_id" gorm:"default:'';not null;index"`
 	ShareToken   string `json:"share_token" gorm:"default:'';not null;index"`
 	PasswordType string `json:"password_type" gorm:"default:'';not null;"`
-	Password     string `json:"password" gorm:"default:'';not null;"`
+	Password     string `json:"password" gorm:"default:'';not null;size:512;serializer:encrypted"`
 	Expire       int64  `json:"expire" gorm:"default:0;not null;"`
+	MaxUses      int64  `json:"max_uses" gorm:"default:0;not null;"` // 0 不限制
+	UsedCount    int64  `json:"used_count" gorm:"default:0;not null;"`
+	RevokedAt    int64  `json:"revoked_at" gorm:"default:0;not null;"`     // 不为0表示已吊销
+	AllowedCidrs string `json:"allowed_cidrs" gorm:"default:'';not null;"` // 逗号分隔, 为空不限制, 如 10.0.0.0/8,1.2.3.4
+	RequireLogin *bool  `json:"require_login" gorm:"default:0;not null;"`  // 访问者必须登录
+	// AccessLogs 最近的访问记录, 只在列表中返回
+	AccessLogs []*ShareAccessLog `json:"access_logs,omitempty" gorm:"-"`
 	TimeModel
 }
 
+// CidrList 解析 AllowedCidrs, 单个ip视为 /32 或 /128
+func (sr *ShareRecord) CidrList() ([]*net.IPNet, error) {
+	var res []*net.IPNet
+	for _, s := range strings.Split(sr.AllowedCidrs, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, errors.New("invalid ip: " + s)
+			}
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.New("invalid cidr: " + s)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// IpAllowed ip是否在允许的范围内
+func (sr *ShareRecord) IpAllowed(ip string) bool {
+	cidrs, err := sr.CidrList()
+	if err != nil {
+		return false
+	}
+	if len(cidrs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range cidrs {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sr *ShareRecord) IsRequireLogin() bool {
+	return sr.RequireLogin != nil && *sr.RequireLogin
+}
+
 // ShareRecordList 分享记录列表
 type ShareRecordList struct {
 	ShareRecords []*ShareRecord `json:"list,omitempty"`
 	Pagination
 }
+
+// 分享链接访问结果
+const (
+	ShareAccessOk            = "ok"
+	ShareAccessRevoked       = "revoked"
+	ShareAccessExpired       = "expired"
+	ShareAccessLimitReached  = "limit_reached"
+	ShareAccessIpDenied      = "ip_denied"
+	ShareAccessLoginRequired = "login_required"
+)
+
+// ShareAccessLog 分享链接访问记录
+type ShareAccessLog struct {
+	IdModel
+	ShareRecordId uint   `json:"share_record_id" gorm:"default:0;not null;index"`
+	UserId        uint   `json:"user_id" gorm:"default:0;not null;"` // 登录访问时记录
+	Username      string `json:"username" gorm:"default:'';not null;"`
+	Ip            string `json:"ip" gorm:"default:'';not null;"`
+	UserAgent     string `json:"user_agent" gorm:"default:'';not null;size:512"`
+	Result        string `json:"result" gorm:"default:'';not null;"`
+	TimeModel
+}
+
+type ShareAccessLogList struct {
+	ShareAccessLogs []*ShareAccessLog `json:"list"`
+	Pagination
+}
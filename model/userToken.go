@@ -7,6 +7,11 @@ type UserToken struct {
 	DeviceId   string `json:"device_id" gorm:"default:'';omitempty;"`
 	Token      string `json:"token" gorm:"default:'';not null;index"`
 	ExpiredAt  int64  `json:"expired_at" gorm:"default:0;not null;"`
+	// 以下字段仅在oidc登录时记录, 用于 RP-initiated logout 和 back-channel logout
+	Op      string `json:"op" gorm:"default:'';not null;"`
+	OpenId  string `json:"open_id" gorm:"default:'';not null;index"`
+	Sid     string `json:"-" gorm:"default:'';not null;index"`
+	IdToken string `json:"-" gorm:"type:text;serializer:encrypted"`
 	TimeModel
 }
 
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+type AccessTokenService struct {
+}
+
+// accessTokenTouchInterval 最后使用时间的更新间隔, 避免每次请求都写库
+const accessTokenTouchInterval = 60
+
+// HashAccessToken token只保存sha256
+func (ats *AccessTokenService) HashAccessToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// IsAccessToken 是否是个人访问令牌
+func (ats *AccessTokenService) IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, model.AccessTokenPrefix)
+}
+
+// InfoById 根据id取令牌
+func (ats *AccessTokenService) InfoById(id uint) *model.AccessToken {
+	at := &model.AccessToken{}
+	DB.Where("id = ?", id).First(at)
+	return at
+}
+
+func (ats *AccessTokenService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.AccessTokenList) {
+	res = &model.AccessTokenList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.AccessToken{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Find(&res.AccessTokens)
+	return
+}
+
+// Create 创建令牌, 明文token只在创建时返回一次
+func (ats *AccessTokenService) Create(u *model.User, name string, scopes []string, expiredAt int64) (string, *model.AccessToken, error) {
+	var clean []string
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if s == "" || utils.InArray(s, clean) {
+			continue
+		}
+		if !utils.InArray(s, model.AccessTokenScopes) {
+			return "", nil, errors.New("invalid scope: " + s)
+		}
+		if s == model.ScopeAdminAll && !AllService.UserService.IsAdmin(u) {
+			return "", nil, errors.New("NoAccess")
+		}
+		clean = append(clean, s)
+	}
+	if len(clean) == 0 {
+		return "", nil, errors.New("scopes is empty")
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := model.AccessTokenPrefix + hex.EncodeToString(buf)
+	at := &model.AccessToken{
+		UserId:    u.Id,
+		Name:      name,
+		TokenHash: ats.HashAccessToken(token),
+		Prefix:    token[:len(model.AccessTokenPrefix)+6],
+		Scopes:    strings.Join(clean, ","),
+		ExpiredAt: expiredAt,
+	}
+	if err := DB.Create(at).Error; err != nil {
+		return "", nil, err
+	}
+	return token, at, nil
+}
+
+func (ats *AccessTokenService) Delete(at *model.AccessToken) error {
+	return DB.Delete(at).Error
+}
+
+// DeleteByUserId 删除用户时删除其所有令牌
+func (ats *AccessTokenService) DeleteByUserId(userId uint) error {
+	return DB.Where("user_id = ?", userId).Delete(&model.AccessToken{}).Error
+}
+
+// InfoByToken 根据明文token取用户和令牌, 过期或不存在时用户Id为0
+func (ats *AccessTokenService) InfoByToken(token string) (*model.User, *model.AccessToken) {
+	u := &model.User{}
+	at := &model.AccessToken{}
+	DB.Where("token_hash = ?", ats.HashAccessToken(token)).First(at)
+	if at.Id == 0 {
+		return u, at
+	}
+	if at.ExpiredAt > 0 && at.ExpiredAt < time.Now().Unix() {
+		return u, at
+	}
+	DB.Where("id = ?", at.UserId).First(u)
+	return u, at
+}
+
+// Touch 记录最后使用时间和ip
+func (ats *AccessTokenService) Touch(at *model.AccessToken, ip string) {
+	now := time.Now().Unix()
+	if now-at.LastUsedAt < accessTokenTouchInterval && at.LastUsedIp == ip {
+		return
+	}
+	at.LastUsedAt = now
+	at.LastUsedIp = ip
+	DB.Model(at).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	})
+}
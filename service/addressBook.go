@@ -5,7 +5,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/lejianwen/rustdesk-api/v2/model"
 	"gorm.io/gorm"
+	"reflect"
 	"strings"
+	"time"
 )
 
 type AddressBookService struct {
@@ -47,18 +49,53 @@ func (s *AddressBookService) ListByUserIds(userIds []uint, page, pageSize uint)
 }
 
 // AddAddressBook
-func (s *AddressBookService) AddAddressBook(ab *model.AddressBook) error {
-	return DB.Create(ab).Error
+func (s *AddressBookService) AddAddressBook(op *model.User, ab *model.AddressBook) error {
+	return s.Create(op, ab)
+}
+
+// UpdateAddressBook 客户端整体提交默认地址簿, baseRev 为客户端修改前的版本, 小于0时不检查(旧客户端)
+// 版本不是最新时和服务端的修改做三方合并, 无法合并返回 ErrAddressBookConflict; 返回新的版本号
+func (s *AddressBookService) UpdateAddressBook(op *model.User, userId uint, data *model.AddressBookExport, baseRev int64) (int64, error) {
+	var rev int64
+	err := operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		cur := s.revision(tx, userId, 0)
+		if baseRev >= 0 && baseRev != cur {
+			base := s.snapshotAt(tx, userId, 0, baseRev)
+			if base == nil {
+				return ErrAddressBookConflict
+			}
+			merged, conflicts := s.MergeAddressBook(base, s.current(tx, userId, 0), data)
+			if len(conflicts) > 0 {
+				Logger.Info("address book conflict, user: ", userId, ", peers: ", conflicts)
+				return ErrAddressBookConflict
+			}
+			data = merged
+		}
+		if err := s.replacePeers(tx, userId, 0, data.Peers); err != nil {
+			return err
+		}
+		colors := s.parseTagColors(data.TagColors)
+		for _, t := range data.Tags {
+			if _, ok := colors[t]; !ok {
+				colors[t] = 0
+			}
+		}
+		if err := s.replaceTags(tx, userId, 0, colors); err != nil {
+			return err
+		}
+		var err error
+		// 读取版本到写入之间有其他请求写入时返回冲突
+		rev, err = s.bumpRevisionFrom(tx, userId, 0, cur)
+		return err
+	})
+	return rev, err
 }
 
-// UpdateAddressBook
-func (s *AddressBookService) UpdateAddressBook(abs []*model.AddressBook, userId uint) error {
-	//比较peers和数据库中的数据，如果peers中的数据在数据库中不存在，则添加，如果存在则更新，如果数据库中的数据在peers中不存在，则删除
-	// 开始事务
-	tx := DB.Begin()
+// replacePeers 比较peers和数据库中的数据，如果peers中的数据在数据库中不存在，则添加，如果存在则更新，如果数据库中的数据在peers中不存在，则删除
+func (s *AddressBookService) replacePeers(tx *gorm.DB, userId, cid uint, abs []*model.AddressBook) error {
 	//1. 获取数据库中的数据
 	var dbABs []*model.AddressBook
-	tx.Where("user_id = ?", userId).Find(&dbABs)
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Find(&dbABs)
 	//2. 比较peers和数据库中的数据
 	//2.1 获取peers中的id
 	aBIds := make(map[string]*model.AddressBook)
@@ -74,8 +111,11 @@ func (s *AddressBookService) UpdateAddressBook(abs []*model.AddressBook, userId
 	for id, ab := range aBIds {
 		dbAB, ok := dbABIds[id]
 		ab.UserId = userId
+		ab.CollectionId = cid
+		ab.Collection = nil
 		if !ok {
 			//添加
+			ab.RowId = 0
 			if ab.Platform == "" || ab.Username == "" || ab.Hostname == "" {
 				peer := AllService.PeerService.FindById(ab.Id)
 				if peer.RowId != 0 {
@@ -84,22 +124,88 @@ func (s *AddressBookService) UpdateAddressBook(abs []*model.AddressBook, userId
 					ab.Hostname = peer.Hostname
 				}
 			}
-			tx.Create(ab)
+			if err := tx.Create(ab).Error; err != nil {
+				return err
+			}
+			if err := s.logPeerChange(tx, nil, ab); err != nil {
+				return err
+			}
 		} else {
-			//更新
-			tx.Model(&model.AddressBook{}).Where("row_id = ?", dbAB.RowId).Updates(ab)
+			//更新, 整体替换, 清空的字段也要更新
+			//旧客户端不上传 password/loginName/sameServer, 为空时保留原来的值
+			ab.RowId = dbAB.RowId
+			if ab.Password == "" {
+				ab.Password = dbAB.Password
+			}
+			if ab.LoginName == "" {
+				ab.LoginName = dbAB.LoginName
+			}
+			if !ab.SameServer {
+				ab.SameServer = dbAB.SameServer
+			}
+			if err := tx.Model(ab).Select("*").Omit("created_at").Updates(ab).Error; err != nil {
+				return err
+			}
+			if err := s.logPeerChange(tx, dbAB, ab); err != nil {
+				return err
+			}
 		}
 	}
 	//2.4 删除
 	for id, dbAB := range dbABIds {
 		_, ok := aBIds[id]
 		if !ok {
-			tx.Delete(dbAB)
+			if err := tx.Delete(dbAB).Error; err != nil {
+				return err
+			}
+			if err := s.logPeerChange(tx, dbAB, nil); err != nil {
+				return err
+			}
 		}
 	}
-	tx.Commit()
 	return nil
+}
 
+// replaceTags 整体替换地址簿的标签
+func (s *AddressBookService) replaceTags(tx *gorm.DB, userId, cid uint, tags map[string]uint) error {
+	var allTags []*model.Tag
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Find(&allTags)
+	for _, t := range allTags {
+		color, ok := tags[t.Name]
+		if !ok {
+			//删除
+			if err := tx.Delete(t).Error; err != nil {
+				return err
+			}
+			if err := s.logTagChange(tx, t, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if color != t.Color {
+			//更新
+			before := *t
+			if err := tx.Model(t).Update("color", color).Error; err != nil {
+				return err
+			}
+			t.Color = color
+			if err := s.logTagChange(tx, &before, t); err != nil {
+				return err
+			}
+		}
+		delete(tags, t.Name)
+	}
+	//新增
+	for name, color := range tags {
+		t := &model.Tag{Name: name, Color: color, UserId: userId, CollectionId: cid}
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		if err := s.logTagChange(tx, nil, t); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *AddressBookService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.AddressBookList) {
@@ -127,27 +233,76 @@ func (s *AddressBookService) FromPeer(peer *model.Peer) (a *model.AddressBook) {
 }
 
 // Create 创建
-func (s *AddressBookService) Create(u *model.AddressBook) error {
-	res := DB.Create(u).Error
-	return res
+func (s *AddressBookService) Create(op *model.User, u *model.AddressBook) error {
+	return s.withRevision(op, u, func(tx *gorm.DB) error {
+		return tx.Create(u).Error
+	})
 }
-func (s *AddressBookService) Delete(u *model.AddressBook) error {
-	return DB.Delete(u).Error
+func (s *AddressBookService) Delete(op *model.User, u *model.AddressBook) error {
+	return s.withRevision(op, u, func(tx *gorm.DB) error {
+		return tx.Delete(u).Error
+	})
 }
 
 // Update 更新
-func (s *AddressBookService) Update(u *model.AddressBook) error {
-	return DB.Model(u).Updates(u).Error
+func (s *AddressBookService) Update(op *model.User, u *model.AddressBook) error {
+	return s.withRevision(op, u, func(tx *gorm.DB) error {
+		return tx.Model(u).Updates(u).Error
+	})
 }
 
 // UpdateByMap 更新
-func (s *AddressBookService) UpdateByMap(u *model.AddressBook, data map[string]interface{}) error {
-	return DB.Model(u).Updates(data).Error
+func (s *AddressBookService) UpdateByMap(op *model.User, u *model.AddressBook, data map[string]interface{}) error {
+	return s.withRevision(op, u, func(tx *gorm.DB) error {
+		// 密码需要经过serializer加密, 不能用map更新
+		if pwd, ok := data["password"]; ok {
+			delete(data, "password")
+			u.Password, _ = pwd.(string)
+			if err := tx.Model(u).Select("password").Updates(u).Error; err != nil {
+				return err
+			}
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		return tx.Model(u).Updates(data).Error
+	})
 }
 
 // UpdateAll 更新
-func (s *AddressBookService) UpdateAll(u *model.AddressBook) error {
-	return DB.Model(u).Select("*").Omit("created_at").Updates(u).Error
+func (s *AddressBookService) UpdateAll(op *model.User, u *model.AddressBook) error {
+	return s.withRevision(op, u, func(tx *gorm.DB) error {
+		return tx.Model(u).Select("*").Omit("created_at").Updates(u).Error
+	})
+}
+
+// withRevision 修改后记录历史并更新版本号, 修改前后所属的地址簿都会更新
+func (s *AddressBookService) withRevision(op *model.User, u *model.AddressBook, fn func(tx *gorm.DB) error) error {
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		var abs []*model.AddressBook
+		old := &model.AddressBook{}
+		if u.RowId > 0 {
+			tx.Where("row_id = ?", u.RowId).First(old)
+			if old.RowId > 0 {
+				abs = append(abs, old)
+			}
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		cur := &model.AddressBook{}
+		tx.Where("row_id = ?", u.RowId).First(cur)
+		if err := s.checkWritable(tx, cur.CollectionId); err != nil {
+			return err
+		}
+		if err := s.logPeerChange(tx, old, cur); err != nil {
+			return err
+		}
+		if cur.RowId > 0 {
+			abs = append(abs, cur)
+		}
+		return s.bumpRevisions(tx, abs)
+	})
 }
 
 // ShareByWebClient 分享
@@ -217,39 +372,60 @@ func (s *AddressBookService) CollectionInfoById(id uint) *model.AddressBookColle
 
 func (s *AddressBookService) CollectionReadRules(user *model.User) (res []*model.AddressBookCollectionRule) {
 	// personalRules
-	var personalRules []*model.AddressBookCollectionRule
-	tx2 := DB.Model(&model.AddressBookCollectionRule{})
-	tx2.Where("type = ? and to_id = ? and rule > 0", model.ShareAddressBookRuleTypePersonal, user.Id).Find(&personalRules)
-	res = append(res, personalRules...)
+	for _, r := range s.rulesTo(model.ShareAddressBookRuleTypePersonal, user.Id) {
+		if r.Rule > 0 {
+			res = append(res, &r)
+		}
+	}
 
 	//group
-	var groupRules []*model.AddressBookCollectionRule
-	tx3 := DB.Model(&model.AddressBookCollectionRule{})
-	tx3.Where("type = ? and to_id = ? and rule > 0", model.ShareAddressBookRuleTypeGroup, user.GroupId).Find(&groupRules)
-	res = append(res, groupRules...)
-	return
+	for _, r := range s.rulesTo(model.ShareAddressBookRuleTypeGroup, user.GroupId) {
+		if r.Rule > 0 {
+			res = append(res, &r)
+		}
+	}
+	return activeRules(res, time.Now())
+}
+
+// activeRules 过滤出 t 时生效的规则
+func activeRules(rules []*model.AddressBookCollectionRule, t time.Time) []*model.AddressBookCollectionRule {
+	res := make([]*model.AddressBookCollectionRule, 0, len(rules))
+	for _, r := range rules {
+		if r.Active(t) {
+			res = append(res, r)
+		}
+	}
+	return res
 }
 
 func (s *AddressBookService) UserMaxRule(user *model.User, uid, cid uint) int {
+	// 智能地址簿只读
+	if s.isSmartCached(cid) {
+		if user.Id == uid || s.userRule(user, cid) > 0 {
+			return model.ShareAddressBookRuleRuleRead
+		}
+		return 0
+	}
 	// ismy?
 	if user.Id == uid {
 		return model.ShareAddressBookRuleRuleFullControl
 	}
+	return s.userRule(user, cid)
+}
+
+// userRule 共享规则中用户对地址簿的最大权限
+func (s *AddressBookService) userRule(user *model.User, cid uint) int {
 	max := 0
-	personalRules := &model.AddressBookCollectionRule{}
-	tx := DB.Model(personalRules)
-	tx.Where("type = ? and collection_id = ? and to_id = ?", model.ShareAddressBookRuleTypePersonal, cid, user.Id).First(&personalRules)
-	if personalRules.Id != 0 {
+	personalRules := ruleOfCollection(s.rulesTo(model.ShareAddressBookRuleTypePersonal, user.Id), cid)
+	if personalRules.Id != 0 && personalRules.Active(time.Now()) {
 		max = personalRules.Rule
 		if max == model.ShareAddressBookRuleRuleFullControl {
 			return max
 		}
 	}
 
-	groupRules := &model.AddressBookCollectionRule{}
-	tx2 := DB.Model(groupRules)
-	tx2.Where("type = ? and collection_id = ? and to_id = ?", model.ShareAddressBookRuleTypeGroup, cid, user.GroupId).First(&groupRules)
-	if groupRules.Id != 0 {
+	groupRules := ruleOfCollection(s.rulesTo(model.ShareAddressBookRuleTypeGroup, user.GroupId), cid)
+	if groupRules.Id != 0 && groupRules.Active(time.Now()) {
 		if groupRules.Rule > max {
 			max = groupRules.Rule
 		}
@@ -260,6 +436,17 @@ func (s *AddressBookService) UserMaxRule(user *model.User, uid, cid uint) int {
 	return max
 }
 
+// ruleOfCollection 规则中 id 最小的属于 cid 的规则, 没有时返回空规则
+func ruleOfCollection(rules []model.AddressBookCollectionRule, cid uint) *model.AddressBookCollectionRule {
+	res := &model.AddressBookCollectionRule{}
+	for i := range rules {
+		if rules[i].CollectionId == cid && (res.Id == 0 || rules[i].Id < res.Id) {
+			res = &rules[i]
+		}
+	}
+	return res
+}
+
 func (s *AddressBookService) CheckUserReadPrivilege(user *model.User, uid, cid uint) bool {
 	return s.UserMaxRule(user, uid, cid) >= model.ShareAddressBookRuleRuleRead
 }
@@ -270,19 +457,50 @@ func (s *AddressBookService) CheckUserFullControlPrivilege(user *model.User, uid
 	return s.UserMaxRule(user, uid, cid) >= model.ShareAddressBookRuleRuleFullControl
 }
 
-func (s *AddressBookService) CreateCollection(t *model.AddressBookCollection) error {
-	return DB.Create(t).Error
+func (s *AddressBookService) CreateCollection(op *model.User, t *model.AddressBookCollection) error {
+	defer s.forgetPermissions()
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return s.logCollectionChange(tx, model.AddressBookChangeCreate, nil, t, t)
+	})
 }
 
-func (s *AddressBookService) UpdateCollection(t *model.AddressBookCollection) error {
-	return DB.Model(t).Updates(t).Error
+func (s *AddressBookService) UpdateCollection(op *model.User, t *model.AddressBookCollection) error {
+	defer s.forgetPermissions()
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		before := &model.AddressBookCollection{}
+		tx.Where("id = ?", t.Id).First(before)
+		// smart 为空时改回普通地址簿, 需要单独更新
+		if err := tx.Model(t).Updates(t).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(t).Select("smart").Updates(t).Error; err != nil {
+			return err
+		}
+		after := &model.AddressBookCollection{}
+		tx.Where("id = ?", t.Id).First(after)
+		if before.Name == after.Name && reflect.DeepEqual(before.Smart, after.Smart) {
+			return nil
+		}
+		return s.logCollectionChange(tx, model.AddressBookChangeUpdate, before, after, after)
+	})
 }
 
-func (s *AddressBookService) DeleteCollection(t *model.AddressBookCollection) error {
+func (s *AddressBookService) DeleteCollection(op *model.User, t *model.AddressBookCollection) error {
 	//删除集合下的所有规则、地址簿，再删除集合
-	tx := DB.Begin()
+	defer s.forgetPermissions()
+	tx := operatorDB(op).Begin()
+	// 删除前记录完整内容, 可以恢复
+	if err := s.logCollectionChange(tx, model.AddressBookChangeDelete, s.collectionState(tx, t), nil, t); err != nil {
+		tx.Rollback()
+		return err
+	}
 	tx.Where("collection_id = ?", t.Id).Delete(&model.AddressBookCollectionRule{})
+	tx.Where("collection_id = ?", t.Id).Delete(&model.AddressBookInvitation{})
 	tx.Where("collection_id = ?", t.Id).Delete(&model.AddressBook{})
+	s.deleteRevisions(tx, t.UserId, t.Id)
 	tx.Delete(t)
 	return tx.Commit().Error
 }
@@ -301,6 +519,7 @@ func (s *AddressBookService) RuleInfoByToIdAndCid(t int, toid, cid uint) *model.
 	return p
 }
 func (s *AddressBookService) CreateRule(t *model.AddressBookCollectionRule) error {
+	defer s.forgetPermissions()
 	return DB.Create(t).Error
 }
 
@@ -319,10 +538,43 @@ func (s *AddressBookService) ListRules(page uint, size uint, f func(tx *gorm.DB)
 }
 
 func (s *AddressBookService) UpdateRule(t *model.AddressBookCollectionRule) error {
-	return DB.Model(t).Updates(t).Error
+	defer s.forgetPermissions()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(t).Updates(t).Error; err != nil {
+			return err
+		}
+		// 生效时间可以清空, 需要单独更新
+		return tx.Model(t).Select("valid_from", "valid_until", "weekdays", "start_time", "end_time", "timezone").Updates(t).Error
+	})
+}
+
+// CleanExpiredRules 删除已过期的共享规则
+func (s *AddressBookService) CleanExpiredRules() (int64, error) {
+	res := DB.Where("valid_until > 0 and valid_until <= ?", time.Now().Unix()).Delete(&model.AddressBookCollectionRule{})
+	if res.RowsAffected > 0 {
+		s.forgetPermissions()
+	}
+	return res.RowsAffected, res.Error
+}
+
+// CleanExpiredRulesEvery 定时删除已过期的共享规则
+func (s *AddressBookService) CleanExpiredRulesEvery(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		n, err := s.CleanExpiredRules()
+		if err != nil {
+			Logger.Error("clean expired address book rules failed: ", err)
+			continue
+		}
+		if n > 0 {
+			Logger.Info("cleaned expired address book rules: ", n)
+		}
+	}
 }
 
 func (s *AddressBookService) DeleteRule(t *model.AddressBookCollectionRule) error {
+	defer s.forgetPermissions()
 	return DB.Delete(t).Error
 }
 
@@ -332,11 +584,25 @@ func (s *AddressBookService) CheckCollectionOwner(uid uint, cid uint) bool {
 	return p.UserId == uid
 }
 
-func (s *AddressBookService) BatchUpdateTags(abs []*model.AddressBook, tags []string) error {
+func (s *AddressBookService) BatchUpdateTags(op *model.User, abs []*model.AddressBook, tags []string) error {
 	ids := make([]uint, 0)
 	for _, ab := range abs {
 		ids = append(ids, ab.RowId)
 	}
 	tagsv, _ := json.Marshal(tags)
-	return DB.Model(&model.AddressBook{}).Where("row_id in ?", ids).Update("tags", tagsv).Error
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		var befores []*model.AddressBook
+		tx.Where("row_id in ?", ids).Find(&befores)
+		if err := tx.Model(&model.AddressBook{}).Where("row_id in ?", ids).Update("tags", tagsv).Error; err != nil {
+			return err
+		}
+		for _, b := range befores {
+			after := *b
+			after.Tags = tagsv
+			if err := s.logPeerChange(tx, b, &after); err != nil {
+				return err
+			}
+		}
+		return s.bumpRevisions(tx, abs)
+	})
 }
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+)
+
+// AddressBookBulkLimit 一次批量操作最多的条目数
+const AddressBookBulkLimit = 1000
+
+// 错误的值为i18n的messageId
+var (
+	ErrBulkSelectorEmpty = errors.New("ParamsError")
+	ErrBulkTooMany       = errors.New("TooManyItems")
+)
+
+// errBulkRollback 有条目失败时回滚整个事务
+var errBulkRollback = errors.New("bulk rollback")
+
+// bulkFail 单个条目失败, 继续处理其他条目以便返回完整的结果, 最后整体回滚
+type bulkFail struct {
+	msg string
+}
+
+func (e *bulkFail) Error() string {
+	return e.msg
+}
+
+// BulkSelect 按条件选择用户的地址簿条目, userId 为0时不限制用户(管理员)
+func (s *AddressBookService) BulkSelect(userId uint, sel *model.AddressBookBulkSelector) ([]*model.AddressBook, error) {
+	if sel.Empty() {
+		return nil, ErrBulkSelectorEmpty
+	}
+	var abs []*model.AddressBook
+	tx := DB.Model(&model.AddressBook{})
+	if userId > 0 {
+		tx.Where("user_id = ?", userId)
+	}
+	if len(sel.RowIds) > 0 {
+		tx.Where("row_id in ?", sel.RowIds)
+	}
+	if sel.CollectionId != nil {
+		tx.Where("collection_id = ?", *sel.CollectionId)
+	}
+	if sel.Id != "" {
+		tx.Where("id like ? escape '!'", LikeContains(sel.Id))
+	}
+	if sel.Hostname != "" {
+		tx.Where("hostname like ? escape '!'", HostnameLikePattern(sel.Hostname))
+	}
+	if sel.Username != "" {
+		tx.Where("username like ? escape '!'", LikeContains(sel.Username))
+	}
+	if sel.Alias != "" {
+		tx.Where("alias like ? escape '!'", LikeContains(sel.Alias))
+	}
+	tx.Order("row_id asc").Find(&abs)
+	// 标签保存为json, 查出来后再筛选
+	if sel.Tag != "" {
+		res := make([]*model.AddressBook, 0, len(abs))
+		for _, ab := range abs {
+			if utils.InArray(sel.Tag, s.TagsOf(ab)) {
+				res = append(res, ab)
+			}
+		}
+		abs = res
+	}
+	if len(abs) > AddressBookBulkLimit {
+		return nil, ErrBulkTooMany
+	}
+	return abs, nil
+}
+
+// Bulk 在一个事务中对选中的条目执行批量操作, 返回每个条目的结果
+// 有条目失败时全部回滚; 数据库错误直接返回
+func (s *AddressBookService) Bulk(op *model.User, abs []*model.AddressBook, act *model.AddressBookBulkAction) (*model.AddressBookBulkResult, error) {
+	res := &model.AddressBookBulkResult{Total: len(abs), Items: make([]*model.AddressBookBulkItem, 0, len(abs))}
+	var target *model.AddressBookCollection
+	if act.Action == model.AddressBookBulkMove || act.Action == model.AddressBookBulkCopy {
+		if act.CollectionId > 0 {
+			target = s.CollectionInfoById(act.CollectionId)
+			if target.Id == 0 {
+				return nil, ErrCollectionNotFound
+			}
+			if target.IsSmart() {
+				return nil, ErrSmartCollectionReadOnly
+			}
+		}
+	}
+	err := operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		var touched []*model.AddressBook
+		for i, ab := range abs {
+			item := &model.AddressBookBulkItem{RowId: ab.RowId, Id: ab.Id}
+			res.Items = append(res.Items, item)
+			t, err := s.bulkApply(tx, ab, act, target, i, item)
+			var bf *bulkFail
+			if errors.As(err, &bf) {
+				item.Status = model.AddressBookBulkFailed
+				item.Error = bf.msg
+				res.Failed++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if len(t) == 0 {
+				item.Status = model.AddressBookBulkSkipped
+				res.Skipped++
+				continue
+			}
+			item.Status = model.AddressBookBulkOk
+			res.Ok++
+			touched = append(touched, t...)
+		}
+		if res.Failed > 0 {
+			return errBulkRollback
+		}
+		return s.bumpRevisions(tx, touched)
+	})
+	if errors.Is(err, errBulkRollback) {
+		for _, item := range res.Items {
+			item.NewRowId = 0
+		}
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Applied = true
+	return res, nil
+}
+
+// bulkApply 处理单个条目, 返回需要更新版本的条目, 为空表示跳过
+func (s *AddressBookService) bulkApply(tx *gorm.DB, ab *model.AddressBook, act *model.AddressBookBulkAction, target *model.AddressBookCollection, n int, item *model.AddressBookBulkItem) ([]*model.AddressBook, error) {
+	if act.Action != model.AddressBookBulkCopy {
+		if err := s.checkWritable(tx, ab.CollectionId); err != nil {
+			return nil, &bulkFail{err.Error()}
+		}
+	}
+	after := *ab
+	switch act.Action {
+	case model.AddressBookBulkMove, model.AddressBookBulkCopy:
+		if target != nil && target.UserId != ab.UserId {
+			return nil, &bulkFail{"NoAccess"}
+		}
+		if ab.CollectionId == act.CollectionId {
+			return nil, nil
+		}
+		ex := &model.AddressBook{}
+		tx.Where("user_id = ? and id = ? and collection_id = ?", ab.UserId, ab.Id, act.CollectionId).First(ex)
+		if ex.RowId > 0 {
+			item.Error = "ItemExists"
+			return nil, nil
+		}
+		if err := s.bulkEnsureTags(tx, ab.UserId, ab.CollectionId, act.CollectionId, s.TagsOf(ab)); err != nil {
+			return nil, err
+		}
+		after.CollectionId = act.CollectionId
+		if act.Action == model.AddressBookBulkCopy {
+			after.RowId = 0
+			if err := tx.Create(&after).Error; err != nil {
+				return nil, err
+			}
+			item.NewRowId = after.RowId
+			return []*model.AddressBook{&after}, s.logPeerChange(tx, nil, &after)
+		}
+		if err := tx.Model(&after).Update("collection_id", after.CollectionId).Error; err != nil {
+			return nil, err
+		}
+		return []*model.AddressBook{ab, &after}, s.logPeerChange(tx, ab, &after)
+	case model.AddressBookBulkDelete:
+		if err := tx.Delete(ab).Error; err != nil {
+			return nil, err
+		}
+		return []*model.AddressBook{ab}, s.logPeerChange(tx, ab, nil)
+	case model.AddressBookBulkAddTags, model.AddressBookBulkRemoveTags:
+		tags := s.TagsOf(ab)
+		var res []string
+		if act.Action == model.AddressBookBulkAddTags {
+			res = tags
+			for _, t := range act.Tags {
+				if !utils.InArray(t, res) {
+					res = append(res, t)
+				}
+			}
+			if err := s.bulkEnsureTags(tx, ab.UserId, ab.CollectionId, ab.CollectionId, act.Tags); err != nil {
+				return nil, err
+			}
+		} else {
+			res = []string{}
+			for _, t := range tags {
+				if !utils.InArray(t, act.Tags) {
+					res = append(res, t)
+				}
+			}
+		}
+		if sameSet(tags, res) {
+			return nil, nil
+		}
+		after.Tags, _ = json.Marshal(res)
+		return s.bulkUpdate(tx, ab, &after, "tags")
+	case model.AddressBookBulkSetAlias:
+		after.Alias = strings.NewReplacer(
+			"{id}", ab.Id, "{hostname}", ab.Hostname, "{username}", ab.Username,
+			"{platform}", ab.Platform, "{alias}", ab.Alias, "{n}", strconv.Itoa(n+1),
+		).Replace(act.Alias)
+		if after.Alias == ab.Alias {
+			return nil, nil
+		}
+		return s.bulkUpdate(tx, ab, &after, "alias")
+	case model.AddressBookBulkSetForceAlwaysRelay:
+		if ab.ForceAlwaysRelay == act.ForceAlwaysRelay {
+			return nil, nil
+		}
+		after.ForceAlwaysRelay = act.ForceAlwaysRelay
+		return s.bulkUpdate(tx, ab, &after, "force_always_relay")
+	case model.AddressBookBulkSetRdpPort:
+		if ab.RdpPort == act.RdpPort {
+			return nil, nil
+		}
+		after.RdpPort = act.RdpPort
+		return s.bulkUpdate(tx, ab, &after, "rdp_port")
+	case model.AddressBookBulkSetRdpUsername:
+		if ab.RdpUsername == act.RdpUsername {
+			return nil, nil
+		}
+		after.RdpUsername = act.RdpUsername
+		return s.bulkUpdate(tx, ab, &after, "rdp_username")
+	}
+	return nil, &bulkFail{"ParamsError"}
+}
+
+// bulkUpdate 只更新指定的字段, 用Select才能更新为零值
+func (s *AddressBookService) bulkUpdate(tx *gorm.DB, before, after *model.AddressBook, field string) ([]*model.AddressBook, error) {
+	if err := tx.Model(after).Select(field).Updates(after).Error; err != nil {
+		return nil, err
+	}
+	return []*model.AddressBook{after}, s.logPeerChange(tx, before, after)
+}
+
+// bulkEnsureTags 目标地址簿中没有的标签从来源地址簿复制颜色后创建
+func (s *AddressBookService) bulkEnsureTags(tx *gorm.DB, userId, fromCid, toCid uint, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var exists []*model.Tag
+	tx.Where("user_id = ? and collection_id = ? and name in ?", userId, toCid, names).Find(&exists)
+	var from []*model.Tag
+	if fromCid != toCid {
+		tx.Where("user_id = ? and collection_id = ? and name in ?", userId, fromCid, names).Find(&from)
+	}
+	for _, name := range names {
+		found := false
+		for _, t := range exists {
+			if t.Name == name {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		t := &model.Tag{Name: name, UserId: userId, CollectionId: toCid}
+		for _, f := range from {
+			if f.Name == name {
+				t.Color = f.Color
+				break
+			}
+		}
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		if err := s.logTagChange(tx, nil, t); err != nil {
+			return err
+		}
+		exists = append(exists, t)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func TestBulkSelectEscapesLike(t *testing.T) {
+	db := newTestDB(t, addressBookTestModels...)
+	db.Create(&[]*model.AddressBook{
+		{Id: "1", UserId: 1, Alias: "50% off", Hostname: "web_1"},
+		{Id: "2", UserId: 1, Alias: "500 off", Hostname: "webx1"},
+		{Id: "3", UserId: 1, Alias: "a!b", Hostname: "web_10"},
+	})
+	s := &AddressBookService{}
+	cases := []struct {
+		sel  model.AddressBookBulkSelector
+		want []string
+	}{
+		{model.AddressBookBulkSelector{Alias: "50%"}, []string{"1"}},
+		{model.AddressBookBulkSelector{Alias: "a!b"}, []string{"3"}},
+		{model.AddressBookBulkSelector{Hostname: "web_1"}, []string{"1", "3"}},
+		{model.AddressBookBulkSelector{Hostname: "web_?"}, []string{"1"}},
+		{model.AddressBookBulkSelector{Hostname: "web?1"}, []string{"1", "2"}},
+	}
+	for _, c := range cases {
+		abs, err := s.BulkSelect(1, &c.sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ab := range abs {
+			got = append(got, ab.Id)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.sel, got, c.want)
+		}
+	}
+}
+
+func bulkPeers(t *testing.T, abs ...*model.AddressBook) []*model.AddressBook {
+	t.Helper()
+	if err := DB.Create(&abs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
+
+func TestBulkRollsBackWhenOneItemFails(t *testing.T) {
+	newTestDB(t, append([]interface{}{&model.User{}, &model.Peer{}}, addressBookTestModels...)...)
+	s := &AddressBookService{}
+	smart := &model.AddressBookCollection{UserId: 1, Name: "smart", Smart: &model.AddressBookSmartFilter{Os: "linux"}}
+	DB.Create(smart)
+	abs := bulkPeers(t,
+		&model.AddressBook{Id: "1", UserId: 1, Alias: "a"},
+		&model.AddressBook{Id: "2", UserId: 1, Alias: "b", CollectionId: smart.Id},
+		&model.AddressBook{Id: "3", UserId: 1, Alias: "c"},
+	)
+	res, err := s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Applied || res.Failed != 1 || res.Ok != 2 || res.Items[1].Status != model.AddressBookBulkFailed {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	var n int64
+	DB.Model(&model.AddressBook{}).Count(&n)
+	if n != 3 {
+		t.Fatalf("delete not rolled back, %d rows left", n)
+	}
+	DB.Model(&model.AddressBookChange{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("changes not rolled back: %d", n)
+	}
+	if s.Revision(1, 0) != 0 {
+		t.Fatal("revision bumped on rollback")
+	}
+}
+
+func TestBulkTags(t *testing.T) {
+	newTestDB(t, addressBookTestModels...)
+	s := &AddressBookService{}
+	abs := bulkPeers(t,
+		&model.AddressBook{Id: "1", UserId: 1, Tags: []byte(`["a"]`)},
+		&model.AddressBook{Id: "2", UserId: 1, Tags: []byte(`["a","b"]`)},
+		&model.AddressBook{Id: "3", UserId: 1, Tags: []byte(`[]`)},
+	)
+	DB.Create(&model.Tag{Name: "a", Color: 5, UserId: 1})
+	tagsOf := func() map[string][]string {
+		var list []*model.AddressBook
+		DB.Order("row_id asc").Find(&list)
+		got := map[string][]string{}
+		for _, ab := range list {
+			got[ab.Id] = s.TagsOf(ab)
+		}
+		return got
+	}
+
+	res, err := s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkAddTags, Tags: []string{"b", "c"}})
+	if err != nil || !res.Applied || res.Ok != 3 {
+		t.Fatalf("add_tags: %+v %v", res, err)
+	}
+	want := map[string][]string{"1": {"a", "b", "c"}, "2": {"a", "b", "c"}, "3": {"b", "c"}}
+	if got := tagsOf(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after add_tags got %v, want %v", got, want)
+	}
+	// 不存在的标签自动创建, 已存在的保持颜色
+	var tags []*model.Tag
+	DB.Where("user_id = ? and collection_id = ?", 1, 0).Order("name asc").Find(&tags)
+	if len(tags) != 3 || tags[0].Name != "a" || tags[0].Color != 5 {
+		t.Fatalf("unexpected tags: %+v", tags)
+	}
+
+	DB.Order("row_id asc").Find(&abs)
+	res, err = s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkRemoveTags, Tags: []string{"a", "c"}})
+	if err != nil || !res.Applied || res.Ok != 3 {
+		t.Fatalf("remove_tags: %+v %v", res, err)
+	}
+	want = map[string][]string{"1": {"b"}, "2": {"b"}, "3": {"b"}}
+	if got := tagsOf(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after remove_tags got %v, want %v", got, want)
+	}
+
+	// 没有变化时跳过
+	DB.Order("row_id asc").Find(&abs)
+	res, err = s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkAddTags, Tags: []string{"b"}})
+	if err != nil || res.Skipped != 3 {
+		t.Fatalf("expected all skipped: %+v %v", res, err)
+	}
+}
+
+func TestBulkMoveRefused(t *testing.T) {
+	newTestDB(t, append([]interface{}{&model.User{}, &model.Peer{}}, addressBookTestModels...)...)
+	s := &AddressBookService{}
+	smart := &model.AddressBookCollection{UserId: 1, Name: "smart", Smart: &model.AddressBookSmartFilter{Os: "linux"}}
+	DB.Create(smart)
+	other := &model.AddressBookCollection{UserId: 2, Name: "other"}
+	DB.Create(other)
+	abs := bulkPeers(t, &model.AddressBook{Id: "1", UserId: 1}, &model.AddressBook{Id: "2", UserId: 1})
+
+	if _, err := s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkMove, CollectionId: smart.Id}); err != ErrSmartCollectionReadOnly {
+		t.Fatalf("move into smart collection: want ErrSmartCollectionReadOnly, got %v", err)
+	}
+	res, err := s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkMove, CollectionId: other.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Applied || res.Failed != 2 || res.Items[0].Error != "NoAccess" {
+		t.Fatalf("move into another owner's collection: %+v", res)
+	}
+	var n int64
+	DB.Model(&model.AddressBook{}).Where("collection_id = ?", 0).Count(&n)
+	if n != 2 {
+		t.Fatalf("entries moved: %d left in default collection", n)
+	}
+	if _, err = s.Bulk(nil, abs, &model.AddressBookBulkAction{Action: model.AddressBookBulkMove, CollectionId: 99}); err != ErrCollectionNotFound {
+		t.Fatalf("move into missing collection: want ErrCollectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+// ErrCollectionNotFound 恢复时地址簿不存在, 值为i18n的messageId
+var ErrCollectionNotFound = errors.New("ItemNotFound")
+
+type operatorKey struct{}
+
+// operatorDB 在context中记录操作人, 修改历史从事务的context中取, nil时为系统
+func operatorDB(op *model.User) *gorm.DB {
+	return DB.WithContext(context.WithValue(context.Background(), operatorKey{}, op))
+}
+
+// logChange 记录修改历史, before/after 为nil时不记录对应的值
+func (s *AddressBookService) logChange(tx *gorm.DB, userId, cid uint, target, targetId, action string, before, after interface{}) error {
+	ch := &model.AddressBookChange{
+		UserId:       userId,
+		CollectionId: cid,
+		Target:       target,
+		TargetId:     targetId,
+		Action:       action,
+	}
+	if tx.Statement.Context != nil {
+		if op, _ := tx.Statement.Context.Value(operatorKey{}).(*model.User); op != nil {
+			ch.OperatorId = op.Id
+			ch.Operator = op.Username
+		}
+	}
+	if before != nil {
+		b, _ := json.Marshal(before)
+		ch.Before = string(b)
+	}
+	if after != nil {
+		b, _ := json.Marshal(after)
+		ch.After = string(b)
+	}
+	return tx.Create(ch).Error
+}
+
+// logPeerChange 根据修改前后的设备记录, 没有变化时不记录
+func (s *AddressBookService) logPeerChange(tx *gorm.DB, before, after *model.AddressBook) error {
+	if before != nil && before.RowId == 0 {
+		before = nil
+	}
+	if after != nil && after.RowId == 0 {
+		after = nil
+	}
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		return s.logChange(tx, after.UserId, after.CollectionId, model.AddressBookChangeTargetPeer, after.Id, model.AddressBookChangeCreate, nil, after)
+	case after == nil:
+		return s.logChange(tx, before.UserId, before.CollectionId, model.AddressBookChangeTargetPeer, before.Id, model.AddressBookChangeDelete, before, nil)
+	case before.UserId != after.UserId || before.CollectionId != after.CollectionId:
+		// 移动到其他地址簿, 两边各记录一条
+		if err := s.logChange(tx, before.UserId, before.CollectionId, model.AddressBookChangeTargetPeer, before.Id, model.AddressBookChangeDelete, before, nil); err != nil {
+			return err
+		}
+		return s.logChange(tx, after.UserId, after.CollectionId, model.AddressBookChangeTargetPeer, after.Id, model.AddressBookChangeCreate, nil, after)
+	case !s.peerChanged(before, after) && before.Password == after.Password && before.Hash == after.Hash:
+		return nil
+	}
+	return s.logChange(tx, after.UserId, after.CollectionId, model.AddressBookChangeTargetPeer, after.Id, model.AddressBookChangeUpdate, before, after)
+}
+
+// logTagChange 根据修改前后的标签记录
+func (s *AddressBookService) logTagChange(tx *gorm.DB, before, after *model.Tag) error {
+	if before != nil && before.Id == 0 {
+		before = nil
+	}
+	if after != nil && after.Id == 0 {
+		after = nil
+	}
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		return s.logChange(tx, after.UserId, after.CollectionId, model.AddressBookChangeTargetTag, after.Name, model.AddressBookChangeCreate, nil, after)
+	case after == nil:
+		return s.logChange(tx, before.UserId, before.CollectionId, model.AddressBookChangeTargetTag, before.Name, model.AddressBookChangeDelete, before, nil)
+	case before.Name == after.Name && before.Color == after.Color && before.CollectionId == after.CollectionId:
+		return nil
+	}
+	return s.logChange(tx, after.UserId, after.CollectionId, model.AddressBookChangeTargetTag, after.Name, model.AddressBookChangeUpdate, before, after)
+}
+
+// logCollectionChange 地址簿本身的修改, 删除时记录完整内容
+func (s *AddressBookService) logCollectionChange(tx *gorm.DB, action string, before, after interface{}, c *model.AddressBookCollection) error {
+	return s.logChange(tx, c.UserId, c.Id, model.AddressBookChangeTargetCollection, strconv.Itoa(int(c.Id)), action, before, after)
+}
+
+// collectionState 地址簿的完整内容
+func (s *AddressBookService) collectionState(tx *gorm.DB, c *model.AddressBookCollection) *model.AddressBookCollectionState {
+	st := &model.AddressBookCollectionState{Collection: c}
+	st.AddressBookExport = *s.current(tx, c.UserId, c.Id)
+	tx.Where("collection_id = ?", c.Id).Find(&st.Rules)
+	return st
+}
+
+func (s *AddressBookService) ChangeInfoById(id uint) *model.AddressBookChange {
+	ch := &model.AddressBookChange{}
+	DB.Where("id = ?", id).First(ch)
+	return ch
+}
+
+func (s *AddressBookService) ChangeList(page, pageSize uint, where func(tx *gorm.DB)) (res *model.AddressBookChangeList) {
+	res = &model.AddressBookChangeList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.AddressBookChange{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.AddressBookChanges)
+	return
+}
+
+// RestoreEntry 把单个设备或标签恢复到这次修改之前的状态, 修改前不存在的会被删除
+func (s *AddressBookService) RestoreEntry(op *model.User, ch *model.AddressBookChange) error {
+	if ch.Target == model.AddressBookChangeTargetCollection {
+		return s.RestoreCollection(op, ch)
+	}
+	if ch.CollectionId > 0 && s.CollectionInfoById(ch.CollectionId).Id == 0 {
+		return ErrCollectionNotFound
+	}
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		switch ch.Target {
+		case model.AddressBookChangeTargetPeer:
+			before, after := &model.AddressBook{}, &model.AddressBook{}
+			if err := decodeChange(ch, before, after); err != nil {
+				return err
+			}
+			// 修改后的设备id可能和修改前不同, 先删除修改后的
+			if after.Id != "" && after.Id != before.Id {
+				ex := &model.AddressBook{}
+				tx.Where("user_id = ? and collection_id = ? and id = ?", ch.UserId, ch.CollectionId, after.Id).First(ex)
+				if ex.RowId > 0 {
+					if err := tx.Delete(ex).Error; err != nil {
+						return err
+					}
+					if err := s.logPeerChange(tx, ex, nil); err != nil {
+						return err
+					}
+				}
+			}
+			if before.Id != "" {
+				if err := s.restorePeer(tx, ch.UserId, ch.CollectionId, before); err != nil {
+					return err
+				}
+			}
+		case model.AddressBookChangeTargetTag:
+			before, after := &model.Tag{}, &model.Tag{}
+			if err := decodeChange(ch, before, after); err != nil {
+				return err
+			}
+			if after.Name != "" && after.Name != before.Name {
+				ex := &model.Tag{}
+				tx.Where("user_id = ? and collection_id = ? and name = ?", ch.UserId, ch.CollectionId, after.Name).First(ex)
+				if ex.Id > 0 {
+					if err := tx.Delete(ex).Error; err != nil {
+						return err
+					}
+					if err := s.logTagChange(tx, ex, nil); err != nil {
+						return err
+					}
+				}
+			}
+			if before.Name != "" {
+				ex := &model.Tag{}
+				tx.Where("user_id = ? and collection_id = ? and name = ?", ch.UserId, ch.CollectionId, before.Name).First(ex)
+				t := &model.Tag{Name: before.Name, Color: before.Color, UserId: ch.UserId, CollectionId: ch.CollectionId}
+				if ex.Id > 0 {
+					t.Id = ex.Id
+					if err := tx.Model(t).Update("color", t.Color).Error; err != nil {
+						return err
+					}
+				} else if err := tx.Create(t).Error; err != nil {
+					return err
+				}
+				if err := s.logTagChange(tx, ex, t); err != nil {
+					return err
+				}
+			}
+		default:
+			return errors.New("unknown change target")
+		}
+		_, err := s.BumpRevision(tx, ch.UserId, ch.CollectionId)
+		return err
+	})
+}
+
+// restorePeer 按设备id新增或整体覆盖
+func (s *AddressBookService) restorePeer(tx *gorm.DB, userId, cid uint, p *model.AddressBook) error {
+	ex := &model.AddressBook{}
+	tx.Where("user_id = ? and collection_id = ? and id = ?", userId, cid, p.Id).First(ex)
+	ab := *p
+	ab.UserId = userId
+	ab.CollectionId = cid
+	ab.Collection = nil
+	ab.RowId = ex.RowId
+	var err error
+	if ex.RowId > 0 {
+		err = tx.Model(&ab).Select("*").Omit("created_at").Updates(&ab).Error
+	} else {
+		err = tx.Create(&ab).Error
+	}
+	if err != nil {
+		return err
+	}
+	return s.logPeerChange(tx, ex, &ab)
+}
+
+// RestoreCollection 把整个地址簿恢复到这次修改之前的状态, 已删除的地址簿会重新创建
+// 从当前状态开始, 按时间倒序撤销这次及之后的所有修改
+func (s *AddressBookService) RestoreCollection(op *model.User, ch *model.AddressBookChange) error {
+	userId, cid := ch.UserId, ch.CollectionId
+	defer s.forgetPermissions()
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		cur := s.current(tx, userId, cid)
+		peers := peerMap(cur.Peers)
+		tags := s.parseTagColors(cur.TagColors)
+		coll := &model.AddressBookCollection{}
+		if cid > 0 {
+			tx.Where("id = ?", cid).First(coll)
+		}
+		var rules []*model.AddressBookCollectionRule
+
+		var changes []*model.AddressBookChange
+		tx.Where("user_id = ? and collection_id = ? and id >= ?", userId, cid, ch.Id).Order("id desc").Find(&changes)
+		for _, c := range changes {
+			switch c.Target {
+			case model.AddressBookChangeTargetPeer:
+				before, after := &model.AddressBook{}, &model.AddressBook{}
+				if err := decodeChange(c, before, after); err != nil {
+					return err
+				}
+				if after.Id != "" {
+					delete(peers, after.Id)
+				}
+				if before.Id != "" {
+					peers[before.Id] = before
+				}
+			case model.AddressBookChangeTargetTag:
+				before, after := &model.Tag{}, &model.Tag{}
+				if err := decodeChange(c, before, after); err != nil {
+					return err
+				}
+				if after.Name != "" {
+					delete(tags, after.Name)
+				}
+				if before.Name != "" {
+					tags[before.Name] = before.Color
+				}
+			case model.AddressBookChangeTargetCollection:
+				switch c.Action {
+				case model.AddressBookChangeDelete:
+					st := &model.AddressBookCollectionState{}
+					if err := json.Unmarshal([]byte(c.Before), st); err != nil {
+						return err
+					}
+					if st.Collection != nil {
+						coll = st.Collection
+					}
+					rules = st.Rules
+					peers = peerMap(st.Peers)
+					tags = s.parseTagColors(st.TagColors)
+				case model.AddressBookChangeUpdate:
+					before := &model.AddressBookCollection{}
+					if err := json.Unmarshal([]byte(c.Before), before); err != nil {
+						return err
+					}
+					coll.Name = before.Name
+				case model.AddressBookChangeCreate:
+					// 创建之前不存在, 更早的修改中有删除时会恢复删除前的内容
+					coll = &model.AddressBookCollection{}
+					peers = map[string]*model.AddressBook{}
+					tags = map[string]uint{}
+				}
+			}
+		}
+
+		if cid > 0 {
+			if coll.Id == 0 {
+				return ErrCollectionNotFound
+			}
+			ex := &model.AddressBookCollection{}
+			tx.Where("id = ?", cid).First(ex)
+			if ex.Id == 0 {
+				coll.Id = cid
+				coll.UserId = userId
+				if err := tx.Create(coll).Error; err != nil {
+					return err
+				}
+				if err := s.logCollectionChange(tx, model.AddressBookChangeCreate, nil, coll, coll); err != nil {
+					return err
+				}
+				for _, r := range rules {
+					r.Id = 0
+					if err := tx.Create(r).Error; err != nil {
+						return err
+					}
+				}
+			} else if ex.Name != coll.Name {
+				if err := tx.Model(ex).Update("name", coll.Name).Error; err != nil {
+					return err
+				}
+				after := *ex
+				after.Name = coll.Name
+				if err := s.logCollectionChange(tx, model.AddressBookChangeUpdate, ex, &after, ex); err != nil {
+					return err
+				}
+			}
+		}
+
+		list := make([]*model.AddressBook, 0, len(peers))
+		for _, p := range peers {
+			list = append(list, p)
+		}
+		if err := s.replacePeers(tx, userId, cid, list); err != nil {
+			return err
+		}
+		if err := s.replaceTags(tx, userId, cid, tags); err != nil {
+			return err
+		}
+		_, err := s.BumpRevision(tx, userId, cid)
+		return err
+	})
+}
+
+// decodeChange 解析修改前后的值, 为空时保持零值
+func decodeChange(ch *model.AddressBookChange, before, after interface{}) error {
+	if ch.Before != "" {
+		if err := json.Unmarshal([]byte(ch.Before), before); err != nil {
+			return err
+		}
+	}
+	if ch.After != "" {
+		if err := json.Unmarshal([]byte(ch.After), after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func newHistoryTestDB(t *testing.T) {
+	models := append([]interface{}{
+		&model.User{}, &model.Peer{}, &model.AddressBookCollectionRule{}, &model.AddressBookInvitation{},
+	}, addressBookTestModels...)
+	newTestDB(t, models...)
+}
+
+// lastChange 最新的一条修改记录
+func lastChange(t *testing.T, target, action string) *model.AddressBookChange {
+	t.Helper()
+	ch := &model.AddressBookChange{}
+	DB.Where("target = ? and action = ?", target, action).Order("id desc").First(ch)
+	if ch.Id == 0 {
+		t.Fatalf("no %s %s change recorded", target, action)
+	}
+	return ch
+}
+
+func peerOf(userId, cid uint, id string) *model.AddressBook {
+	ab := &model.AddressBook{}
+	DB.Where("user_id = ? and collection_id = ? and id = ?", userId, cid, id).First(ab)
+	return ab
+}
+
+func TestRestoreEntryPeer(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	ab := &model.AddressBook{Id: "123", UserId: 1, Alias: "a", Tags: []byte(`["x"]`)}
+	if err := s.Create(nil, ab); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateByMap(nil, ab, map[string]interface{}{"alias": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	rev := s.Revision(1, 0)
+
+	// 修改: 恢复到修改前的别名
+	if err := s.RestoreEntry(nil, lastChange(t, model.AddressBookChangeTargetPeer, model.AddressBookChangeUpdate)); err != nil {
+		t.Fatal(err)
+	}
+	got := peerOf(1, 0, "123")
+	if got.RowId != ab.RowId || got.Alias != "a" || string(got.Tags) != `["x"]` {
+		t.Fatalf("unexpected restored peer: %+v", got)
+	}
+	if s.Revision(1, 0) != rev+1 {
+		t.Fatal("restore did not bump the revision")
+	}
+
+	// 删除: 重新创建
+	if err := s.Delete(nil, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreEntry(nil, lastChange(t, model.AddressBookChangeTargetPeer, model.AddressBookChangeDelete)); err != nil {
+		t.Fatal(err)
+	}
+	got = peerOf(1, 0, "123")
+	if got.RowId == 0 || got.Alias != "a" {
+		t.Fatalf("deleted peer not restored: %+v", got)
+	}
+
+	// 新增: 恢复到新增之前即删除
+	if err := s.Create(nil, &model.AddressBook{Id: "456", UserId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreEntry(nil, lastChange(t, model.AddressBookChangeTargetPeer, model.AddressBookChangeCreate)); err != nil {
+		t.Fatal(err)
+	}
+	if peerOf(1, 0, "456").RowId != 0 {
+		t.Fatal("created peer not removed")
+	}
+	var n int64
+	DB.Model(&model.AddressBook{}).Where("user_id = ?", 1).Count(&n)
+	if n != 1 {
+		t.Fatalf("want 1 peer, got %d", n)
+	}
+}
+
+func TestRestoreEntryTag(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	if _, err := s.UpdateAddressBook(nil, 1, &model.AddressBookExport{Tags: []string{"x"}, TagColors: `{"x":1}`}, -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateAddressBook(nil, 1, &model.AddressBookExport{Tags: []string{"x"}, TagColors: `{"x":2}`}, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreEntry(nil, lastChange(t, model.AddressBookChangeTargetTag, model.AddressBookChangeUpdate)); err != nil {
+		t.Fatal(err)
+	}
+	var tags []*model.Tag
+	DB.Where("user_id = ? and collection_id = ?", 1, 0).Find(&tags)
+	if len(tags) != 1 || tags[0].Name != "x" || tags[0].Color != 1 {
+		t.Fatalf("unexpected tags after restoring update: %+v", tags)
+	}
+
+	if _, err := s.UpdateAddressBook(nil, 1, &model.AddressBookExport{Tags: []string{}, TagColors: `{}`}, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreEntry(nil, lastChange(t, model.AddressBookChangeTargetTag, model.AddressBookChangeDelete)); err != nil {
+		t.Fatal(err)
+	}
+	tags = nil
+	DB.Where("user_id = ? and collection_id = ?", 1, 0).Find(&tags)
+	if len(tags) != 1 || tags[0].Name != "x" || tags[0].Color != 1 {
+		t.Fatalf("unexpected tags after restoring delete: %+v", tags)
+	}
+}
+
+func TestRestoreEntryCollectionGone(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	c := &model.AddressBookCollection{UserId: 1, Name: "c"}
+	if err := s.CreateCollection(nil, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(nil, &model.AddressBook{Id: "123", UserId: 1, CollectionId: c.Id}); err != nil {
+		t.Fatal(err)
+	}
+	ch := lastChange(t, model.AddressBookChangeTargetPeer, model.AddressBookChangeCreate)
+	DB.Delete(c)
+	if err := s.RestoreEntry(nil, ch); err != ErrCollectionNotFound {
+		t.Fatalf("want ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestRestoreCollectionDeleted(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	c := &model.AddressBookCollection{UserId: 1, Name: "c"}
+	if err := s.CreateCollection(nil, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(nil, &model.AddressBook{Id: "123", UserId: 1, CollectionId: c.Id, Alias: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	DB.Create(&model.Tag{Name: "x", Color: 3, UserId: 1, CollectionId: c.Id})
+	DB.Create(&model.AddressBookCollectionRule{UserId: 1, CollectionId: c.Id, Type: model.ShareAddressBookRuleTypePersonal, ToId: 2, Rule: model.ShareAddressBookRuleRuleRead})
+
+	if err := s.DeleteCollection(nil, c); err != nil {
+		t.Fatal(err)
+	}
+	if s.CollectionInfoById(c.Id).Id != 0 || peerOf(1, c.Id, "123").RowId != 0 {
+		t.Fatal("collection not deleted")
+	}
+	if err := s.RestoreCollection(nil, lastChange(t, model.AddressBookChangeTargetCollection, model.AddressBookChangeDelete)); err != nil {
+		t.Fatal(err)
+	}
+	got := s.CollectionInfoById(c.Id)
+	if got.Id != c.Id || got.Name != "c" || got.UserId != 1 {
+		t.Fatalf("unexpected restored collection: %+v", got)
+	}
+	if p := peerOf(1, c.Id, "123"); p.RowId == 0 || p.Alias != "a" {
+		t.Fatalf("peer not restored: %+v", p)
+	}
+	var tags []*model.Tag
+	DB.Where("collection_id = ?", c.Id).Find(&tags)
+	if len(tags) != 1 || tags[0].Name != "x" || tags[0].Color != 3 {
+		t.Fatalf("unexpected restored tags: %+v", tags)
+	}
+	var rules []*model.AddressBookCollectionRule
+	DB.Where("collection_id = ?", c.Id).Find(&rules)
+	if len(rules) != 1 || rules[0].ToId != 2 {
+		t.Fatalf("unexpected restored rules: %+v", rules)
+	}
+	if s.Revision(1, c.Id) == 0 {
+		t.Fatal("restore did not bump the revision")
+	}
+}
+
+func TestRestoreCollectionUpdated(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	c := &model.AddressBookCollection{UserId: 1, Name: "c"}
+	if err := s.CreateCollection(nil, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(nil, &model.AddressBook{Id: "123", UserId: 1, CollectionId: c.Id, Alias: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Name = "renamed"
+	if err := s.UpdateCollection(nil, c); err != nil {
+		t.Fatal(err)
+	}
+	ch := lastChange(t, model.AddressBookChangeTargetCollection, model.AddressBookChangeUpdate)
+	// 重命名之后的修改也会被撤销
+	ab := peerOf(1, c.Id, "123")
+	if err := s.UpdateByMap(nil, ab, map[string]interface{}{"alias": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(nil, &model.AddressBook{Id: "456", UserId: 1, CollectionId: c.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RestoreCollection(nil, ch); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.CollectionInfoById(c.Id); got.Name != "c" {
+		t.Fatalf("collection name not restored: %q", got.Name)
+	}
+	if p := peerOf(1, c.Id, "123"); p.Alias != "a" {
+		t.Fatalf("peer alias not restored: %q", p.Alias)
+	}
+	if peerOf(1, c.Id, "456").RowId != 0 {
+		t.Fatal("peer added after the change not removed")
+	}
+}
+
+// TestChangeOperator 修改历史记录传入的操作人, 不传时为系统
+func TestChangeOperator(t *testing.T) {
+	newHistoryTestDB(t)
+	s := &AddressBookService{}
+	op := &model.User{IdModel: model.IdModel{Id: 7}, Username: "admin"}
+	if err := s.Create(op, &model.AddressBook{Id: "123", UserId: 1, Tags: []byte(`[]`)}); err != nil {
+		t.Fatal(err)
+	}
+	if ch := lastChange(t, model.AddressBookChangeTargetPeer, model.AddressBookChangeCreate); ch.OperatorId != op.Id || ch.Operator != op.Username {
+		t.Fatalf("peer change operator = %d %q", ch.OperatorId, ch.Operator)
+	}
+	if err := (&TagService{}).Create(op, &model.Tag{UserId: 1, Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if ch := lastChange(t, model.AddressBookChangeTargetTag, model.AddressBookChangeCreate); ch.OperatorId != op.Id {
+		t.Fatalf("tag change operator = %d", ch.OperatorId)
+	}
+	if err := s.CreateCollection(nil, &model.AddressBookCollection{UserId: 1, Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if ch := lastChange(t, model.AddressBookChangeTargetCollection, model.AddressBookChangeCreate); ch.OperatorId != 0 || ch.Operator != "" {
+		t.Fatalf("system change operator = %d %q", ch.OperatorId, ch.Operator)
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/lejianwen/rustdesk-api/v2/lib/mail"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+type AddressBookInvitationService struct {
+}
+
+// 错误的值为i18n的messageId
+var (
+	ErrInvitationInvalid       = errors.New("InvitationInvalid")
+	ErrInvitationEmailMismatch = errors.New("InvitationEmailMismatch")
+)
+
+// InvitationDefaultTTL 邀请默认的有效期
+const InvitationDefaultTTL = 7 * 24 * time.Hour
+
+// HashToken token只保存sha256
+func (is *AddressBookInvitationService) HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func (is *AddressBookInvitationService) InfoById(id uint) *model.AddressBookInvitation {
+	inv := &model.AddressBookInvitation{}
+	DB.Where("id = ?", id).First(inv)
+	inv.Status = inv.StatusAt(time.Now().Unix())
+	return inv
+}
+
+// InfoByToken 根据明文token取邀请, 不存在时Id为0
+func (is *AddressBookInvitationService) InfoByToken(token string) *model.AddressBookInvitation {
+	inv := &model.AddressBookInvitation{}
+	if token == "" {
+		return inv
+	}
+	DB.Where("token_hash = ?", is.HashToken(token)).First(inv)
+	inv.Status = inv.StatusAt(time.Now().Unix())
+	return inv
+}
+
+func (is *AddressBookInvitationService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.AddressBookInvitationList) {
+	res = &model.AddressBookInvitationList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.AddressBookInvitation{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.AddressBookInvitations)
+	now := time.Now().Unix()
+	for _, inv := range res.AddressBookInvitations {
+		inv.Status = inv.StatusAt(now)
+	}
+	return
+}
+
+// WhereStatus 按状态筛选
+func (is *AddressBookInvitationService) WhereStatus(tx *gorm.DB, status string) {
+	now := time.Now().Unix()
+	switch status {
+	case model.AddressBookInvitationPending:
+		tx.Where("accepted_at = 0 and (expired_at = 0 or expired_at > ?)", now)
+	case model.AddressBookInvitationExpired:
+		tx.Where("accepted_at = 0 and expired_at > 0 and expired_at <= ?", now)
+	case model.AddressBookInvitationAccepted:
+		tx.Where("accepted_at > 0")
+	}
+}
+
+// Create 创建邀请, 明文token只在创建和重新发送时返回
+// 同一个地址簿对同一个邮箱只能有一个未接受的邀请
+func (is *AddressBookInvitationService) Create(inv *model.AddressBookInvitation) (string, error) {
+	inv.Email = strings.TrimSpace(inv.Email)
+	ex := &model.AddressBookInvitation{}
+	DB.Where("collection_id = ? and email = ? and accepted_at = 0", inv.CollectionId, inv.Email).First(ex)
+	if ex.Id > 0 {
+		return "", errors.New("ItemExists")
+	}
+	token, err := is.newToken()
+	if err != nil {
+		return "", err
+	}
+	inv.TokenHash = is.HashToken(token)
+	if inv.ExpiredAt == 0 {
+		inv.ExpiredAt = time.Now().Add(InvitationDefaultTTL).Unix()
+	}
+	if err := DB.Create(inv).Error; err != nil {
+		return "", err
+	}
+	inv.Status = inv.StatusAt(time.Now().Unix())
+	return token, nil
+}
+
+// Renew 重新生成token并延长有效期, 旧的链接失效
+func (is *AddressBookInvitationService) Renew(inv *model.AddressBookInvitation) (string, error) {
+	if inv.AcceptedAt > 0 {
+		return "", ErrInvitationInvalid
+	}
+	token, err := is.newToken()
+	if err != nil {
+		return "", err
+	}
+	inv.TokenHash = is.HashToken(token)
+	inv.ExpiredAt = time.Now().Add(InvitationDefaultTTL).Unix()
+	if err := DB.Model(inv).Updates(map[string]interface{}{"token_hash": inv.TokenHash, "expired_at": inv.ExpiredAt}).Error; err != nil {
+		return "", err
+	}
+	inv.Status = inv.StatusAt(time.Now().Unix())
+	return token, nil
+}
+
+func (is *AddressBookInvitationService) newToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// AcceptUrl 接受邀请的页面地址
+func (is *AddressBookInvitationService) AcceptUrl(token string) string {
+	return Config.Rustdesk.ApiServer + "/_admin/#/invitation/" + token
+}
+
+// MailEnabled 是否可以发送邀请邮件
+func (is *AddressBookInvitationService) MailEnabled() bool {
+	return is.mailer().Enabled()
+}
+
+func (is *AddressBookInvitationService) mailer() *mail.Smtp {
+	m := Config.Mail
+	return &mail.Smtp{Host: m.Host, Port: m.Port, Username: m.Username, Password: m.Password, From: m.From, Tls: m.Tls}
+}
+
+// Send 发送邀请邮件
+func (is *AddressBookInvitationService) Send(inv *model.AddressBookInvitation, token string) error {
+	inviter := AllService.UserService.InfoById(inv.UserId)
+	collection := AllService.AddressBookService.CollectionInfoById(inv.CollectionId)
+	subject := fmt.Sprintf("%s invited you to the address book \"%s\"", inviter.Username, collection.Name)
+	body := fmt.Sprintf("%s invited you to the RustDesk address book \"%s\".\n\n"+
+		"Open the link below to accept the invitation. You can sign in with an existing account or create a new one.\n\n%s\n\n"+
+		"The invitation expires at %s.\n",
+		inviter.Username, collection.Name, is.AcceptUrl(token), time.Unix(inv.ExpiredAt, 0).Format("2006-01-02 15:04:05 MST"))
+	if err := is.mailer().Send(inv.Email, subject, body); err != nil {
+		return err
+	}
+	inv.SentAt = time.Now().Unix()
+	return DB.Model(inv).Update("sent_at", inv.SentAt).Error
+}
+
+// CanRegister 是否可以通过邀请注册新用户, 开放注册或者邀请人是管理员
+func (is *AddressBookInvitationService) CanRegister(inv *model.AddressBookInvitation) bool {
+	if Config.App.Register {
+		return true
+	}
+	return AllService.UserService.IsAdmin(AllService.UserService.InfoById(inv.UserId))
+}
+
+// RegisterStatus 通过邀请注册的用户的状态, 邀请人是管理员时直接启用
+// 否则和普通注册一样按 app.register-status, 需要管理员审核时为禁用
+func (is *AddressBookInvitationService) RegisterStatus(inv *model.AddressBookInvitation) model.StatusCode {
+	if AllService.UserService.IsAdmin(AllService.UserService.InfoById(inv.UserId)) {
+		return model.COMMON_STATUS_ENABLE
+	}
+	status := model.StatusCode(Config.App.RegisterStatus)
+	if status != model.COMMON_STATUS_DISABLED {
+		status = model.COMMON_STATUS_ENABLE
+	}
+	return status
+}
+
+// Accept 用户接受邀请, 创建个人共享规则, 已有规则时保留较大的权限
+// 用户有邮箱时必须和邀请的邮箱一致
+func (is *AddressBookInvitationService) Accept(inv *model.AddressBookInvitation, u *model.User) error {
+	if inv.Id == 0 || inv.StatusAt(time.Now().Unix()) != model.AddressBookInvitationPending {
+		return ErrInvitationInvalid
+	}
+	if u.Email != "" && !strings.EqualFold(u.Email, inv.Email) {
+		return ErrInvitationEmailMismatch
+	}
+	if u.Id == inv.UserId {
+		return errors.New("CannotShareToSelf")
+	}
+	defer AllService.AddressBookService.forgetPermissions()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		c := &model.AddressBookCollection{}
+		tx.Where("id = ? and user_id = ?", inv.CollectionId, inv.UserId).First(c)
+		if c.Id == 0 {
+			return ErrInvitationInvalid
+		}
+		now := time.Now().Unix()
+		res := tx.Model(inv).Where("accepted_at = 0").Updates(map[string]interface{}{"accepted_at": now, "accepted_user_id": u.Id})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+		inv.AcceptedAt = now
+		inv.AcceptedUserId = u.Id
+		inv.Status = model.AddressBookInvitationAccepted
+
+		rule := &model.AddressBookCollectionRule{}
+		tx.Where("type = ? and to_id = ? and collection_id = ?", model.ShareAddressBookRuleTypePersonal, u.Id, c.Id).First(rule)
+		if rule.Id > 0 {
+			if rule.Rule >= inv.Rule {
+				return nil
+			}
+			return tx.Model(rule).Update("rule", inv.Rule).Error
+		}
+		return tx.Create(&model.AddressBookCollectionRule{
+			UserId:       inv.UserId,
+			CollectionId: c.Id,
+			Rule:         inv.Rule,
+			Type:         model.ShareAddressBookRuleTypePersonal,
+			ToId:         u.Id,
+		}).Error
+	})
+}
+
+func (is *AddressBookInvitationService) Delete(inv *model.AddressBookInvitation) error {
+	return DB.Delete(inv).Error
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func TestInvitationRegisterStatus(t *testing.T) {
+	newTestDB(t, &model.User{})
+	is := &AddressBookInvitationService{}
+	yes := true
+	admin := &model.User{Username: "admin", IsAdmin: &yes}
+	DB.Create(admin)
+	user := &model.User{Username: "user"}
+	DB.Create(user)
+
+	Config.App.Register = true
+	Config.App.RegisterStatus = int(model.COMMON_STATUS_DISABLED)
+	if s := is.RegisterStatus(&model.AddressBookInvitation{UserId: user.Id}); s != model.COMMON_STATUS_DISABLED {
+		t.Fatalf("non-admin invitation skipped approval: %v", s)
+	}
+	if s := is.RegisterStatus(&model.AddressBookInvitation{UserId: admin.Id}); s != model.COMMON_STATUS_ENABLE {
+		t.Fatalf("admin invitation needs approval: %v", s)
+	}
+	// 未配置时默认启用
+	Config.App.RegisterStatus = 0
+	if s := is.RegisterStatus(&model.AddressBookInvitation{UserId: user.Id}); s != model.COMMON_STATUS_ENABLE {
+		t.Fatalf("default register status = %v", s)
+	}
+}
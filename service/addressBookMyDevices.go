@@ -0,0 +1,75 @@
+package service
+
+import (
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"reflect"
+)
+
+// MyDevicesCollectionName "我的设备"地址簿的名称
+const MyDevicesCollectionName = "My devices"
+
+// MyDevicesEnabled 是否为用户维护"我的设备"地址簿, 全局开启或者用户所在分组开启
+func (s *AddressBookService) MyDevicesEnabled(u *model.User) bool {
+	if Config.App.MyDevices {
+		return true
+	}
+	g := AllService.GroupService.InfoById(u.GroupId)
+	return g.MyDevices != nil && *g.MyDevices
+}
+
+// MyDevicesCollection 用户的"我的设备"地址簿
+func (s *AddressBookService) MyDevicesCollection(userId uint) *model.AddressBookCollection {
+	c := &model.AddressBookCollection{}
+	DB.Where("user_id = ? and auto = ?", userId, model.AddressBookCollectionAutoMyDevices).First(c)
+	return c
+}
+
+// SyncMyDevices 同步用户的"我的设备"地址簿
+// 开启时不存在则创建, 是包含用户所有设备的智能地址簿; 已存在且设备有变化时更新版本号, 让客户端重新拉取
+// 关闭时删除
+func (s *AddressBookService) SyncMyDevices(userId uint) error {
+	if userId == 0 {
+		return nil
+	}
+	u := AllService.UserService.InfoById(userId)
+	if u.Id == 0 {
+		return nil
+	}
+	c := s.MyDevicesCollection(u.Id)
+	if !s.MyDevicesEnabled(u) {
+		if c.Id == 0 {
+			return nil
+		}
+		return s.DeleteCollection(nil, c)
+	}
+	filter := &model.AddressBookSmartFilter{UserIds: []uint{u.Id}}
+	if c.Id == 0 {
+		return s.CreateCollection(nil, &model.AddressBookCollection{
+			UserId: u.Id,
+			Name:   MyDevicesCollectionName,
+			Smart:  filter,
+			Auto:   model.AddressBookCollectionAutoMyDevices,
+		})
+	}
+	if !reflect.DeepEqual(c.Smart, filter) {
+		c.Smart = filter
+		return s.UpdateCollection(nil, c)
+	}
+	// 每次上传设备信息都会调用, 设备没有变化时不更新版本
+	if !s.snapshotChanged(DB, u.Id, c.Id) {
+		return nil
+	}
+	_, err := s.BumpRevision(DB, u.Id, c.Id)
+	return err
+}
+
+// SyncMyDevicesOfGroup 分组的"我的设备"开关变化或者分组删除后同步组内所有用户
+func (s *AddressBookService) SyncMyDevicesOfGroup(groupId uint) {
+	var ids []uint
+	DB.Model(&model.User{}).Where("group_id = ?", groupId).Pluck("id", &ids)
+	for _, id := range ids {
+		if err := s.SyncMyDevices(id); err != nil {
+			Logger.Warn("sync my devices failed, user: ", id, ", err: ", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func newMyDevicesTestDB(t *testing.T) {
+	models := append([]interface{}{
+		&model.User{}, &model.Group{}, &model.Peer{}, &model.RoleBinding{},
+		&model.AddressBookCollectionRule{}, &model.AddressBookInvitation{},
+	}, addressBookTestModels...)
+	newTestDB(t, models...)
+}
+
+func TestSyncMyDevices(t *testing.T) {
+	newMyDevicesTestDB(t)
+	s := &AddressBookService{}
+	on := true
+	g := &model.Group{Name: "g", MyDevices: &on}
+	DB.Create(g)
+	u := &model.User{Username: "u", GroupId: g.Id}
+	DB.Create(u)
+	p := &model.Peer{Id: "123", Hostname: "a", UserId: u.Id}
+	DB.Create(p)
+
+	if err := s.SyncMyDevices(u.Id); err != nil {
+		t.Fatal(err)
+	}
+	c := s.MyDevicesCollection(u.Id)
+	if c.Id == 0 || !c.IsSmart() {
+		t.Fatalf("my devices collection not created: %+v", c)
+	}
+	if err := s.SyncMyDevices(u.Id); err != nil {
+		t.Fatal(err)
+	}
+	rev := s.Revision(u.Id, c.Id)
+	if rev == 0 {
+		t.Fatal("revision not bumped on first sync")
+	}
+	// 设备没有变化, 不更新版本
+	for i := 0; i < 3; i++ {
+		if err := s.SyncMyDevices(u.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.Revision(u.Id, c.Id); got != rev {
+		t.Fatalf("revision bumped without changes: %d -> %d", rev, got)
+	}
+	DB.Model(p).Update("hostname", "b")
+	if err := s.SyncMyDevices(u.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Revision(u.Id, c.Id); got != rev+1 {
+		t.Fatalf("revision after hostname change = %d, want %d", got, rev+1)
+	}
+	DB.Create(&model.Peer{Id: "456", Hostname: "c", UserId: u.Id})
+	if err := s.SyncMyDevices(u.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Revision(u.Id, c.Id); got != rev+2 {
+		t.Fatalf("revision after new peer = %d, want %d", got, rev+2)
+	}
+}
+
+func TestSyncMyDevicesOnGroupToggle(t *testing.T) {
+	newMyDevicesTestDB(t)
+	s := &AddressBookService{}
+	gs := &GroupService{}
+	off, on := false, true
+	g := &model.Group{Name: "g", MyDevices: &off}
+	DB.Create(g)
+	// gorm 的 default:0 会忽略false, 单独更新
+	DB.Model(g).Update("my_devices", false)
+	u := &model.User{Username: "u", GroupId: g.Id}
+	DB.Create(u)
+	other := &model.User{Username: "o"}
+	DB.Create(other)
+
+	if err := gs.Update(&model.Group{IdModel: model.IdModel{Id: g.Id}, MyDevices: &on}); err != nil {
+		t.Fatal(err)
+	}
+	if s.MyDevicesCollection(u.Id).Id == 0 {
+		t.Fatal("enabling the group did not create my devices")
+	}
+	if s.MyDevicesCollection(other.Id).Id != 0 {
+		t.Fatal("user outside the group got my devices")
+	}
+	if err := gs.Update(&model.Group{IdModel: model.IdModel{Id: g.Id}, MyDevices: &off}); err != nil {
+		t.Fatal(err)
+	}
+	if s.MyDevicesCollection(u.Id).Id != 0 {
+		t.Fatal("disabling the group did not delete my devices")
+	}
+
+	// 移入开启的分组时创建
+	g2 := &model.Group{Name: "g2", MyDevices: &on}
+	DB.Create(g2)
+	if err := AllService.UserService.Update(&model.User{IdModel: model.IdModel{Id: other.Id}, GroupId: g2.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if s.MyDevicesCollection(other.Id).Id == 0 {
+		t.Fatal("moving the user into an enabled group did not create my devices")
+	}
+	// 删除分组后删除
+	if err := gs.Delete(gs.InfoById(g2.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if s.MyDevicesCollection(other.Id).Id != 0 {
+		t.Fatal("deleting the group did not delete my devices")
+	}
+}
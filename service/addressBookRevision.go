@@ -0,0 +1,339 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+)
+
+// ErrAddressBookConflict 提交的版本不是最新且无法自动合并, 值为i18n的messageId
+var ErrAddressBookConflict = errors.New("AddressBookConflict")
+
+// addressBookSnapshotKeep 每个地址簿保留的快照数量, 客户端的版本比这更旧时不能合并
+const addressBookSnapshotKeep = 20
+
+// Revision 当前版本号, 从未修改过为0
+func (s *AddressBookService) Revision(userId, cid uint) int64 {
+	return s.revision(DB, userId, cid)
+}
+
+func (s *AddressBookService) revision(tx *gorm.DB, userId, cid uint) int64 {
+	r := &model.AddressBookRevision{}
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).First(r)
+	return r.Revision
+}
+
+// BumpRevision 修改后调用, 版本号加一并保存快照
+func (s *AddressBookService) BumpRevision(tx *gorm.DB, userId, cid uint) (int64, error) {
+	return s.bumpRevisionFrom(tx, userId, cid, -1)
+}
+
+// bumpRevisionFrom expect >= 0 时只有当前版本等于expect才更新, 防止并发写入
+func (s *AddressBookService) bumpRevisionFrom(tx *gorm.DB, userId, cid uint, expect int64) (int64, error) {
+	r := &model.AddressBookRevision{}
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).First(r)
+	if r.Id == 0 {
+		if expect > 0 {
+			return 0, ErrAddressBookConflict
+		}
+		r.UserId = userId
+		r.CollectionId = cid
+		r.Revision = 1
+		if err := tx.Create(r).Error; err != nil {
+			return 0, err
+		}
+	} else {
+		q := tx.Model(&model.AddressBookRevision{}).Where("id = ?", r.Id)
+		if expect >= 0 {
+			q.Where("revision = ?", expect)
+		}
+		res := q.UpdateColumn("revision", gorm.Expr("revision + ?", 1))
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return 0, ErrAddressBookConflict
+		}
+		tx.Where("id = ?", r.Id).First(r)
+	}
+	data, _ := json.Marshal(s.snapshot(tx, userId, cid))
+	snap := &model.AddressBookSnapshot{UserId: userId, CollectionId: cid, Revision: r.Revision, Data: string(data)}
+	if err := tx.Create(snap).Error; err != nil {
+		return 0, err
+	}
+	tx.Where("user_id = ? and collection_id = ? and revision <= ?", userId, cid, r.Revision-addressBookSnapshotKeep).Delete(&model.AddressBookSnapshot{})
+	return r.Revision, nil
+}
+
+// bumpRevisions 批量修改时按 用户+地址簿 去重后更新版本
+func (s *AddressBookService) bumpRevisions(tx *gorm.DB, abs []*model.AddressBook) error {
+	done := map[[2]uint]bool{}
+	for _, ab := range abs {
+		k := [2]uint{ab.UserId, ab.CollectionId}
+		if done[k] {
+			continue
+		}
+		done[k] = true
+		if _, err := s.BumpRevision(tx, ab.UserId, ab.CollectionId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// current 当前的设备和标签
+func (s *AddressBookService) current(tx *gorm.DB, userId, cid uint) *model.AddressBookExport {
+	res := &model.AddressBookExport{Tags: []string{}, Peers: []*model.AddressBook{}}
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Order("row_id asc").Find(&res.Peers)
+	var tags []*model.Tag
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Order("name asc").Find(&tags)
+	colors := map[string]uint{}
+	for _, t := range tags {
+		res.Tags = append(res.Tags, t.Name)
+		colors[t.Name] = t.Color
+	}
+	tc, _ := json.Marshal(colors)
+	res.TagColors = string(tc)
+	return res
+}
+
+// snapshot 快照不保存密码; 智能地址簿保存生成的设备, 用于判断内容是否变化
+func (s *AddressBookService) snapshot(tx *gorm.DB, userId, cid uint) *model.AddressBookExport {
+	var res *model.AddressBookExport
+	c := &model.AddressBookCollection{}
+	if cid > 0 {
+		tx.Where("id = ?", cid).First(c)
+	}
+	if c.IsSmart() {
+		res = &model.AddressBookExport{Tags: []string{}, Peers: []*model.AddressBook{}, TagColors: "{}"}
+		res.Peers = append(res.Peers, s.SmartPeers(c).AddressBooks...)
+	} else {
+		res = s.current(tx, userId, cid)
+	}
+	for _, ab := range res.Peers {
+		ab.Password = ""
+		ab.Hash = ""
+	}
+	return res
+}
+
+// snapshotChanged 当前内容和最新版本的快照是否不同, 没有快照时视为不同
+func (s *AddressBookService) snapshotChanged(tx *gorm.DB, userId, cid uint) bool {
+	snap := &model.AddressBookSnapshot{}
+	tx.Where("user_id = ? and collection_id = ? and revision = ?", userId, cid, s.revision(tx, userId, cid)).First(snap)
+	if snap.Id == 0 {
+		return true
+	}
+	data, _ := json.Marshal(s.snapshot(tx, userId, cid))
+	return string(data) != snap.Data
+}
+
+// SnapshotAt 取某个版本的快照, 不存在时返回nil
+func (s *AddressBookService) SnapshotAt(userId, cid uint, rev int64) *model.AddressBookExport {
+	return s.snapshotAt(DB, userId, cid, rev)
+}
+
+func (s *AddressBookService) snapshotAt(tx *gorm.DB, userId, cid uint, rev int64) *model.AddressBookExport {
+	if rev == 0 {
+		return &model.AddressBookExport{Tags: []string{}, Peers: []*model.AddressBook{}, TagColors: "{}"}
+	}
+	snap := &model.AddressBookSnapshot{}
+	tx.Where("user_id = ? and collection_id = ? and revision = ?", userId, cid, rev).First(snap)
+	if snap.Id == 0 {
+		return nil
+	}
+	res := &model.AddressBookExport{}
+	if err := json.Unmarshal([]byte(snap.Data), res); err != nil {
+		return nil
+	}
+	return res
+}
+
+// deleteRevisions 删除地址簿时删除版本和快照
+func (s *AddressBookService) deleteRevisions(tx *gorm.DB, userId, cid uint) {
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Delete(&model.AddressBookRevision{})
+	tx.Where("user_id = ? and collection_id = ?", userId, cid).Delete(&model.AddressBookSnapshot{})
+}
+
+// MergeAddressBook 三方合并, base 是客户端修改前的版本, ours 是服务端当前的数据, theirs 是客户端提交的数据
+// 别名两边都改了且不一致, 或者一边删除一边修改时返回冲突; 标签按集合合并; 其他字段客户端修改过的优先
+func (s *AddressBookService) MergeAddressBook(base, ours, theirs *model.AddressBookExport) (*model.AddressBookExport, []string) {
+	var conflicts []string
+	bm := peerMap(base.Peers)
+	om := peerMap(ours.Peers)
+	tm := peerMap(theirs.Peers)
+
+	var ids []string
+	for _, list := range [][]*model.AddressBook{ours.Peers, theirs.Peers} {
+		for _, p := range list {
+			if !utils.InArray(p.Id, ids) {
+				ids = append(ids, p.Id)
+			}
+		}
+	}
+
+	res := &model.AddressBookExport{Tags: []string{}, Peers: []*model.AddressBook{}}
+	for _, id := range ids {
+		b, o, t := bm[id], om[id], tm[id]
+		switch {
+		case o == nil && t == nil:
+			continue
+		case b == nil && o == nil:
+			res.Peers = append(res.Peers, t)
+		case b == nil && t == nil:
+			res.Peers = append(res.Peers, o)
+		case o == nil:
+			// 服务端已删除, 客户端未修改则保持删除
+			if !s.peerChanged(b, t) {
+				continue
+			}
+			conflicts = append(conflicts, id)
+		case t == nil:
+			// 客户端删除, 服务端未修改则删除
+			if !s.peerChanged(b, o) {
+				continue
+			}
+			conflicts = append(conflicts, id)
+		default:
+			if b == nil {
+				b = &model.AddressBook{Id: id}
+			}
+			p, ok := s.mergePeer(b, o, t)
+			if !ok {
+				conflicts = append(conflicts, id)
+				continue
+			}
+			res.Peers = append(res.Peers, p)
+		}
+	}
+
+	// 标签和颜色
+	res.Tags = mergeSet(base.Tags, ours.Tags, theirs.Tags)
+	for _, p := range res.Peers {
+		for _, tag := range s.TagsOf(p) {
+			if !utils.InArray(tag, res.Tags) {
+				res.Tags = append(res.Tags, tag)
+			}
+		}
+	}
+	bc, oc, tc := s.parseTagColors(base.TagColors), s.parseTagColors(ours.TagColors), s.parseTagColors(theirs.TagColors)
+	colors := map[string]uint{}
+	for _, tag := range res.Tags {
+		if c, ok := tc[tag]; ok && (c != bc[tag] || !hasKey(bc, tag)) {
+			colors[tag] = c
+		} else if c, ok := oc[tag]; ok {
+			colors[tag] = c
+		} else if c, ok := tc[tag]; ok {
+			colors[tag] = c
+		}
+	}
+	cj, _ := json.Marshal(colors)
+	res.TagColors = string(cj)
+	return res, conflicts
+}
+
+// mergePeer 合并一个设备, 别名冲突时返回false
+func (s *AddressBookService) mergePeer(b, o, t *model.AddressBook) (*model.AddressBook, bool) {
+	p := *o
+	p.Collection = nil
+	alias, ok := mergeString(b.Alias, o.Alias, t.Alias)
+	if !ok {
+		return nil, false
+	}
+	p.Alias = alias
+	tags, _ := json.Marshal(mergeSet(s.TagsOf(b), s.TagsOf(o), s.TagsOf(t)))
+	p.Tags = tags
+	p.Username = pick(b.Username, o.Username, t.Username)
+	p.Hostname = pick(b.Hostname, o.Hostname, t.Hostname)
+	p.Platform = pick(b.Platform, o.Platform, t.Platform)
+	p.RdpPort = pick(b.RdpPort, o.RdpPort, t.RdpPort)
+	p.RdpUsername = pick(b.RdpUsername, o.RdpUsername, t.RdpUsername)
+	p.LoginName = pick(b.LoginName, o.LoginName, t.LoginName)
+	if t.ForceAlwaysRelay != b.ForceAlwaysRelay {
+		p.ForceAlwaysRelay = t.ForceAlwaysRelay
+	}
+	if t.SameServer != b.SameServer {
+		p.SameServer = t.SameServer
+	}
+	// 快照中没有密码, 客户端提交了就用客户端的
+	if t.Password != "" {
+		p.Password = t.Password
+	}
+	if t.Hash != "" {
+		p.Hash = t.Hash
+	}
+	return &p, true
+}
+
+// peerChanged 比较除密码外的字段
+func (s *AddressBookService) peerChanged(b, p *model.AddressBook) bool {
+	return b.Alias != p.Alias || b.Username != p.Username || b.Hostname != p.Hostname ||
+		b.Platform != p.Platform || b.RdpPort != p.RdpPort || b.RdpUsername != p.RdpUsername ||
+		b.LoginName != p.LoginName || b.ForceAlwaysRelay != p.ForceAlwaysRelay ||
+		!sameSet(s.TagsOf(b), s.TagsOf(p))
+}
+
+func peerMap(peers []*model.AddressBook) map[string]*model.AddressBook {
+	m := make(map[string]*model.AddressBook, len(peers))
+	for _, p := range peers {
+		if p != nil && p.Id != "" {
+			m[p.Id] = p
+		}
+	}
+	return m
+}
+
+// mergeString 两边都改了且不一致时冲突
+func mergeString(b, o, t string) (string, bool) {
+	if o == t || t == b {
+		return o, true
+	}
+	if o == b {
+		return t, true
+	}
+	return "", false
+}
+
+// pick 客户端改过的优先
+func pick(b, o, t string) string {
+	if t != b {
+		return t
+	}
+	return o
+}
+
+// mergeSet 以ours为准, 加上theirs新增的, 去掉theirs删除的
+func mergeSet(b, o, t []string) []string {
+	res := []string{}
+	for _, v := range o {
+		if utils.InArray(v, b) && !utils.InArray(v, t) {
+			continue
+		}
+		res = append(res, v)
+	}
+	for _, v := range t {
+		if !utils.InArray(v, b) && !utils.InArray(v, res) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		if !utils.InArray(v, b) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasKey(m map[string]uint, k string) bool {
+	_, ok := m[k]
+	return ok
+}
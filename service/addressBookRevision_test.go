@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func testPeer(id, alias string, tags ...string) *model.AddressBook {
+	if tags == nil {
+		tags = []string{}
+	}
+	tj, _ := json.Marshal(tags)
+	return &model.AddressBook{Id: id, Alias: alias, Tags: tj}
+}
+
+func TestMergeAddressBook(t *testing.T) {
+	s := &AddressBookService{}
+	base := &model.AddressBookExport{
+		Tags:      []string{"a", "b"},
+		TagColors: `{"a":1,"b":2}`,
+		Peers:     []*model.AddressBook{testPeer("1", "one", "a"), testPeer("2", "two"), testPeer("3", "three")},
+	}
+	// 服务端: 修改1的别名, 删除3, 新增4
+	ours := &model.AddressBookExport{
+		Tags:      []string{"a", "b"},
+		TagColors: `{"a":1,"b":2}`,
+		Peers:     []*model.AddressBook{testPeer("1", "ONE", "a"), testPeer("2", "two"), testPeer("4", "four")},
+	}
+	// 客户端: 给1加标签b, 删除2, 新增5, 修改标签a的颜色
+	theirs := &model.AddressBookExport{
+		Tags:      []string{"a", "b"},
+		TagColors: `{"a":9,"b":2}`,
+		Peers:     []*model.AddressBook{testPeer("1", "one", "a", "b"), testPeer("3", "three"), testPeer("5", "five")},
+	}
+	res, conflicts := s.MergeAddressBook(base, ours, theirs)
+	if len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+	got := map[string]*model.AddressBook{}
+	for _, p := range res.Peers {
+		got[p.Id] = p
+	}
+	if len(got) != 3 || got["1"] == nil || got["4"] == nil || got["5"] == nil {
+		t.Fatalf("unexpected peers: %v", got)
+	}
+	if got["1"].Alias != "ONE" || !sameSet(s.TagsOf(got["1"]), []string{"a", "b"}) {
+		t.Fatalf("unexpected merge of peer 1: %s %s", got["1"].Alias, got["1"].Tags)
+	}
+	if s.parseTagColors(res.TagColors)["a"] != 9 {
+		t.Fatalf("unexpected tag colors: %s", res.TagColors)
+	}
+
+	// 两边都修改了别名
+	theirs.Peers[0] = testPeer("1", "uno", "a")
+	if _, conflicts = s.MergeAddressBook(base, ours, theirs); len(conflicts) != 1 || conflicts[0] != "1" {
+		t.Fatalf("expected alias conflict, got %v", conflicts)
+	}
+	// 服务端删除, 客户端修改
+	theirs.Peers[0] = testPeer("1", "one", "a")
+	theirs.Peers[1] = testPeer("3", "drei")
+	if _, conflicts = s.MergeAddressBook(base, ours, theirs); len(conflicts) != 1 || conflicts[0] != "3" {
+		t.Fatalf("expected delete conflict, got %v", conflicts)
+	}
+}
+
+func TestMergeSet(t *testing.T) {
+	res := mergeSet([]string{"a", "b"}, []string{"a", "b", "c"}, []string{"b", "d"})
+	if !sameSet(res, []string{"b", "c", "d"}) {
+		t.Fatalf("unexpected result: %v", res)
+	}
+}
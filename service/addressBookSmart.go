@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/model/custom_types"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ErrSmartCollectionReadOnly 智能地址簿的内容由筛选条件生成, 不能直接修改
+var ErrSmartCollectionReadOnly = errors.New("SmartCollectionReadOnly")
+
+// SmartPeerLimit 智能地址簿最多生成的设备数
+const SmartPeerLimit = 1000
+
+// IsSmartCollection 是否智能地址簿, 默认地址簿(cid为0)不是
+func (s *AddressBookService) IsSmartCollection(cid uint) bool {
+	if cid == 0 {
+		return false
+	}
+	return s.CollectionInfoById(cid).IsSmart()
+}
+
+// checkWritable 智能地址簿不能写入
+func (s *AddressBookService) checkWritable(tx *gorm.DB, cid uint) error {
+	if cid == 0 {
+		return nil
+	}
+	c := &model.AddressBookCollection{}
+	tx.Where("id = ?", cid).First(c)
+	if c.IsSmart() {
+		return ErrSmartCollectionReadOnly
+	}
+	return nil
+}
+
+// SmartPeers 按筛选条件生成智能地址簿的设备
+// 非管理员创建的智能地址簿只包含自己的设备
+func (s *AddressBookService) SmartPeers(c *model.AddressBookCollection) (res *model.AddressBookList) {
+	res = &model.AddressBookList{}
+	if !c.IsSmart() {
+		return
+	}
+	owner := AllService.UserService.InfoById(c.UserId)
+	if owner.Id == 0 {
+		return
+	}
+	f := c.Smart
+	var ids []string
+	if len(f.Tags) > 0 {
+		ids = s.peerIdsWithTags(owner.Id, f.Tags)
+		if len(ids) == 0 {
+			return
+		}
+	}
+	if f.OnlineWithin > 0 {
+		// 心跳先写入缓存, 按在线时间筛选前写入数据库
+		AllService.PeerService.FlushHeartbeats()
+	}
+	pl := AllService.PeerService.List(1, SmartPeerLimit, func(tx *gorm.DB) {
+		if !AllService.UserService.IsAdmin(owner) {
+			tx.Where("user_id = ?", owner.Id)
+		}
+		if len(f.DeviceGroupIds) > 0 {
+			tx.Where("group_id in (?)", f.DeviceGroupIds)
+		}
+		if f.Os != "" {
+			tx.Where("os like ? escape '!'", LikeContains(f.Os))
+		}
+		if len(f.UserIds) > 0 {
+			tx.Where("user_id in (?)", f.UserIds)
+		}
+		if f.Hostname != "" {
+			tx.Where("hostname like ? escape '!'", HostnameLikePattern(f.Hostname))
+		}
+		if f.OnlineWithin > 0 {
+			tx.Where("last_online_time > ?", time.Now().Unix()-f.OnlineWithin)
+		}
+		if len(ids) > 0 {
+			tx.Where("id in (?)", ids)
+		}
+		tx.Order("id")
+	})
+	res.Page = 1
+	res.PageSize = SmartPeerLimit
+	res.Total = pl.Total
+	for _, p := range pl.Peers {
+		ab := s.FromPeer(p)
+		ab.Alias = p.Hostname
+		ab.Tags = custom_types.AutoJson("[]")
+		ab.UserId = c.UserId
+		ab.CollectionId = c.Id
+		res.AddressBooks = append(res.AddressBooks, ab)
+	}
+	return
+}
+
+// peerIdsWithTags 所有者默认地址簿中带有任一标签的设备id
+func (s *AddressBookService) peerIdsWithTags(userId uint, tags []string) []string {
+	var abs []*model.AddressBook
+	DB.Where("user_id = ? and collection_id = 0", userId).Find(&abs)
+	var ids []string
+	for _, ab := range abs {
+		for _, t := range s.TagsOf(ab) {
+			if utils.InArray(t, tags) {
+				ids = append(ids, ab.Id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// likeEscape like语句中的转义, 需要加上 escape '!'; 不用反斜杠, mysql和sqlite对字符串中的反斜杠处理不同
+var likeEscape = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// LikeContains 按包含匹配的 like 参数, 用户输入的 % 和 _ 按原字符匹配
+func LikeContains(s string) string {
+	return "%" + likeEscape.Replace(s) + "%"
+}
+
+// HostnameLikePattern 把通配符 * ? 转为 like 参数, 没有通配符时按包含匹配; 需要加上 escape '!'
+func HostnameLikePattern(p string) string {
+	if !strings.ContainsAny(p, "*?") {
+		return LikeContains(p)
+	}
+	return strings.NewReplacer("*", "%", "?", "_").Replace(likeEscape.Replace(p))
+}
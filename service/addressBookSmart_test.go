@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func TestHostnameLikePattern(t *testing.T) {
+	cases := map[string]string{
+		"dc2":     "%dc2%",
+		"dc2-*":   "dc2-%",
+		"web?":    "web_",
+		"*-db-*":  "%-db-%",
+		"dc2-web": "%dc2-web%",
+		"50%":     "%50!%%",
+		"web_1*":  "web!_1%",
+		"a!b?":    "a!!b_",
+	}
+	for in, want := range cases {
+		if got := HostnameLikePattern(in); got != want {
+			t.Errorf("HostnameLikePattern(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestSmartPeersOnlineWithinUsesHeartbeats 缓存中还没写入数据库的心跳也要计算在内
+func TestSmartPeersOnlineWithinUsesHeartbeats(t *testing.T) {
+	newTestDB(t, &model.User{}, &model.Peer{})
+	old := PeerHeartbeats
+	PeerHeartbeats = NewMemoryPeerHeartbeatStore()
+	t.Cleanup(func() { PeerHeartbeats = old })
+	u := &model.User{Username: "u"}
+	DB.Create(u)
+	stale := time.Now().Add(-time.Hour).Unix()
+	DB.Create(&model.Peer{Id: "1", Uuid: "u1", UserId: u.Id, LastOnlineTime: stale})
+	DB.Create(&model.Peer{Id: "2", Uuid: "u2", UserId: u.Id, LastOnlineTime: stale})
+	if !AllService.PeerService.Heartbeat("u1", "10.0.0.1") {
+		t.Fatal("heartbeat not recorded")
+	}
+
+	s := &AddressBookService{}
+	c := &model.AddressBookCollection{UserId: u.Id, Smart: &model.AddressBookSmartFilter{OnlineWithin: 60}}
+	c.Id = 1
+	res := s.SmartPeers(c)
+	if len(res.AddressBooks) != 1 || res.AddressBooks[0].Id != "1" {
+		t.Fatalf("unexpected peers: %+v", res.AddressBooks)
+	}
+}
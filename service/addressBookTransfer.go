@@ -0,0 +1,374 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvHeader csv导出的列, id为空的行只用来保存没有设备使用的标签
+var csvHeader = []string{
+	"id", "alias", "username", "hostname", "platform", "tags", "tag_colors",
+	"hash", "password", "force_always_relay", "rdp_port", "rdp_username", "login_name",
+}
+
+// csvListSep 单元格内多个值的分隔符
+const csvListSep = ";"
+
+// Export 导出用户某个地址簿(cid为0时为默认地址簿)的设备和标签
+func (s *AddressBookService) Export(userId, cid uint) *model.AddressBookExport {
+	var abs []*model.AddressBook
+	DB.Where("user_id = ? and collection_id = ?", userId, cid).Order("row_id asc").Find(&abs)
+	tags := AllService.TagService.ListByUserIdAndCollectionId(userId, cid)
+
+	if abs == nil {
+		abs = []*model.AddressBook{}
+	}
+	res := &model.AddressBookExport{Tags: []string{}, Peers: abs}
+	tagColors := map[string]uint{}
+	for _, t := range tags.Tags {
+		res.Tags = append(res.Tags, t.Name)
+		tagColors[t.Name] = t.Color
+	}
+	tc, _ := json.Marshal(tagColors)
+	res.TagColors = string(tc)
+	// 和所属用户、地址簿无关的字段不导出
+	for _, ab := range abs {
+		ab.RowId = 0
+		ab.UserId = 0
+		ab.CollectionId = 0
+		ab.Online = false
+	}
+	return res
+}
+
+// Encode 按格式编码导出的数据
+func (s *AddressBookService) Encode(data *model.AddressBookExport, format string) ([]byte, error) {
+	if format == model.AddressBookExportCsv {
+		return s.ExportCsv(data)
+	}
+	return json.Marshal(data)
+}
+
+// Decode 按格式解析导入的数据
+func (s *AddressBookService) Decode(raw []byte, format string) (*model.AddressBookExport, error) {
+	if format == model.AddressBookExportCsv {
+		return s.ParseCsv(raw)
+	}
+	return s.ParseJson(raw)
+}
+
+// ExportCsv 转成csv
+func (s *AddressBookService) ExportCsv(data *model.AddressBookExport) ([]byte, error) {
+	tagColors := s.parseTagColors(data.TagColors)
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, ab := range data.Peers {
+		tags := s.TagsOf(ab)
+		for _, t := range tags {
+			used[t] = true
+		}
+		row := []string{
+			ab.Id, ab.Alias, ab.Username, ab.Hostname, ab.Platform,
+			strings.Join(tags, csvListSep), s.formatCsvTagColors(tags, tagColors),
+			ab.Hash, ab.Password, strconv.FormatBool(ab.ForceAlwaysRelay), ab.RdpPort, ab.RdpUsername, ab.LoginName,
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	var unused []string
+	for _, t := range data.Tags {
+		if !used[t] {
+			unused = append(unused, t)
+		}
+	}
+	if len(unused) > 0 {
+		row := make([]string, len(csvHeader))
+		row[5] = strings.Join(unused, csvListSep)
+		row[6] = s.formatCsvTagColors(unused, tagColors)
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// formatCsvTagColors 格式为 tag1:4288585374;tag2:4278238420
+func (s *AddressBookService) formatCsvTagColors(tags []string, colors map[string]uint) string {
+	var res []string
+	for _, t := range tags {
+		if c, ok := colors[t]; ok {
+			res = append(res, t+":"+strconv.FormatUint(uint64(c), 10))
+		}
+	}
+	return strings.Join(res, csvListSep)
+}
+
+// ParseJson 解析json, 也支持客户端 /api/ab 返回的 {"data":"..."} 格式
+func (s *AddressBookService) ParseJson(raw []byte) (*model.AddressBookExport, error) {
+	wrap := &struct {
+		Data *string `json:"data"`
+	}{}
+	if err := json.Unmarshal(raw, wrap); err != nil {
+		return nil, err
+	}
+	if wrap.Data != nil {
+		raw = []byte(*wrap.Data)
+	}
+	res := &model.AddressBookExport{}
+	if err := json.Unmarshal(raw, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ParseCsv 解析 ExportCsv 导出的csv, 按表头取列, 可以缺少列
+func (s *AddressBookService) ParseCsv(raw []byte) (*model.AddressBookExport, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("empty csv")
+	}
+	idx := map[string]int{}
+	for i, h := range rows[0] {
+		idx[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := idx["id"]; !ok {
+		return nil, errors.New("csv header must contain id")
+	}
+	col := func(row []string, name string) string {
+		i, ok := idx[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	res := &model.AddressBookExport{Tags: []string{}}
+	tagColors := map[string]uint{}
+	for _, row := range rows[1:] {
+		tags := splitCsvList(col(row, "tags"))
+		for _, t := range tags {
+			if !utils.InArray(t, res.Tags) {
+				res.Tags = append(res.Tags, t)
+			}
+		}
+		for _, tc := range splitCsvList(col(row, "tag_colors")) {
+			i := strings.LastIndex(tc, ":")
+			if i <= 0 {
+				return nil, errors.New("invalid tag color: " + tc)
+			}
+			c, err := strconv.ParseUint(tc[i+1:], 10, 32)
+			if err != nil {
+				return nil, errors.New("invalid tag color: " + tc)
+			}
+			tagColors[tc[:i]] = uint(c)
+		}
+		id := col(row, "id")
+		if id == "" {
+			continue
+		}
+		tj, _ := json.Marshal(tags)
+		relay, _ := strconv.ParseBool(col(row, "force_always_relay"))
+		res.Peers = append(res.Peers, &model.AddressBook{
+			Id:               id,
+			Alias:            col(row, "alias"),
+			Username:         col(row, "username"),
+			Hostname:         col(row, "hostname"),
+			Platform:         col(row, "platform"),
+			Tags:             tj,
+			Hash:             col(row, "hash"),
+			Password:         col(row, "password"),
+			ForceAlwaysRelay: relay,
+			RdpPort:          col(row, "rdp_port"),
+			RdpUsername:      col(row, "rdp_username"),
+			LoginName:        col(row, "login_name"),
+		})
+	}
+	tc, _ := json.Marshal(tagColors)
+	res.TagColors = string(tc)
+	return res, nil
+}
+
+func splitCsvList(s string) []string {
+	res := []string{}
+	for _, v := range strings.Split(s, csvListSep) {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// TagsOf 取设备的标签
+func (s *AddressBookService) TagsOf(ab *model.AddressBook) []string {
+	tags := []string{}
+	if len(ab.Tags) > 0 {
+		_ = json.Unmarshal(ab.Tags, &tags)
+	}
+	return tags
+}
+
+func (s *AddressBookService) parseTagColors(tc string) map[string]uint {
+	res := map[string]uint{}
+	if tc != "" {
+		_ = json.Unmarshal([]byte(tc), &res)
+	}
+	return res
+}
+
+// Import 导入到用户的某个地址簿, mode 为已存在的设备(按id)的处理方式
+func (s *AddressBookService) Import(op *model.User, userId, cid uint, data *model.AddressBookExport, mode string) (*model.AddressBookImportResult, error) {
+	res := &model.AddressBookImportResult{}
+	if mode == "" {
+		mode = model.AddressBookImportSkip
+	}
+	tagColors := s.parseTagColors(data.TagColors)
+	err := operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		if err := s.checkWritable(tx, cid); err != nil {
+			return err
+		}
+		var dbABs []*model.AddressBook
+		tx.Where("user_id = ? and collection_id = ?", userId, cid).Find(&dbABs)
+		exists := make(map[string]*model.AddressBook, len(dbABs))
+		for _, ab := range dbABs {
+			exists[ab.Id] = ab
+		}
+
+		allTags := append([]string{}, data.Tags...)
+		for _, p := range data.Peers {
+			if p == nil || p.Id == "" {
+				res.Skipped++
+				continue
+			}
+			tags := s.TagsOf(p)
+			for _, t := range tags {
+				if !utils.InArray(t, allTags) {
+					allTags = append(allTags, t)
+				}
+			}
+			ab := &model.AddressBook{
+				Id:               p.Id,
+				Username:         p.Username,
+				Password:         p.Password,
+				Hostname:         p.Hostname,
+				Alias:            p.Alias,
+				Platform:         p.Platform,
+				Hash:             p.Hash,
+				UserId:           userId,
+				ForceAlwaysRelay: p.ForceAlwaysRelay,
+				RdpPort:          p.RdpPort,
+				RdpUsername:      p.RdpUsername,
+				LoginName:        p.LoginName,
+				SameServer:       p.SameServer,
+				CollectionId:     cid,
+			}
+			ab.Tags, _ = json.Marshal(tags)
+			ex, ok := exists[p.Id]
+			if !ok {
+				if err := tx.Create(ab).Error; err != nil {
+					return err
+				}
+				if err := s.logPeerChange(tx, nil, ab); err != nil {
+					return err
+				}
+				exists[ab.Id] = ab
+				res.Created++
+				continue
+			}
+			switch mode {
+			case model.AddressBookImportOverwrite:
+				ab.RowId = ex.RowId
+				if err := tx.Model(ab).Select("*").Omit("created_at").Updates(ab).Error; err != nil {
+					return err
+				}
+				if err := s.logPeerChange(tx, ex, ab); err != nil {
+					return err
+				}
+				exists[ab.Id] = ab
+				res.Updated++
+			case model.AddressBookImportMerge:
+				merged := s.TagsOf(ex)
+				changed := false
+				for _, t := range tags {
+					if !utils.InArray(t, merged) {
+						merged = append(merged, t)
+						changed = true
+					}
+				}
+				if !changed {
+					res.Skipped++
+					continue
+				}
+				before := *ex
+				ex.Tags, _ = json.Marshal(merged)
+				if err := tx.Model(ex).Update("tags", ex.Tags).Error; err != nil {
+					return err
+				}
+				if err := s.logPeerChange(tx, &before, ex); err != nil {
+					return err
+				}
+				res.Updated++
+			default:
+				res.Skipped++
+			}
+		}
+
+		var dbTags []*model.Tag
+		tx.Where("user_id = ? and collection_id = ?", userId, cid).Find(&dbTags)
+		tagMap := make(map[string]*model.Tag, len(dbTags))
+		for _, t := range dbTags {
+			tagMap[t.Name] = t
+		}
+		sort.Strings(allTags)
+		for _, name := range allTags {
+			color, hasColor := tagColors[name]
+			t, ok := tagMap[name]
+			if !ok {
+				t = &model.Tag{Name: name, UserId: userId, CollectionId: cid, Color: color}
+				if err := tx.Create(t).Error; err != nil {
+					return err
+				}
+				if err := s.logTagChange(tx, nil, t); err != nil {
+					return err
+				}
+				tagMap[name] = t
+				res.TagsCreated++
+				continue
+			}
+			if mode == model.AddressBookImportOverwrite && hasColor && t.Color != color {
+				before := *t
+				if err := tx.Model(t).Update("color", color).Error; err != nil {
+					return err
+				}
+				t.Color = color
+				if err := s.logTagChange(tx, &before, t); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := s.BumpRevision(tx, userId, cid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func TestAddressBookCsvRoundTrip(t *testing.T) {
+	s := &AddressBookService{}
+	tags, _ := json.Marshal([]string{"ops", "db"})
+	data := &model.AddressBookExport{
+		Tags:      []string{"ops", "db", "unused"},
+		TagColors: `{"ops":4288585374,"db":4278238420,"unused":1}`,
+		Peers: []*model.AddressBook{
+			{Id: "123456", Alias: "db, primary", Tags: tags, RdpPort: "3389", ForceAlwaysRelay: true},
+		},
+	}
+	raw, err := s.ExportCsv(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.ParseCsv(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Peers) != 1 || len(res.Tags) != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	p := res.Peers[0]
+	if p.Id != "123456" || p.Alias != "db, primary" || p.RdpPort != "3389" || !p.ForceAlwaysRelay {
+		t.Fatalf("unexpected peer: %+v", p)
+	}
+	if got := s.TagsOf(p); len(got) != 2 || got[0] != "ops" || got[1] != "db" {
+		t.Fatalf("unexpected tags: %v", got)
+	}
+	colors := s.parseTagColors(res.TagColors)
+	if colors["ops"] != 4288585374 || colors["unused"] != 1 {
+		t.Fatalf("unexpected tag colors: %v", colors)
+	}
+}
+
+func TestAddressBookParseJson(t *testing.T) {
+	s := &AddressBookService{}
+	// /api/ab 返回的格式
+	raw := `{"data":"{\"tags\":[\"t1\"],\"peers\":[{\"id\":\"abc\",\"tags\":[\"t1\"]}],\"tag_colors\":\"{\\\"t1\\\":1}\"}"}`
+	res, err := s.ParseJson([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Peers) != 1 || res.Peers[0].Id != "abc" || len(res.Tags) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = s.ParseJson([]byte(`{"tags":[],"peers":[{"id":"x","created_at":"2024-01-02 03:04:05"}],"tag_colors":"{}"}`))
+	if err != nil || len(res.Peers) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", res, err)
+	}
+}
+
+func TestAddressBookExportImportRoundTrip(t *testing.T) {
+	db := newTestDB(t, addressBookTestModels...)
+	s := &AddressBookService{}
+	tags, _ := json.Marshal([]string{"ops"})
+	db.Create(&model.AddressBook{Id: "123456", UserId: 1, Alias: "db", Tags: tags, RdpPort: "3389"})
+	db.Create(&model.Tag{Name: "ops", Color: 4288585374, UserId: 1})
+
+	for _, format := range []string{model.AddressBookExportJson, model.AddressBookExportCsv} {
+		raw, err := s.Encode(s.Export(1, 0), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := s.Decode(raw, format)
+		if err != nil {
+			t.Fatalf("%s: decode exported data: %v", format, err)
+		}
+		res, err := s.Import(nil, 2, 0, data, model.AddressBookImportOverwrite)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if format == model.AddressBookExportJson && (res.Created != 1 || res.TagsCreated != 1) {
+			t.Fatalf("unexpected import result: %+v", res)
+		}
+		got := s.Export(2, 0)
+		if len(got.Peers) != 1 || got.Peers[0].Id != "123456" || got.Peers[0].Alias != "db" || got.Peers[0].RdpPort != "3389" {
+			t.Fatalf("%s: unexpected peers: %+v", format, got.Peers)
+		}
+		if len(got.Tags) != 1 || got.TagColors != `{"ops":4288585374}` {
+			t.Fatalf("%s: unexpected tags: %+v", format, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/lib/crypt"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+// addressBookTestModels 地址簿相关的测试需要的表
+var addressBookTestModels = []interface{}{
+	&model.AddressBook{}, &model.Tag{}, &model.AddressBookCollection{},
+	&model.AddressBookRevision{}, &model.AddressBookSnapshot{}, &model.AddressBookChange{},
+}
+
+func TestAddressBookUpdateByMapEncryptsPassword(t *testing.T) {
+	crypt.RegisterSerializer(crypt.NewKeyring("test-key", nil))
+	defer crypt.RegisterSerializer(crypt.NewKeyring("", nil))
+	db := newTestDB(t, addressBookTestModels...)
+	s := &AddressBookService{}
+	ab := &model.AddressBook{Id: "123", UserId: 1, Alias: "a"}
+	if err := db.Create(ab).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateByMap(nil, ab, map[string]interface{}{"password": "secret", "alias": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		Password string
+		Alias    string
+	}
+	db.Raw("select password, alias from address_books where row_id = ?", ab.RowId).Scan(&raw)
+	if !crypt.IsEncrypted(raw.Password) || raw.Alias != "b" {
+		t.Fatalf("unexpected row: %+v", raw)
+	}
+	got := &model.AddressBook{}
+	db.Where("row_id = ?", ab.RowId).First(got)
+	if got.Password != "secret" {
+		t.Fatalf("want decrypted password, got %q", got.Password)
+	}
+}
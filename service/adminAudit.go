@@ -0,0 +1,200 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type AdminAuditService struct {
+}
+
+// adminAuditTargets 路由中的对象类型对应的模型, 用于记录修改前后的值
+var adminAuditTargets = map[string]func() interface{}{
+	"user":                         func() interface{} { return &model.User{} },
+	"group":                        func() interface{} { return &model.Group{} },
+	"device_group":                 func() interface{} { return &model.DeviceGroup{} },
+	"tag":                          func() interface{} { return &model.Tag{} },
+	"address_book":                 func() interface{} { return &model.AddressBook{} },
+	"address_book_collection":      func() interface{} { return &model.AddressBookCollection{} },
+	"address_book_collection_rule": func() interface{} { return &model.AddressBookCollectionRule{} },
+	"address_book_invitation":      func() interface{} { return &model.AddressBookInvitation{} },
+	"peer":                         func() interface{} { return &model.Peer{} },
+	"oauth":                        func() interface{} { return &model.Oauth{} },
+	"user_token":                   func() interface{} { return &model.UserToken{} },
+	"access_token":                 func() interface{} { return &model.AccessToken{} },
+	"share_record":                 func() interface{} { return &model.ShareRecord{} },
+	"login_log":                    func() interface{} { return &model.LoginLog{} },
+	"audit_conn":                   func() interface{} { return &model.AuditConn{} },
+	"audit_file":                   func() interface{} { return &model.AuditFile{} },
+	"role":                         func() interface{} { return &model.Role{} },
+	"rustdesk":                     func() interface{} { return &model.ServerCmd{} },
+	"relay_server":                 func() interface{} { return &model.RelayServer{} },
+	"ip_rule":                      func() interface{} { return &model.IpRule{} },
+	"server_cmd_schedule":          func() interface{} { return &model.ServerCmdSchedule{} },
+}
+
+// adminAuditIgnoreFields 不记录修改的字段
+var adminAuditIgnoreFields = map[string]bool{"created_at": true, "updated_at": true}
+
+const adminAuditRedacted = "******"
+
+// Target 从路径中取对象类型, 如 /api/admin/oauth/update 为 oauth, /api/admin/my/tag/create 为 tag
+func (as *AdminAuditService) Target(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/admin/"), "/")
+	if len(parts) > 1 && parts[0] == "my" {
+		parts = parts[1:]
+	}
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// Snapshot 读取对象当前的值, 对象类型未知或不存在时返回nil
+func (as *AdminAuditService) Snapshot(target, id string) map[string]interface{} {
+	newFn, ok := adminAuditTargets[target]
+	if !ok {
+		return nil
+	}
+	// 字符串会被gorm当作查询条件, 必须是数字
+	pk, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || pk == 0 {
+		return nil
+	}
+	v := newFn()
+	if err := DB.First(v, pk).Error; err != nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	m := map[string]interface{}{}
+	if json.Unmarshal(b, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+// RedactBody 请求体脱敏, 不是json时不记录内容
+func (as *AdminAuditService) RedactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return ""
+	}
+	b, _ := json.Marshal(redactAudit(v))
+	return string(b)
+}
+
+// Diff 修改前后不同的字段, 创建时 before 为nil, 删除时 after 为nil
+func (as *AdminAuditService) Diff(before, after map[string]interface{}) string {
+	changes := diffAudit(before, after)
+	if len(changes) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(changes)
+	return string(b)
+}
+
+func (as *AdminAuditService) Create(l *model.AdminAuditLog) error {
+	return DB.Create(l).Error
+}
+
+func (as *AdminAuditService) InfoById(id uint) *model.AdminAuditLog {
+	l := &model.AdminAuditLog{}
+	DB.Where("id = ?", id).First(l)
+	return l
+}
+
+func (as *AdminAuditService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.AdminAuditLogList) {
+	res = &model.AdminAuditLogList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.AdminAuditLog{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.AdminAuditLogs)
+	return
+}
+
+// sensitiveAuditField 密码、密钥、token等字段, hash 是地址簿中设备的密码hash
+func sensitiveAuditField(k string) bool {
+	k = strings.ToLower(k)
+	return strings.Contains(k, "password") || strings.Contains(k, "secret") || strings.Contains(k, "pwd") ||
+		strings.HasSuffix(k, "token") || k == "key" || strings.HasSuffix(k, "_key") ||
+		k == "hash" || strings.HasSuffix(k, "_hash")
+}
+
+// redactAudit 递归替换敏感字段的值
+func redactAudit(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			if sensitiveAuditField(k) && val != nil && val != "" {
+				res[k] = adminAuditRedacted
+				continue
+			}
+			res[k] = redactAudit(val)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, val := range t {
+			res[i] = redactAudit(val)
+		}
+		return res
+	}
+	return v
+}
+
+func diffAudit(before, after map[string]interface{}) []*model.AdminAuditChange {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	fields := make([]string, 0, len(keys))
+	for k := range keys {
+		if !adminAuditIgnoreFields[k] {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	res := make([]*model.AdminAuditChange, 0)
+	for _, k := range fields {
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		if inBefore && inAfter && reflect.DeepEqual(a, b) {
+			continue
+		}
+		c := &model.AdminAuditChange{Field: k, Before: b, After: a}
+		if sensitiveAuditField(k) {
+			c.Redacted = true
+			if b != nil && b != "" {
+				c.Before = adminAuditRedacted
+			}
+			if a != nil && a != "" {
+				c.After = adminAuditRedacted
+			}
+		} else {
+			c.Before = redactAudit(b)
+			c.After = redactAudit(a)
+		}
+		res = append(res, c)
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdminAuditTarget(t *testing.T) {
+	as := &AdminAuditService{}
+	cases := map[string]string{
+		"/api/admin/oauth/update":           "oauth",
+		"/api/admin/my/address_book/update": "address_book",
+		"/api/admin/user/delete":            "user",
+		"/api/admin/rustdesk/sendCmd":       "rustdesk",
+		"/api/admin/my/share_record/delete": "share_record",
+		"/api/admin/logout":                 "",
+	}
+	for path, want := range cases {
+		if got := as.Target(path); got != want {
+			t.Errorf("Target(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRedactAudit(t *testing.T) {
+	in := map[string]interface{}{
+		"id":            float64(1),
+		"client_secret": "abc",
+		"password":      "",
+		"nested":        []interface{}{map[string]interface{}{"access_token": "t", "name": "n"}},
+		"peers":         []interface{}{map[string]interface{}{"id": "123", "hash": "h"}},
+	}
+	want := map[string]interface{}{
+		"id":            float64(1),
+		"client_secret": adminAuditRedacted,
+		"password":      "",
+		"nested":        []interface{}{map[string]interface{}{"access_token": adminAuditRedacted, "name": "n"}},
+		"peers":         []interface{}{map[string]interface{}{"id": "123", "hash": adminAuditRedacted}},
+	}
+	if got := redactAudit(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("redactAudit = %#v", got)
+	}
+}
+
+func TestDiffAudit(t *testing.T) {
+	before := map[string]interface{}{"id": float64(1), "client_id": "a", "client_secret": "s1", "scopes": "x", "updated_at": "t1"}
+	after := map[string]interface{}{"id": float64(1), "client_id": "a", "client_secret": "s2", "scopes": "y", "updated_at": "t2"}
+	got := diffAudit(before, after)
+	if len(got) != 2 {
+		t.Fatalf("got %d changes, want 2", len(got))
+	}
+	if c := got[0]; c.Field != "client_secret" || !c.Redacted || c.Before != adminAuditRedacted || c.After != adminAuditRedacted {
+		t.Errorf("secret change = %+v", *c)
+	}
+	if c := got[1]; c.Field != "scopes" || c.Before != "x" || c.After != "y" || c.Redacted {
+		t.Errorf("scopes change = %+v", *c)
+	}
+	// 删除
+	got = diffAudit(before, nil)
+	if len(got) != 4 || got[0].After != nil {
+		t.Errorf("delete changes = %d", len(got))
+	}
+	if len(diffAudit(before, before)) != 0 {
+		t.Error("no changes expected")
+	}
+}
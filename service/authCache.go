@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/lib/cache"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"strconv"
+	"time"
+)
+
+// 认证请求路径上的读缓存: token => UserToken, 用户id => User, 共享给个人/群组的地址簿规则
+// 缓存时间为 app.auth-cache-ttl, 为0时不缓存; 写入时主动失效
+// 多实例部署时需要共享的缓存(cache.type: redis), 否则其他实例要等缓存过期
+const (
+	authCacheTokenPrefix = "auth:token:"
+	authCacheUserPrefix  = "auth:user:"
+	// 共享规则和智能地址簿的缓存key带上版本号, 任何变更时版本号加1, 所有旧的缓存一起失效
+	abPermGenKey  = "ab:perm:gen"
+	abRulesPrefix = "ab:rules:"
+	abSmartPrefix = "ab:smart:"
+)
+
+// errSkipCache 查询结果不写入缓存, 如不存在的token, 避免被随意的token填满缓存
+var errSkipCache = errors.New("skip cache")
+
+func authCacheTtl() int {
+	if Cache == nil || Config == nil {
+		return 0
+	}
+	return int(Config.App.AuthCacheTtl / time.Second)
+}
+
+// readThrough 读缓存, 不存在时调用 fn, fn 返回false时不写入缓存; 未启用或者缓存出错时直接调用 fn
+// T 为0值时需要表示不存在, 并发等待中的调用在不写入缓存时拿到的是0值
+func readThrough[T any](prefix, key string, fn func() (T, bool)) T {
+	ttl := authCacheTtl()
+	if ttl <= 0 {
+		v, _ := fn()
+		return v
+	}
+	var res T
+	v, err := cache.NewTyped[T](Cache, prefix, ttl).GetOrSet(key, func() (T, error) {
+		v, ok := fn()
+		res = v
+		if !ok {
+			return v, errSkipCache
+		}
+		return v, nil
+	})
+	if err == nil {
+		return v
+	}
+	if errors.Is(err, errSkipCache) {
+		return res
+	}
+	Logger.Warn("auth cache: ", err)
+	v, _ = fn()
+	return v
+}
+
+func forgetAuthCache(keys ...string) {
+	if Cache == nil {
+		return
+	}
+	for _, k := range keys {
+		if err := Cache.Delete(k); err != nil {
+			Logger.Warn("auth cache: ", err)
+		}
+	}
+}
+
+// cachedToken token记录, 不含仅用于oidc登出的字段
+func (us *UserService) cachedToken(token string) *model.UserToken {
+	ut := readThrough(authCacheTokenPrefix, token, func() (model.UserToken, bool) {
+		ut := model.UserToken{}
+		DB.Where("token = ?", token).First(&ut)
+		ut.Sid, ut.IdToken = "", ""
+		return ut, ut.Id > 0
+	})
+	return &ut
+}
+
+// cachedUser 用户信息, 不含密码
+func (us *UserService) cachedUser(id uint) *model.User {
+	u := readThrough(authCacheUserPrefix, strconv.FormatUint(uint64(id), 10), func() (model.User, bool) {
+		u := model.User{}
+		DB.Where("id = ?", id).First(&u)
+		// 不论是否启用缓存都去掉密码, 保证行为一致
+		u.Password = ""
+		return u, u.Id > 0
+	})
+	return &u
+}
+
+// forgetTokens 删除token的缓存, 在删除token之前调用, where 为删除时的条件
+func (us *UserService) forgetTokens(query interface{}, args ...interface{}) {
+	if authCacheTtl() <= 0 {
+		return
+	}
+	var tokens []string
+	DB.Model(&model.UserToken{}).Where(query, args...).Pluck("token", &tokens)
+	keys := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		keys = append(keys, authCacheTokenPrefix+t)
+	}
+	forgetAuthCache(keys...)
+}
+
+func (us *UserService) forgetUser(id uint) {
+	forgetAuthCache(authCacheUserPrefix + strconv.FormatUint(uint64(id), 10))
+}
+
+// abPermGen 当前的版本号, 不存在时为0
+func abPermGen() string {
+	var gen int64
+	if err := Cache.Get(abPermGenKey, &gen); err != nil {
+		gen = 0
+	}
+	return strconv.FormatInt(gen, 10) + ":"
+}
+
+// forgetPermissions 共享规则或者地址簿变更后调用
+func (s *AddressBookService) forgetPermissions() {
+	if Cache == nil {
+		return
+	}
+	if _, err := Cache.Incr(abPermGenKey, 1, 0); err != nil {
+		Logger.Warn("auth cache: ", err)
+	}
+}
+
+// rulesTo 共享给个人或群组(typ)的 toId 的所有规则, 生效时间在使用时判断
+func (s *AddressBookService) rulesTo(typ int, toId uint) []model.AddressBookCollectionRule {
+	query := func() ([]model.AddressBookCollectionRule, bool) {
+		var rules []model.AddressBookCollectionRule
+		DB.Where("type = ? and to_id = ?", typ, toId).Find(&rules)
+		return rules, true
+	}
+	if authCacheTtl() <= 0 {
+		rules, _ := query()
+		return rules
+	}
+	return readThrough(abRulesPrefix+abPermGen(), strconv.Itoa(typ)+":"+strconv.FormatUint(uint64(toId), 10), query)
+}
+
+// isSmartCached 同 IsSmartCollection, 用于权限判断
+func (s *AddressBookService) isSmartCached(cid uint) bool {
+	if cid == 0 {
+		return false
+	}
+	query := func() (bool, bool) {
+		return s.CollectionInfoById(cid).IsSmart(), true
+	}
+	if authCacheTtl() <= 0 {
+		smart, _ := query()
+		return smart
+	}
+	return readThrough(abSmartPrefix+abPermGen(), strconv.FormatUint(uint64(cid), 10), query)
+}
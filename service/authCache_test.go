@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/cache"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"testing"
+	"time"
+)
+
+func TestRuleOfCollection(t *testing.T) {
+	rules := []model.AddressBookCollectionRule{
+		{IdModel: model.IdModel{Id: 5}, CollectionId: 1, Rule: 3},
+		{IdModel: model.IdModel{Id: 2}, CollectionId: 1, Rule: 1},
+		{IdModel: model.IdModel{Id: 3}, CollectionId: 2, Rule: 2},
+	}
+	if r := ruleOfCollection(rules, 1); r.Id != 2 || r.Rule != 1 {
+		t.Fatalf("want the rule with the smallest id, got %+v", r)
+	}
+	if r := ruleOfCollection(rules, 9); r.Id != 0 {
+		t.Fatalf("want empty rule, got %+v", r)
+	}
+}
+
+func TestReadThrough(t *testing.T) {
+	oldConfig, oldCache := Config, Cache
+	defer func() { Config, Cache = oldConfig, oldCache }()
+	Config = &config.Config{}
+	Config.App.AuthCacheTtl = time.Minute
+	Cache = cache.NewMemoryCache(0)
+
+	calls := 0
+	found := func() (string, bool) {
+		calls++
+		return "v", true
+	}
+	for i := 0; i < 2; i++ {
+		if v := readThrough("t:", "found", found); v != "v" {
+			t.Fatalf("got %q", v)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("want 1 call, got %d", calls)
+	}
+
+	calls = 0
+	missing := func() (string, bool) {
+		calls++
+		return "", false
+	}
+	for i := 0; i < 2; i++ {
+		readThrough("t:", "missing", missing)
+	}
+	if calls != 2 {
+		t.Fatalf("misses should not be cached, got %d calls", calls)
+	}
+
+	Config.App.AuthCacheTtl = 0
+	calls = 0
+	readThrough("t:", "found", found)
+	if calls != 1 {
+		t.Fatalf("disabled cache should call fn")
+	}
+}
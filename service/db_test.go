@@ -0,0 +1,44 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/crypt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// newTestDB 使用临时目录中的sqlite替换 DB, 只迁移 models; 测试结束后恢复
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	oldDB, oldLogger, oldConfig, oldAll := DB, Logger, Config, AllService
+	// 加密字段需要注册serializer, 未注册时不加密
+	if _, ok := schema.GetSerializer(crypt.SerializerName); !ok {
+		crypt.RegisterSerializer(crypt.NewKeyring("", nil))
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+	Logger = log.New()
+	Config = &config.Config{}
+	AllService = new(Service)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB, Logger, Config, AllService = oldDB, oldLogger, oldConfig, oldAll
+	})
+	return db
+}
@@ -35,12 +35,28 @@ func (us *GroupService) Create(u *model.Group) error {
 	return res
 }
 func (us *GroupService) Delete(u *model.Group) error {
-	return DB.Delete(u).Error
+	if err := AllService.RoleService.DeleteBindingsByGroupId(u.Id); err != nil {
+		return err
+	}
+	if err := DB.Delete(u).Error; err != nil {
+		return err
+	}
+	if u.MyDevices != nil && *u.MyDevices {
+		AllService.AddressBookService.SyncMyDevicesOfGroup(u.Id)
+	}
+	return nil
 }
 
-// Update 更新
+// Update 更新, "我的设备"开关变化时同步组内用户
 func (us *GroupService) Update(u *model.Group) error {
-	return DB.Model(u).Updates(u).Error
+	old := us.InfoById(u.Id)
+	if err := DB.Model(u).Updates(u).Error; err != nil {
+		return err
+	}
+	if u.MyDevices != nil && (old.MyDevices == nil || *old.MyDevices != *u.MyDevices) {
+		AllService.AddressBookService.SyncMyDevicesOfGroup(u.Id)
+	}
+	return nil
 }
 
 // DeviceGroupInfoById 根据用户id取用户信息
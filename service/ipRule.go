@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type IpRuleService struct {
+}
+
+// ipRuleEntry api中拦截的网段
+type ipRuleEntry struct {
+	prefix    netip.Prefix
+	expiresAt int64
+}
+
+type ipRuleSet []ipRuleEntry
+
+// ipRules 内存中的规则, 每次修改后重新加载, 避免每个请求都查询数据库
+var ipRules atomic.Pointer[ipRuleSet]
+
+func newIpRuleSet(rules []*model.IpRule) ipRuleSet {
+	set := make(ipRuleSet, 0, len(rules))
+	for _, r := range rules {
+		if !r.InApi() {
+			continue
+		}
+		p, err := model.ParseIpRule(r.Cidr)
+		if err != nil {
+			continue
+		}
+		set = append(set, ipRuleEntry{prefix: p, expiresAt: r.ExpiresAt})
+	}
+	return set
+}
+
+// match ip是否在未过期的规则中
+func (s ipRuleSet) match(ip string, t time.Time) bool {
+	a, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap()
+	for _, e := range s {
+		if e.expiresAt > 0 && t.Unix() >= e.expiresAt {
+			continue
+		}
+		if e.prefix.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load 重新加载api中拦截的规则
+func (is *IpRuleService) Load() {
+	var rules []*model.IpRule
+	DB.Where("scope in ?", []string{model.IpRuleScopeAll, model.IpRuleScopeApi}).Find(&rules)
+	set := newIpRuleSet(rules)
+	ipRules.Store(&set)
+}
+
+// Blocked ip是否被封禁
+func (is *IpRuleService) Blocked(ip string) bool {
+	set := ipRules.Load()
+	if set == nil {
+		return false
+	}
+	return set.match(ip, time.Now())
+}
+
+func (is *IpRuleService) InfoById(id uint) *model.IpRule {
+	r := &model.IpRule{}
+	DB.Where("id = ?", id).First(r)
+	return r
+}
+
+func (is *IpRuleService) InfoByCidr(cidr string) *model.IpRule {
+	r := &model.IpRule{}
+	DB.Where("cidr = ?", cidr).First(r)
+	return r
+}
+
+func (is *IpRuleService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.IpRuleList) {
+	res = &model.IpRuleList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.IpRule{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.IpRules)
+	return
+}
+
+// Create 创建并同步到hbbr
+func (is *IpRuleService) Create(ctx context.Context, r *model.IpRule) (*model.IpRuleSyncResult, error) {
+	if err := DB.Create(r).Error; err != nil {
+		return nil, err
+	}
+	is.Load()
+	res := &model.IpRuleSyncResult{}
+	if ip := r.RelayIp(); ip != "" {
+		res.Relays = AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-add", ip)
+	}
+	return res, nil
+}
+
+// Update 更新并同步到hbbr, ip或范围变化时先从hbbr中移除旧的ip
+func (is *IpRuleService) Update(ctx context.Context, old, r *model.IpRule) (*model.IpRuleSyncResult, error) {
+	err := DB.Model(r).Select("cidr", "scope", "reason", "expires_at").Updates(r).Error
+	if err != nil {
+		return nil, err
+	}
+	is.Load()
+	res := &model.IpRuleSyncResult{}
+	oldIp, ip := old.RelayIp(), r.RelayIp()
+	if oldIp != "" && oldIp != ip {
+		res.Relays = AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-remove", oldIp)
+	}
+	if ip != "" {
+		res.Relays = append(res.Relays, AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-add", ip)...)
+	}
+	return res, nil
+}
+
+// Delete 删除并解封: 从hbbr的 blocklist 中移除, 解除hbbs ip-blocker 的自动封禁
+func (is *IpRuleService) Delete(ctx context.Context, r *model.IpRule) (*model.IpRuleSyncResult, error) {
+	if err := DB.Delete(r).Error; err != nil {
+		return nil, err
+	}
+	is.Load()
+	res := &model.IpRuleSyncResult{}
+	if ip := r.RelayIp(); ip != "" {
+		res.Relays = AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-remove", ip)
+	}
+	if p, err := model.ParseIpRule(r.Cidr); err == nil && p.IsSingleIP() {
+		res.IdServers = AllService.ServerCmdService.SendCmdToIdServers(ctx, "ip-blocker", p.Addr().String()+" -")
+	}
+	return res, nil
+}
+
+// Sync 清理过期的规则, 并把所有规则同步到hbbr(hbbr重启后通过指令添加的 blocklist 会丢失)
+// 每次都重新加载api中拦截的规则, 多实例部署时其他实例修改的规则在这里生效
+// hbbr的 blocklist-add/blocklist-remove 支持用 | 分隔多个ip
+func (is *IpRuleService) Sync(ctx context.Context) []*model.RelayCmdResult {
+	var rules []*model.IpRule
+	DB.Find(&rules)
+	now := time.Now()
+	var add, remove []string
+	var expired []uint
+	for _, r := range rules {
+		ip := r.RelayIp()
+		if r.Expired(now) {
+			expired = append(expired, r.Id)
+			if ip != "" {
+				remove = append(remove, ip)
+			}
+			continue
+		}
+		if ip != "" {
+			add = append(add, ip)
+		}
+	}
+	var res []*model.RelayCmdResult
+	if len(expired) > 0 {
+		if err := DB.Where("id in ?", expired).Delete(&model.IpRule{}).Error; err != nil {
+			Logger.Error("delete expired ip rules failed: ", err)
+		}
+	}
+	is.Load()
+	if len(remove) > 0 {
+		res = append(res, AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-remove", strings.Join(remove, "|"))...)
+	}
+	if len(add) > 0 {
+		res = append(res, AllService.RelayServerService.SendCmdToAll(ctx, "blocklist-add", strings.Join(add, "|"))...)
+	}
+	return res
+}
+
+// SyncEvery 启动时加载规则, 之后定时同步
+func (is *IpRuleService) SyncEvery(d time.Duration) {
+	is.Load()
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		for _, r := range is.Sync(context.Background()) {
+			if r.Error != "" {
+				Logger.Warn("sync ip rules to relay ", r.Name, " failed: ", r.Error)
+			}
+		}
+	}
+}
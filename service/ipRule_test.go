@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"testing"
+	"time"
+)
+
+func TestIpRuleSetMatch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	set := newIpRuleSet([]*model.IpRule{
+		{Cidr: "1.2.3.4", Scope: model.IpRuleScopeAll},
+		{Cidr: "10.0.0.0/8", Scope: model.IpRuleScopeApi},
+		{Cidr: "2001:db8::/32", Scope: model.IpRuleScopeAll},
+		{Cidr: "5.6.7.8", Scope: model.IpRuleScopeRelay},
+		{Cidr: "9.9.9.9", Scope: model.IpRuleScopeAll, ExpiresAt: 1000},
+	})
+	cases := map[string]bool{
+		"1.2.3.4":        true,
+		"::ffff:1.2.3.4": true,
+		"10.20.30.40":    true,
+		"2001:db8::5":    true,
+		"5.6.7.8":        false, // 只同步到hbbr
+		"9.9.9.9":        false, // 已过期
+		"1.2.3.5":        false,
+		"not-an-ip":      false,
+	}
+	for ip, want := range cases {
+		if got := set.match(ip, now); got != want {
+			t.Errorf("match(%q) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestIpRuleSyncLoadsNewRules(t *testing.T) {
+	db := newTestDB(t, &model.IpRule{})
+	defer ipRules.Store(nil)
+	is := &IpRuleService{}
+	is.Load()
+	// 其他实例创建的规则, 本实例没有调用 Load
+	db.Create(&model.IpRule{Cidr: "10.0.0.0/8", Scope: model.IpRuleScopeApi})
+	if is.Blocked("10.1.2.3") {
+		t.Fatal("rule should not be loaded yet")
+	}
+	is.Sync(context.Background())
+	if !is.Blocked("10.1.2.3") {
+		t.Fatal("sync should load rules created by other instances")
+	}
+}
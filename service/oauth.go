@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -10,9 +11,9 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	// "golang.org/x/oauth2/google"
-	"gorm.io/gorm"
-	// "io"
 	"fmt"
+	"gorm.io/gorm"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -47,6 +48,8 @@ type OauthCacheItem struct {
 	Email      string `json:"email"`
 	Verifier   string `json:"verifier"` // used for oauth pkce
 	Nonce      string `json:"nonce"`
+	IdToken    string `json:"-"` // used for oidc logout
+	Sid        string `json:"-"`
 }
 
 func (oci *OauthCacheItem) ToOauthUser() *model.OauthUser {
@@ -70,6 +73,8 @@ func (oci *OauthCacheItem) UpdateFromOauthUser(oauthUser *model.OauthUser) {
 	oci.Username = oauthUser.Username
 	oci.Name = oauthUser.Name
 	oci.Email = oauthUser.Email
+	oci.IdToken = oauthUser.IdToken
+	oci.Sid = oauthUser.Sid
 }
 
 func (os *OauthService) GetOauthCache(key string) *OauthCacheItem {
@@ -154,6 +159,15 @@ func (os *OauthService) GithubProvider() *oidc.Provider {
 	}).NewProvider(context.Background())
 }
 
+// GenericProvider oauth2类型的provider, 只用到 endpoint 和 userinfo 地址
+func (os *OauthService) GenericProvider(oauthInfo *model.Oauth) *oidc.Provider {
+	return (&oidc.ProviderConfig{
+		AuthURL:     oauthInfo.AuthUrl,
+		TokenURL:    oauthInfo.TokenUrl,
+		UserInfoURL: oauthInfo.UserInfoUrl,
+	}).NewProvider(context.Background())
+}
+
 // GetOauthConfig retrieves the OAuth2 configuration based on the provider name
 func (os *OauthService) GetOauthConfig(op string) (err error, oauthInfo *model.Oauth, oauthConfig *oauth2.Config, provider *oidc.Provider) {
 	//err, oauthInfo, oauthConfig = os.getOauthConfigGeneral(op)
@@ -182,6 +196,10 @@ func (os *OauthService) GetOauthConfig(op string) (err error, oauthInfo *model.O
 		oauthConfig.Endpoint = github.Endpoint
 		oauthConfig.Scopes = []string{"read:user", "user:email"}
 		provider = os.GithubProvider()
+	case model.OauthTypeOauth2:
+		provider = os.GenericProvider(oauthInfo)
+		oauthConfig.Endpoint = provider.Endpoint()
+		oauthConfig.Scopes = splitScopes(oauthInfo.Scopes)
 	//case model.OauthTypeGoogle: //google单独出来，可以少一次FetchOidcEndpoint请求
 	//	oauthConfig.Endpoint = google.Endpoint
 	//	oauthConfig.Scopes = os.constructScopes(oauthInfo.Scopes)
@@ -218,7 +236,9 @@ func getHTTPClientWithProxy() *http.Client {
 	}
 	return http.DefaultClient
 }
-func (os *OauthService) callbackBase(oauthConfig *oauth2.Config, provider *oidc.Provider, code string, verifier string, nonce string, userData interface{}) (err error, client *http.Client) {
+
+// callbackBase verifyIdToken 为false时忽略 id_token, 用于没有jwks和issuer的provider(github, oauth2)
+func (os *OauthService) callbackBase(oauthConfig *oauth2.Config, provider *oidc.Provider, code string, verifier string, nonce string, verifyIdToken bool, userData interface{}) (err error, client *http.Client, claims map[string]interface{}, rawIDToken string) {
 
 	// 设置代理客户端
 	httpClient := getHTTPClientWithProxy()
@@ -233,32 +253,38 @@ func (os *OauthService) callbackBase(oauthConfig *oauth2.Config, provider *oidc.
 
 	if err != nil {
 		Logger.Warn("oauthConfig.Exchange() failed: ", err)
-		return errors.New("GetOauthTokenError"), nil
+		return errors.New("GetOauthTokenError"), nil, nil, ""
 	}
 
+	claims = make(map[string]interface{})
 	// 获取 ID Token， github没有id_token
 	rawIDToken, ok := token.Extra("id_token").(string)
-	if ok && rawIDToken != "" {
+	if !verifyIdToken {
+		rawIDToken = ""
+	} else if ok && rawIDToken != "" {
 		// 验证 ID Token
 		v := provider.Verifier(&oidc.Config{ClientID: oauthConfig.ClientID})
 		idToken, err2 := v.Verify(ctx, rawIDToken)
 		if err2 != nil {
 			Logger.Warn("IdTokenVerifyError: ", err2)
-			return errors.New("IdTokenVerifyError"), nil
+			return errors.New("IdTokenVerifyError"), nil, nil, ""
+		}
+		if err2 = idToken.Claims(&claims); err2 != nil {
+			Logger.Warn("Failed to parse ID Token claims: ", err2)
 		}
 		if nonce != "" {
 			// 验证 nonce
-			var claims struct {
+			var nonceClaims struct {
 				Nonce string `json:"nonce"`
 			}
-			if err2 = idToken.Claims(&claims); err2 != nil {
+			if err2 = idToken.Claims(&nonceClaims); err2 != nil {
 				Logger.Warn("Failed to parse ID Token claims: ", err)
-				return errors.New("IDTokenClaimsError"), nil
+				return errors.New("IDTokenClaimsError"), nil, nil, ""
 			}
 
-			if claims.Nonce != nonce {
+			if nonceClaims.Nonce != nonce {
 				Logger.Warn("Nonce does not match")
-				return errors.New("NonceDoesNotMatch"), nil
+				return errors.New("NonceDoesNotMatch"), nil, nil, ""
 			}
 		}
 	}
@@ -268,7 +294,7 @@ func (os *OauthService) callbackBase(oauthConfig *oauth2.Config, provider *oidc.
 	resp, err := client.Get(provider.UserInfoEndpoint())
 	if err != nil {
 		Logger.Warn("failed getting user info: ", err)
-		return errors.New("GetOauthUserInfoError"), nil
+		return errors.New("GetOauthUserInfoError"), nil, nil, ""
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -276,19 +302,28 @@ func (os *OauthService) callbackBase(oauthConfig *oauth2.Config, provider *oidc.
 		}
 	}()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		Logger.Warn("failed reading user info: ", err)
+		return errors.New("GetOauthUserInfoError"), nil, nil, ""
+	}
 	// 解析用户信息
-	if err = json.NewDecoder(resp.Body).Decode(userData); err != nil {
+	if err = json.Unmarshal(body, userData); err != nil {
 		Logger.Warn("failed decoding user info: ", err)
-		return errors.New("DecodeOauthUserInfoError"), nil
+		return errors.New("DecodeOauthUserInfoError"), nil, nil, ""
+	}
+	// userinfo 中的 claim 覆盖 id_token 中的同名 claim
+	if err = json.Unmarshal(body, &claims); err != nil {
+		Logger.Warn("failed decoding user info claims: ", err)
 	}
 
-	return nil, client
+	return nil, client, claims, rawIDToken
 }
 
 // githubCallback github回调
 func (os *OauthService) githubCallback(oauthConfig *oauth2.Config, provider *oidc.Provider, code, verifier, nonce string) (error, *model.OauthUser) {
 	var user = &model.GithubUser{}
-	err, client := os.callbackBase(oauthConfig, provider, code, verifier, nonce, user)
+	err, client, claims, _ := os.callbackBase(oauthConfig, provider, code, verifier, nonce, false, user)
 	if err != nil {
 		return err, nil
 	}
@@ -296,16 +331,41 @@ func (os *OauthService) githubCallback(oauthConfig *oauth2.Config, provider *oid
 	if err != nil {
 		return err, nil
 	}
-	return nil, user.ToOauthUser()
+	oauthUser := user.ToOauthUser()
+	oauthUser.Claims = claims
+	return nil, oauthUser
 }
 
 // oidcCallback oidc回调, 通过code获取用户信息
 func (os *OauthService) oidcCallback(oauthConfig *oauth2.Config, provider *oidc.Provider, code, verifier, nonce string) (error, *model.OauthUser) {
 	var user = &model.OidcUser{}
-	if err, _ := os.callbackBase(oauthConfig, provider, code, verifier, nonce, user); err != nil {
+	err, _, claims, rawIDToken := os.callbackBase(oauthConfig, provider, code, verifier, nonce, true, user)
+	if err != nil {
+		return err, nil
+	}
+	oauthUser := user.ToOauthUser()
+	oauthUser.Claims = claims
+	oauthUser.IdToken = rawIDToken
+	if sid, ok := claims["sid"].(string); ok {
+		oauthUser.Sid = sid
+	}
+	return nil, oauthUser
+}
+
+// genericCallback oauth2回调, 按配置的path从userinfo中取用户信息
+func (os *OauthService) genericCallback(oauthInfo *model.Oauth, oauthConfig *oauth2.Config, provider *oidc.Provider, code, verifier, nonce string) (error, *model.OauthUser) {
+	var user = &model.GenericOauthUser{}
+	err, _, claims, _ := os.callbackBase(oauthConfig, provider, code, verifier, nonce, false, user)
+	if err != nil {
 		return err, nil
 	}
-	return nil, user.ToOauthUser()
+	oauthUser := user.ToOauthUser(oauthInfo)
+	if oauthUser.OpenId == "" {
+		Logger.Warn("oauth2 userinfo has no id at path: ", oauthInfo.IdPath)
+		return errors.New("DecodeOauthUserInfoError"), nil
+	}
+	oauthUser.Claims = claims
+	return nil, oauthUser
 }
 
 // Callback: Get user information by code and op(Oauth provider)
@@ -321,6 +381,8 @@ func (os *OauthService) Callback(code, verifier, op, nonce string) (err error, o
 		err, oauthUser = os.githubCallback(oauthConfig, provider, code, verifier, nonce)
 	case model.OauthTypeOidc, model.OauthTypeGoogle:
 		err, oauthUser = os.oidcCallback(oauthConfig, provider, code, verifier, nonce)
+	case model.OauthTypeOauth2:
+		err, oauthUser = os.genericCallback(oauthInfo, oauthConfig, provider, code, verifier, nonce)
 	default:
 		return errors.New("unsupported OAuth type"), nil
 	}
@@ -385,7 +447,18 @@ func (os *OauthService) constructScopes(scopes string) []string {
 	if scopes == "" {
 		scopes = model.OIDC_DEFAULT_SCOPES
 	}
-	return strings.Split(scopes, ",")
+	return splitScopes(scopes)
+}
+
+// splitScopes 逗号分隔的scopes, 去掉空格和空值
+func splitScopes(scopes string) []string {
+	var res []string
+	for _, s := range strings.Split(scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
 }
 
 func (os *OauthService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.OauthList) {
@@ -458,6 +531,182 @@ func (os *OauthService) GetOauthProviders() []string {
 	return res
 }
 
+// ClaimValues returns the values of a claim, path can be nested with ".", eg: realm_access.roles
+// The claim can be a string (separated by "," or space) or an array of strings
+func (os *OauthService) ClaimValues(claims map[string]interface{}, path string) []string {
+	cur, ok := utils.JsonPathGet(claims, path)
+	if !ok {
+		return nil
+	}
+	var res []string
+	switch v := cur.(type) {
+	case string:
+		res = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+// MapClaims 根据配置将claim映射为是否管理员和本地分组, groupId为0表示没有匹配的分组
+// found 为false表示IdP没有返回这个claim, 此时不应修改用户
+func (os *OauthService) MapClaims(oauthInfo *model.Oauth, claims map[string]interface{}) (isAdmin bool, groupId uint, found bool) {
+	if _, found = utils.JsonPathGet(claims, oauthInfo.RoleClaim); !found {
+		return false, 0, false
+	}
+	values := os.ClaimValues(claims, oauthInfo.RoleClaim)
+	if len(values) == 0 {
+		return false, 0, true
+	}
+	adminRoles := oauthInfo.AdminRoleList()
+	for _, v := range values {
+		if utils.InArray(v, adminRoles) {
+			isAdmin = true
+			break
+		}
+	}
+	groupMaps, err := oauthInfo.GroupMapList()
+	if err != nil {
+		Logger.Warn("invalid oauth group mapping: ", err)
+		return isAdmin, 0, true
+	}
+	for _, gm := range groupMaps {
+		if utils.InArray(gm.Value, values) {
+			return isAdmin, gm.GroupId, true
+		}
+	}
+	return isAdmin, 0, true
+}
+
+// EndSessionUrl 构造 RP-initiated logout 地址, IdP不支持时返回空
+func (os *OauthService) EndSessionUrl(op, idToken string) string {
+	err, oauthInfo, _, provider := os.GetOauthConfig(op)
+	if err != nil || provider == nil {
+		return ""
+	}
+	var meta struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err = provider.Claims(&meta); err != nil || meta.EndSessionEndpoint == "" {
+		return ""
+	}
+	u, err := url.Parse(meta.EndSessionEndpoint)
+	if err != nil {
+		Logger.Warn("invalid end_session_endpoint: ", err)
+		return ""
+	}
+	q := u.Query()
+	q.Set("id_token_hint", idToken)
+	q.Set("client_id", oauthInfo.ClientId)
+	if oauthInfo.PostLogoutRedirectUrl != "" {
+		q.Set("post_logout_redirect_uri", oauthInfo.PostLogoutRedirectUrl)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// VerifyLogoutToken 验证 back-channel logout token, 返回其中的sub和sid
+func (os *OauthService) VerifyLogoutToken(op, rawToken string) (err error, sub, sid string) {
+	err, oauthInfo, oauthConfig, provider := os.GetOauthConfig(op)
+	if err != nil {
+		return err, "", ""
+	}
+	if oauthInfo.OauthType != model.OauthTypeOidc && oauthInfo.OauthType != model.OauthTypeGoogle {
+		return errors.New("unsupported OAuth type"), "", ""
+	}
+	if !isLogoutTokenTyp(rawToken) {
+		return errors.New("LogoutTokenTypeInvalid"), "", ""
+	}
+	ctx := oidc.ClientContext(context.Background(), getHTTPClientWithProxy())
+	// logout token 的 exp 是可选的, 由下面自行校验
+	v := provider.Verifier(&oidc.Config{ClientID: oauthConfig.ClientID, SkipExpiryCheck: true})
+	token, err := v.Verify(ctx, rawToken)
+	if err != nil {
+		Logger.Warn("LogoutTokenVerifyError: ", err)
+		return errors.New("LogoutTokenVerifyError"), "", ""
+	}
+	var claims struct {
+		Sub    string                     `json:"sub"`
+		Sid    string                     `json:"sid"`
+		Jti    string                     `json:"jti"`
+		Nonce  string                     `json:"nonce"`
+		Events map[string]json.RawMessage `json:"events"`
+	}
+	if err = token.Claims(&claims); err != nil {
+		return errors.New("LogoutTokenClaimsError"), "", ""
+	}
+	// https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation
+	if _, ok := claims.Events[model.BackChannelLogoutEvent]; !ok {
+		return errors.New("LogoutTokenEventMissing"), "", ""
+	}
+	if claims.Nonce != "" {
+		return errors.New("LogoutTokenNonceNotAllowed"), "", ""
+	}
+	if !token.Expiry.IsZero() && token.Expiry.Before(time.Now()) {
+		return errors.New("LogoutTokenExpired"), "", ""
+	}
+	if claims.Sub == "" && claims.Sid == "" {
+		return errors.New("LogoutTokenSubjectMissing"), "", ""
+	}
+	if claims.Jti == "" {
+		return errors.New("LogoutTokenJtiMissing"), "", ""
+	}
+	if logoutTokenReplayed(op, claims.Jti, token.Expiry) {
+		return errors.New("LogoutTokenReplayed"), "", ""
+	}
+	return nil, claims.Sub, claims.Sid
+}
+
+// logoutTokenJtiTTL 没有exp的logout token记录jti的时间
+const logoutTokenJtiTTL = 10 * time.Minute
+
+// isLogoutTokenTyp JWT头的typ必须是 logout+jwt, 避免把id_token等其他token当作logout token
+func isLogoutTokenTyp(rawToken string) bool {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	var header struct {
+		Typ string `json:"typ"`
+	}
+	if err = json.Unmarshal(b, &header); err != nil {
+		return false
+	}
+	typ := strings.ToLower(header.Typ)
+	return typ == "logout+jwt" || typ == "application/logout+jwt"
+}
+
+// logoutTokenReplayed 记录jti直到token过期, 已经记录过时返回true
+func logoutTokenReplayed(op, jti string, exp time.Time) bool {
+	if Cache == nil {
+		return false
+	}
+	ttl := logoutTokenJtiTTL
+	if !exp.IsZero() {
+		ttl = time.Until(exp)
+	}
+	sec := int(ttl / time.Second)
+	if sec < 1 {
+		sec = 1
+	}
+	n, err := Cache.Incr("oidc:logout:jti:"+op+":"+jti, 1, sec)
+	if err != nil {
+		Logger.Warn("record logout token jti failed: ", err)
+		return false
+	}
+	return n > 1
+}
+
 // getGithubPrimaryEmail: Get the primary email of the user from Github
 func (os *OauthService) getGithubPrimaryEmail(client *http.Client, githubUser *model.GithubUser) error {
 	// the client is already set with the token
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/cache"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+func TestSplitScopes(t *testing.T) {
+	got := splitScopes(" read_user , openid,,email ")
+	if want := []string{"read_user", "openid", "email"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got = splitScopes(" "); len(got) != 0 {
+		t.Fatalf("got %q", got)
+	}
+}
+
+// oauth2类型的provider没有jwks, 返回的 id_token 不能校验, 需要忽略
+func TestGenericCallbackIgnoresIdToken(t *testing.T) {
+	oldConfig, oldLogger := Config, Logger
+	defer func() { Config, Logger = oldConfig, oldLogger }()
+	Config = &config.Config{}
+	Logger = log.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"at","token_type":"bearer","id_token":"not.a.jwt"}`))
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":42,"login":"Alice"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	os := &OauthService{}
+	oa := &model.Oauth{
+		OauthType:    model.OauthTypeOauth2,
+		ClientId:     "c",
+		ClientSecret: "s",
+		AuthUrl:      srv.URL + "/auth",
+		TokenUrl:     srv.URL + "/token",
+		UserInfoUrl:  srv.URL + "/user",
+		IdPath:       "id",
+		UsernamePath: "login",
+	}
+	provider := os.GenericProvider(oa)
+	oc := &oauth2.Config{ClientID: oa.ClientId, ClientSecret: oa.ClientSecret, Endpoint: provider.Endpoint()}
+	err, u := os.genericCallback(oa, oc, provider, "code", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.OpenId != "42" || u.Username != "alice" || u.IdToken != "" {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+}
+
+func TestSyncOauthClaims(t *testing.T) {
+	newTestDB(t, &model.User{}, &model.Group{}, &model.Oauth{})
+	DB.Create(&model.Oauth{Op: "oidc", OauthType: model.OauthTypeOidc, RoleClaim: "roles", AdminRoles: "admin"})
+	yes := true
+	// 保留一个管理员, 否则不能取消最后一个管理员
+	DB.Create(&model.User{Username: "root", IsAdmin: &yes})
+	u := &model.User{Username: "local-admin", IsAdmin: &yes}
+	DB.Create(u)
+	us := &UserService{}
+	sync := func(claims map[string]interface{}) bool {
+		us.SyncOauthClaims(u, &model.OauthUser{Claims: claims}, "oidc")
+		return us.IsAdmin(us.InfoById(u.Id))
+	}
+
+	// IdP没有返回claim, 不修改本地设置的管理员
+	if !sync(map[string]interface{}{"sub": "1"}) {
+		t.Fatal("missing role claim demoted a local admin")
+	}
+	if sync(map[string]interface{}{"roles": []interface{}{"user"}}) {
+		t.Fatal("role claim without an admin role did not demote")
+	}
+	if !sync(map[string]interface{}{"roles": []interface{}{"admin"}}) {
+		t.Fatal("admin role claim did not promote")
+	}
+	if sync(map[string]interface{}{"roles": []interface{}{}}) {
+		t.Fatal("empty role claim did not demote")
+	}
+}
+
+func TestIsLogoutTokenTyp(t *testing.T) {
+	token := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + ".e30.sig"
+	}
+	cases := map[string]bool{
+		token(`{"alg":"RS256","typ":"logout+jwt"}`):             true,
+		token(`{"alg":"RS256","typ":"application/logout+jwt"}`): true,
+		token(`{"alg":"RS256","typ":"JWT"}`):                    false,
+		token(`{"alg":"RS256"}`):                                false,
+		"not-a-jwt":                                             false,
+	}
+	for raw, want := range cases {
+		if got := isLogoutTokenTyp(raw); got != want {
+			t.Errorf("isLogoutTokenTyp(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestLogoutTokenReplayed(t *testing.T) {
+	old := Cache
+	Cache = cache.NewMemoryCache(0)
+	t.Cleanup(func() { Cache = old })
+	exp := time.Now().Add(time.Minute)
+	if logoutTokenReplayed("oidc", "jti-1", exp) {
+		t.Fatal("first use reported as replay")
+	}
+	if !logoutTokenReplayed("oidc", "jti-1", exp) {
+		t.Fatal("replayed jti accepted")
+	}
+	if logoutTokenReplayed("oidc", "jti-2", time.Time{}) || logoutTokenReplayed("other", "jti-1", exp) {
+		t.Fatal("different jti or op reported as replay")
+	}
+}
+
+func TestLogoutByOidcSessionMatchesSub(t *testing.T) {
+	newTestDB(t, &model.UserToken{}, &model.Peer{})
+	DB.Create(&model.UserToken{UserId: 1, Token: "a", Op: "oidc", OpenId: "alice", Sid: "s1"})
+	DB.Create(&model.UserToken{UserId: 2, Token: "b", Op: "oidc", OpenId: "bob", Sid: "s1"})
+	DB.Create(&model.UserToken{UserId: 2, Token: "c", Op: "oidc", OpenId: "bob", Sid: "s2"})
+	us := &UserService{}
+
+	// sid相同, 只吊销sub匹配的
+	n, err := us.LogoutByOidcSession("oidc", "alice", "s1")
+	if err != nil || n != 1 {
+		t.Fatalf("revoked %d, err %v", n, err)
+	}
+	var left []string
+	DB.Model(&model.UserToken{}).Order("token").Pluck("token", &left)
+	if !reflect.DeepEqual(left, []string{"b", "c"}) {
+		t.Fatalf("unexpected tokens left: %v", left)
+	}
+	// 只有sub时吊销该sub的所有会话
+	if n, err = us.LogoutByOidcSession("oidc", "bob", ""); err != nil || n != 2 {
+		t.Fatalf("revoked %d, err %v", n, err)
+	}
+}
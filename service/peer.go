@@ -37,8 +37,13 @@ func (ps *PeerService) UuidBindUserId(deviceId string, uuid string, userId uint)
 	peer := ps.FindByUuid(uuid)
 	// 如果存在则更新
 	if peer.RowId > 0 {
+		oldUserId := peer.UserId
 		peer.UserId = userId
 		ps.Update(peer)
+		if oldUserId != userId {
+			ps.syncMyDevices(oldUserId)
+		}
+		ps.syncMyDevices(userId)
 	} else {
 		// 不存在则创建
 		/*if deviceId != "" {
@@ -56,6 +61,14 @@ func (ps *PeerService) UuidUnbindUserId(uuid string, userId uint) {
 	peer := ps.FindByUserIdAndUuid(uuid, userId)
 	if peer.RowId > 0 {
 		DB.Model(peer).Update("user_id", 0)
+		ps.syncMyDevices(userId)
+	}
+}
+
+// syncMyDevices 设备所属用户变化后同步"我的设备"地址簿
+func (ps *PeerService) syncMyDevices(userId uint) {
+	if err := AllService.AddressBookService.SyncMyDevices(userId); err != nil {
+		Logger.Warn("sync my devices failed, user: ", userId, ", err: ", err)
 	}
 }
 
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"sync"
+	"time"
+)
+
+// PeerHeartbeat 心跳中需要写入数据库的字段
+type PeerHeartbeat struct {
+	LastOnlineTime int64  `json:"last_online_time"`
+	LastOnlineIp   string `json:"last_online_ip"`
+}
+
+// PeerHeartbeatStore 心跳缓存, 心跳先写入缓存, 查看设备列表时再批量写入数据库
+type PeerHeartbeatStore interface {
+	Has(uuid string) bool
+	Set(uuid string, hb PeerHeartbeat)
+	// Drain 取出并清空全部缓存
+	Drain() map[string]PeerHeartbeat
+}
+
+// PeerHeartbeats 默认在进程内存中, 多实例部署时替换为 RedisPeerHeartbeatStore
+// 这样任一实例写库时都会取出所有实例收到的心跳
+var PeerHeartbeats PeerHeartbeatStore = NewMemoryPeerHeartbeatStore()
+
+type MemoryPeerHeartbeatStore struct {
+	mu    sync.Mutex
+	items map[string]PeerHeartbeat
+}
+
+func NewMemoryPeerHeartbeatStore() *MemoryPeerHeartbeatStore {
+	return &MemoryPeerHeartbeatStore{items: make(map[string]PeerHeartbeat)}
+}
+
+func (s *MemoryPeerHeartbeatStore) Has(uuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[uuid]
+	return ok
+}
+
+func (s *MemoryPeerHeartbeatStore) Set(uuid string, hb PeerHeartbeat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[uuid] = hb
+}
+
+func (s *MemoryPeerHeartbeatStore) Drain() map[string]PeerHeartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.items
+	s.items = make(map[string]PeerHeartbeat)
+	return items
+}
+
+// 取出和删除需要是原子的, 否则两个实例同时写库时心跳会丢失或重复写入
+var redisDrainScript = redis.NewScript(`local v = redis.call("hgetall", KEYS[1]) redis.call("del", KEYS[1]) return v`)
+
+// RedisPeerHeartbeatStore 保存在redis的一个hash中, field 为uuid
+type RedisPeerHeartbeatStore struct {
+	rdb redis.UniversalClient
+	key string
+}
+
+func NewRedisPeerHeartbeatStore(rdb redis.UniversalClient, key string) *RedisPeerHeartbeatStore {
+	return &RedisPeerHeartbeatStore{rdb: rdb, key: key}
+}
+
+func (s *RedisPeerHeartbeatStore) Has(uuid string) bool {
+	ok, err := s.rdb.HExists(context.Background(), s.key, uuid).Result()
+	if err != nil {
+		Logger.Warn("redis peer heartbeat: ", err)
+	}
+	return ok
+}
+
+func (s *RedisPeerHeartbeatStore) Set(uuid string, hb PeerHeartbeat) {
+	b, _ := json.Marshal(hb)
+	if err := s.rdb.HSet(context.Background(), s.key, uuid, b).Err(); err != nil {
+		Logger.Warn("redis peer heartbeat: ", err)
+	}
+}
+
+func (s *RedisPeerHeartbeatStore) Drain() map[string]PeerHeartbeat {
+	res := make(map[string]PeerHeartbeat)
+	kv, err := redisDrainScript.Run(context.Background(), s.rdb, []string{s.key}).StringSlice()
+	if err != nil {
+		Logger.Warn("redis peer heartbeat: ", err)
+		return res
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		hb := PeerHeartbeat{}
+		if json.Unmarshal([]byte(kv[i+1]), &hb) == nil {
+			res[kv[i]] = hb
+		}
+	}
+	return res
+}
+
+// Heartbeat 记录心跳, 设备不存在时返回false
+func (ps *PeerService) Heartbeat(uuid, ip string) bool {
+	if !PeerHeartbeats.Has(uuid) {
+		if p := ps.FindByUuid(uuid); p.RowId == 0 {
+			return false
+		}
+	}
+	PeerHeartbeats.Set(uuid, PeerHeartbeat{LastOnlineTime: time.Now().Unix(), LastOnlineIp: ip})
+	return true
+}
+
+// FlushHeartbeats 把缓存的心跳写入数据库, 只更新在线时间和ip
+func (ps *PeerService) FlushHeartbeats() {
+	for uuid, hb := range PeerHeartbeats.Drain() {
+		err := DB.Model(&model.Peer{}).Where("uuid = ?", uuid).Updates(map[string]interface{}{
+			"last_online_time": hb.LastOnlineTime,
+			"last_online_ip":   hb.LastOnlineIp,
+		}).Error
+		if err != nil {
+			Logger.Error("update peer heartbeat failed: ", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/servercmd"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RelayServerService struct {
+}
+
+func (rs *RelayServerService) InfoById(id uint) *model.RelayServer {
+	r := &model.RelayServer{}
+	DB.Where("id = ?", id).First(r)
+	return r
+}
+
+func (rs *RelayServerService) InfoByName(name string) *model.RelayServer {
+	r := &model.RelayServer{}
+	DB.Where("name = ?", name).First(r)
+	return r
+}
+
+func (rs *RelayServerService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.RelayServerList) {
+	res = &model.RelayServerList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.RelayServer{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("region asc, name asc").Find(&res.RelayServers)
+	return
+}
+
+// Create 创建
+func (rs *RelayServerService) Create(r *model.RelayServer) error {
+	return DB.Create(r).Error
+}
+
+// Update 更新, Select 才能把字段更新为空
+func (rs *RelayServerService) Update(r *model.RelayServer) error {
+	return DB.Model(r).Select("name", "region", "admin_addr", "cmd_target", "public_addr", "status", "remark").Updates(r).Error
+}
+
+func (rs *RelayServerService) Delete(r *model.RelayServer) error {
+	return DB.Delete(r).Error
+}
+
+// cmdTarget 中继的命令连接方式, 引用的 cmd-targets 不存在时返回nil
+func (rs *RelayServerService) cmdTarget(r *model.RelayServer) *config.CmdTarget {
+	if r.CmdTarget != "" {
+		t := AllService.ServerCmdService.CmdTarget(r.CmdTarget)
+		if t == nil {
+			return nil
+		}
+		cp := *t
+		cp.Type = config.CmdTargetRelayServer
+		return &cp
+	}
+	t := &config.CmdTarget{Name: r.Name, Type: config.CmdTargetRelayServer, Addr: r.AdminAddr}
+	t.Init()
+	return t
+}
+
+// SendCmd 并发发送命令到选中的中继, 结果和中继的顺序一致, 已知命令的输出会被解析
+func (rs *RelayServerService) SendCmd(ctx context.Context, relays []*model.RelayServer, cmd string, arg string) []*model.RelayCmdResult {
+	res := make([]*model.RelayCmdResult, len(relays))
+	wg := sync.WaitGroup{}
+	for i, r := range relays {
+		res[i] = &model.RelayCmdResult{Id: r.Id, Name: r.Name, Region: r.Region}
+		t := rs.cmdTarget(r)
+		if t == nil {
+			res[i].Error = ErrCmdTargetNotFound.Error()
+			continue
+		}
+		wg.Add(1)
+		go func(item *model.RelayCmdResult, t *config.CmdTarget) {
+			defer wg.Done()
+			out, err := AllService.ServerCmdService.SendCmdToTarget(ctx, t, cmd, arg)
+			item.Output = out
+			if err != nil {
+				item.Error = err.Error()
+				return
+			}
+			item.Parsed = servercmd.Parse(cmd, arg, out)
+		}(res[i], t)
+	}
+	wg.Wait()
+	return res
+}
+
+// SendCmdToAll 发送命令到所有启用的中继, 没有登记中继时发送到本机的hbbr
+func (rs *RelayServerService) SendCmdToAll(ctx context.Context, cmd string, arg string) []*model.RelayCmdResult {
+	relays := rs.List(1, 9999, func(tx *gorm.DB) {
+		tx.Where("status = ?", model.COMMON_STATUS_ENABLE)
+	}).RelayServers
+	if len(relays) > 0 {
+		return rs.SendCmd(ctx, relays, cmd, arg)
+	}
+	item := &model.RelayCmdResult{}
+	out, err := AllService.ServerCmdService.SendCmd(ctx, Config.Admin.RelayServerPort, cmd, arg)
+	item.Output = out
+	if err != nil {
+		item.Error = err.Error()
+	} else {
+		item.Parsed = servercmd.Parse(cmd, arg, out)
+	}
+	return []*model.RelayCmdResult{item}
+}
+
+// RelayServersValue hbbs relay-servers 的值, 启用的中继的 PublicAddr
+func (rs *RelayServerService) RelayServersValue() string {
+	var addrs []string
+	DB.Model(&model.RelayServer{}).Where("status = ? and public_addr <> ''", model.COMMON_STATUS_ENABLE).
+		Order("region asc, name asc").Pluck("public_addr", &addrs)
+	return strings.Join(addrs, ",")
+}
+
+// Sync 把 relay-servers 同步到hbbs, 见 ServerCmdService.SendCmdToIdServers
+// 没有启用的中继时不同步, 返回错误
+func (rs *RelayServerService) Sync(ctx context.Context) []*model.RelaySyncResult {
+	val := rs.RelayServersValue()
+	if val == "" {
+		return []*model.RelaySyncResult{{Error: "no enabled relay server, hbbs relay-servers unchanged"}}
+	}
+	return AllService.ServerCmdService.SendCmdToIdServers(ctx, "relay-servers", val)
+}
+
+// SyncChanged 中继修改或删除后同步, before 为修改前的中继
+// 最后一个启用的中继被禁用或删除时, 把hbbs恢复为配置的 rustdesk.relay-server, 只在这时恢复一次
+func (rs *RelayServerService) SyncChanged(ctx context.Context, before *model.RelayServer) []*model.RelaySyncResult {
+	if rs.RelayServersValue() != "" || before == nil || before.Status != model.COMMON_STATUS_ENABLE || before.PublicAddr == "" {
+		return rs.Sync(ctx)
+	}
+	val := strings.TrimSpace(Config.Rustdesk.RelayServer)
+	if val == "" {
+		// hbbs不能清空 relay-servers
+		return []*model.RelaySyncResult{{Error: "no enabled relay server and rustdesk.relay-server is empty, hbbs relay-servers unchanged"}}
+	}
+	return AllService.ServerCmdService.SendCmdToIdServers(ctx, "relay-servers", val)
+}
+
+// SyncEvery 定时同步, hbbs重启后通过命令设置的 relay-servers 会丢失
+// 没有启用的中继时不同步, 不覆盖hbbs自己的配置
+func (rs *RelayServerService) SyncEvery(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for range ticker.C {
+		if rs.RelayServersValue() == "" {
+			continue
+		}
+		for _, r := range rs.Sync(context.Background()) {
+			if r.Error != "" {
+				Logger.Warn("sync relay-servers to ", r.Target, " failed: ", r.Error)
+			}
+		}
+	}
+}
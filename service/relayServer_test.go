@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+// fakeIdServer 记录收到的命令并返回 ok
+func fakeIdServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	cmds := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// 命令没有换行, 一次读取
+			buf := make([]byte, 1024)
+			n, _ := conn.Read(buf)
+			cmds <- strings.TrimSpace(string(buf[:n]))
+			conn.Write([]byte("ok\n"))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), cmds
+}
+
+func TestRelayServerSync(t *testing.T) {
+	db := newTestDB(t, &model.RelayServer{})
+	addr, cmds := fakeIdServer(t)
+	Config.Admin.CmdTargets = []config.CmdTarget{{Name: "hbbs", Type: config.CmdTargetIdServer, Addr: addr, Timeout: time.Second}}
+	Config.Rustdesk.RelayServer = "default.example.com:21117"
+	rs := &RelayServerService{}
+	recv := func() string {
+		select {
+		case c := <-cmds:
+			return c
+		case <-time.After(2 * time.Second):
+			t.Fatal("no command received")
+			return ""
+		}
+	}
+
+	r := &model.RelayServer{Name: "r1", PublicAddr: "r1.example.com:21117", Status: model.COMMON_STATUS_ENABLE}
+	db.Create(r)
+	sync := func() {
+		for _, res := range rs.Sync(context.Background()) {
+			if res.Error != "" {
+				t.Fatal(res.Error)
+			}
+		}
+	}
+	sync()
+	if c := recv(); c != "relay-servers r1.example.com:21117" {
+		t.Fatalf("unexpected cmd %q", c)
+	}
+
+	// 最后一个中继被禁用后恢复一次为配置的默认中继
+	before := *r
+	db.Model(r).Update("status", model.COMMON_STATUS_DISABLED)
+	for _, res := range rs.SyncChanged(context.Background(), &before) {
+		if res.Error != "" {
+			t.Fatal(res.Error)
+		}
+	}
+	if c := recv(); c != "relay-servers default.example.com:21117" {
+		t.Fatalf("unexpected cmd %q", c)
+	}
+
+	// 之后的同步(包括定时同步)不再覆盖hbbs
+	r.Status = model.COMMON_STATUS_DISABLED
+	for _, res := range append(rs.Sync(context.Background()), rs.SyncChanged(context.Background(), r)...) {
+		if res.Error == "" {
+			t.Fatalf("want an error when no relay is enabled, got %+v", res)
+		}
+	}
+	select {
+	case c := <-cmds:
+		t.Fatalf("unexpected cmd %q", c)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	Config.Rustdesk.RelayServer = ""
+	res := rs.SyncChanged(context.Background(), &before)
+	if len(res) != 1 || res[0].Error == "" {
+		t.Fatalf("want an error when nothing can be synced, got %+v", res)
+	}
+}
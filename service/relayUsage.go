@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"github.com/lejianwen/rustdesk-api/v2/lib/servercmd"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// RelayUsageMaxPoints 降采样后每条曲线最多的点数, 超过时自动加大步长
+const RelayUsageMaxPoints = 2000
+
+// RelayUsageMaxRange 一次查询的最大时间范围(秒)
+const RelayUsageMaxRange = 31 * 86400
+
+type RelayUsageService struct {
+}
+
+// Collect 对所有启用的中继执行 usage 并保存采样; 没有登记中继时采集本机的hbbr
+// 单个中继失败不影响其他中继, 返回成功保存的采样数
+func (rus *RelayUsageService) Collect(ctx context.Context) int {
+	now := time.Now().Unix()
+	results := AllService.RelayServerService.SendCmdToAll(ctx, "usage", "")
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			Logger.Warn("collect relay usage of ", r.Name, " failed: ", r.Error)
+			continue
+		}
+		p, ok := r.Parsed.(*servercmd.Output)
+		if !ok {
+			continue
+		}
+		items, _ := p.Data.([]servercmd.UsageItem)
+		if err := rus.save(r, items, now); err != nil {
+			Logger.Error("save relay usage of ", r.Name, " failed: ", err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func (rus *RelayUsageService) save(r *model.RelayCmdResult, items []servercmd.UsageItem, at int64) error {
+	u := &model.RelayUsage{RelayId: r.Id, RelayName: r.Name, Sessions: len(items), SampledAt: at}
+	for _, it := range items {
+		u.SpeedKbps += it.SpeedKbps
+		u.TotalMB += it.TotalMB
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		conns := make([]*model.RelayUsageConn, 0, len(items))
+		for _, it := range items {
+			conns = append(conns, &model.RelayUsageConn{
+				UsageId:     u.Id,
+				RelayId:     r.Id,
+				ConnKey:     it.Key,
+				Elapsed:     it.Elapsed,
+				TotalMB:     it.TotalMB,
+				HighestKbps: it.HighestKbps,
+				AvgKbps:     it.AvgKbps,
+				SpeedKbps:   it.SpeedKbps,
+				SampledAt:   at,
+			})
+		}
+		return tx.CreateInBatches(conns, 100).Error
+	})
+}
+
+// Clean 删除 before(unix秒) 之前的采样
+func (rus *RelayUsageService) Clean(before int64) (int64, error) {
+	if err := DB.Where("sampled_at < ?", before).Delete(&model.RelayUsageConn{}).Error; err != nil {
+		return 0, err
+	}
+	res := DB.Where("sampled_at < ?", before).Delete(&model.RelayUsage{})
+	return res.RowsAffected, res.Error
+}
+
+// CollectEvery 定时采集, retention 大于0时同时删除超过保留时间的采样
+func (rus *RelayUsageService) CollectEvery(d, retention time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for range ticker.C {
+		// 多实例部署时只有一个实例采集, 租约比间隔略短, 避免错过下一次
+		if !Lock.TryLease("relay_usage_collect", d*9/10) {
+			continue
+		}
+		rus.Collect(context.Background())
+		if retention <= 0 {
+			continue
+		}
+		if _, err := rus.Clean(time.Now().Add(-retention).Unix()); err != nil {
+			Logger.Error("clean relay usage failed: ", err)
+		}
+	}
+}
+
+// Series 查询 [start, end) 之间的用量, 按中继分组并在数据库中降采样; relayIds 为空时查询所有中继
+// step 为0时按 RelayUsageMaxPoints 自动计算; 范围超过 RelayUsageMaxRange 时只查询最后的 RelayUsageMaxRange
+func (rus *RelayUsageService) Series(relayIds []uint, start, end, step int64) []*model.RelayUsageSeries {
+	if end-start > RelayUsageMaxRange {
+		start = end - RelayUsageMaxRange
+	}
+	step = relayUsageStep(start, end, step)
+	where := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Model(&model.RelayUsage{}).Where("sampled_at >= ? and sampled_at < ?", start, end)
+		if len(relayIds) > 0 {
+			tx = tx.Where("relay_id in ?", relayIds)
+		}
+		return tx
+	}
+	var buckets []*relayUsageBucket
+	where(DB).Select("relay_id, sampled_at - sampled_at % ? as bucket, count(*) as samples, "+
+		"avg(sessions) as sessions, max(sessions) as max_sessions, avg(speed_kbps) as speed_kbps, max(speed_kbps) as max_speed_kbps", step).
+		Group("relay_id, bucket").Order("relay_id asc, bucket asc").Scan(&buckets)
+	// 使用最近一次采样时的名称
+	var latest []*model.RelayUsage
+	DB.Where("id in (?)", where(DB).Select("max(id)").Group("relay_id")).Find(&latest)
+	names := make(map[uint]string, len(latest))
+	for _, l := range latest {
+		names[l.RelayId] = l.RelayName
+	}
+	res := make([]*model.RelayUsageSeries, 0)
+	var cur *model.RelayUsageSeries
+	for _, b := range buckets {
+		if cur == nil || cur.RelayId != b.RelayId {
+			cur = &model.RelayUsageSeries{RelayId: b.RelayId, RelayName: names[b.RelayId], Step: step, Points: make([]*model.RelayUsagePoint, 0)}
+			res = append(res, cur)
+		}
+		cur.Points = append(cur.Points, &model.RelayUsagePoint{
+			Time:         b.Bucket,
+			Samples:      b.Samples,
+			Sessions:     b.Sessions,
+			MaxSessions:  b.MaxSessions,
+			SpeedKbps:    b.SpeedKbps,
+			MaxSpeedKbps: b.MaxSpeedKbps,
+		})
+	}
+	return res
+}
+
+// relayUsageBucket Series 中一个中继一个时间段的聚合结果
+type relayUsageBucket struct {
+	RelayId      uint
+	Bucket       int64
+	Samples      int
+	Sessions     float64
+	MaxSessions  int
+	SpeedKbps    float64
+	MaxSpeedKbps int64
+}
+
+// ConnList 单个连接的采样明细
+func (rus *RelayUsageService) ConnList(page, pageSize uint, where func(tx *gorm.DB)) (res *model.RelayUsageConnList) {
+	res = &model.RelayUsageConnList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.RelayUsageConn{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("sampled_at desc, id asc").Find(&res.RelayUsageConns)
+	return
+}
+
+// relayUsageStep 步长至少为1秒, 且点数不超过 RelayUsageMaxPoints
+func relayUsageStep(start, end, step int64) int64 {
+	span := end - start
+	if span <= 0 {
+		span = 1
+	}
+	minStep := (span + RelayUsageMaxPoints - 1) / RelayUsageMaxPoints
+	if step < minStep {
+		step = minStep
+	}
+	if step < 1 {
+		step = 1
+	}
+	return step
+}
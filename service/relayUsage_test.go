@@ -0,0 +1,62 @@
+package service
+
+import (
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"testing"
+)
+
+func TestRelayUsageStep(t *testing.T) {
+	cases := []struct {
+		start, end, step, want int64
+	}{
+		{0, 3600, 60, 60},
+		{0, 3600, 0, 2},
+		{0, 86400 * 30, 60, 1296},
+		{100, 100, 0, 1},
+	}
+	for _, c := range cases {
+		if got := relayUsageStep(c.start, c.end, c.step); got != c.want {
+			t.Errorf("relayUsageStep(%d, %d, %d) = %d, want %d", c.start, c.end, c.step, got, c.want)
+		}
+	}
+}
+
+func TestRelayUsageSeries(t *testing.T) {
+	newTestDB(t, &model.RelayUsage{})
+	DB.Create([]*model.RelayUsage{
+		{RelayId: 1, RelayName: "old", Sessions: 1, SpeedKbps: 100, SampledAt: 600},
+		{RelayId: 1, RelayName: "r1", Sessions: 3, SpeedKbps: 300, SampledAt: 660},
+		{RelayId: 1, RelayName: "r1", Sessions: 2, SpeedKbps: 50, SampledAt: 1250},
+		{RelayId: 2, RelayName: "r2", Sessions: 5, SpeedKbps: 10, SampledAt: 700},
+		{RelayId: 1, RelayName: "r1", Sessions: 9, SpeedKbps: 900, SampledAt: 2000},
+	})
+	got := (&RelayUsageService{}).Series(nil, 0, 1800, 300)
+	if len(got) != 2 {
+		t.Fatalf("got %d series, want 2", len(got))
+	}
+	s := got[0]
+	if s.RelayId != 1 || s.RelayName != "r1" || s.Step != 300 || len(s.Points) != 2 {
+		t.Fatalf("first series = %+v", *s)
+	}
+	p := s.Points[0]
+	if p.Time != 600 || p.Samples != 2 || p.Sessions != 2 || p.MaxSessions != 3 || p.SpeedKbps != 200 || p.MaxSpeedKbps != 300 {
+		t.Errorf("first point = %+v", *p)
+	}
+	p = s.Points[1]
+	if p.Time != 1200 || p.Samples != 1 || p.Sessions != 2 || p.MaxSpeedKbps != 50 {
+		t.Errorf("second point = %+v", *p)
+	}
+	if s = got[1]; s.RelayId != 2 || s.RelayName != "r2" || len(s.Points) != 1 || s.Points[0].MaxSessions != 5 {
+		t.Errorf("second series = %+v", *s)
+	}
+
+	got = (&RelayUsageService{}).Series([]uint{2}, 0, 1800, 300)
+	if len(got) != 1 || got[0].RelayId != 2 {
+		t.Errorf("filtered series = %+v", got)
+	}
+	// 超过 RelayUsageMaxRange 时只查询最后的部分
+	got = (&RelayUsageService{}).Series(nil, 0, 2100+RelayUsageMaxRange, 0)
+	if len(got) != 0 {
+		t.Errorf("range not capped: %+v", got)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"gorm.io/gorm"
+	"strings"
+)
+
+type RoleService struct {
+}
+
+// InfoById 根据id取角色, 包含绑定的用户和分组
+func (rs *RoleService) InfoById(id uint) *model.Role {
+	r := &model.Role{}
+	DB.Where("id = ?", id).First(r)
+	if r.Id > 0 {
+		rs.loadBindings(r)
+	}
+	return r
+}
+
+func (rs *RoleService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.RoleList) {
+	res = &model.RoleList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.Role{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Find(&res.Roles)
+	for _, r := range res.Roles {
+		rs.loadBindings(r)
+	}
+	return
+}
+
+func (rs *RoleService) loadBindings(r *model.Role) {
+	var bindings []*model.RoleBinding
+	DB.Where("role_id = ?", r.Id).Find(&bindings)
+	r.UserIds = []uint{}
+	r.GroupIds = []uint{}
+	for _, b := range bindings {
+		if b.UserId > 0 {
+			r.UserIds = append(r.UserIds, b.UserId)
+		} else if b.GroupId > 0 {
+			r.GroupIds = append(r.GroupIds, b.GroupId)
+		}
+	}
+}
+
+// FormatPermissions 校验并去重权限
+func (rs *RoleService) FormatPermissions(perms []string) (string, error) {
+	all := model.AllPermissions()
+	var res []string
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if p == "" || utils.InArray(p, res) {
+			continue
+		}
+		if p != model.PermissionAll && !utils.InArray(p, all) {
+			return "", errors.New("invalid permission: " + p)
+		}
+		res = append(res, p)
+	}
+	return strings.Join(res, ","), nil
+}
+
+// Create 创建
+func (rs *RoleService) Create(r *model.Role) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		return rs.saveBindings(tx, r)
+	})
+}
+
+// Update 更新, 绑定关系整体替换
+func (rs *RoleService) Update(r *model.Role) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(r).Select("name", "remark", "permissions").Updates(r).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", r.Id).Delete(&model.RoleBinding{}).Error; err != nil {
+			return err
+		}
+		return rs.saveBindings(tx, r)
+	})
+}
+
+func (rs *RoleService) saveBindings(tx *gorm.DB, r *model.Role) error {
+	var bindings []*model.RoleBinding
+	for _, uid := range r.UserIds {
+		bindings = append(bindings, &model.RoleBinding{RoleId: r.Id, UserId: uid})
+	}
+	for _, gid := range r.GroupIds {
+		bindings = append(bindings, &model.RoleBinding{RoleId: r.Id, GroupId: gid})
+	}
+	if len(bindings) == 0 {
+		return nil
+	}
+	return tx.Create(&bindings).Error
+}
+
+func (rs *RoleService) Delete(r *model.Role) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", r.Id).Delete(&model.RoleBinding{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(r).Error
+	})
+}
+
+// UserPermissions 用户的有效权限, 包含直接分配和通过分组分配的角色, 管理员拥有全部权限
+func (rs *RoleService) UserPermissions(u *model.User) []string {
+	if u == nil || u.Id == 0 {
+		return nil
+	}
+	if AllService.UserService.IsAdmin(u) {
+		return []string{model.PermissionAll}
+	}
+	var roleIds []uint
+	tx := DB.Model(&model.RoleBinding{})
+	if u.GroupId > 0 {
+		tx.Where("user_id = ? or group_id = ?", u.Id, u.GroupId)
+	} else {
+		tx.Where("user_id = ?", u.Id)
+	}
+	tx.Distinct().Pluck("role_id", &roleIds)
+	return rs.permissionsOfRoles(roleIds)
+}
+
+// GroupPermissions 分组绑定的角色的权限, 即加入分组后获得的权限
+func (rs *RoleService) GroupPermissions(groupId uint) []string {
+	if groupId == 0 {
+		return nil
+	}
+	var roleIds []uint
+	DB.Model(&model.RoleBinding{}).Where("group_id = ?", groupId).Distinct().Pluck("role_id", &roleIds)
+	return rs.permissionsOfRoles(roleIds)
+}
+
+func (rs *RoleService) permissionsOfRoles(roleIds []uint) []string {
+	if len(roleIds) == 0 {
+		return nil
+	}
+	var roles []*model.Role
+	DB.Where("id in (?)", roleIds).Find(&roles)
+	var res []string
+	for _, r := range roles {
+		for _, p := range r.PermissionList() {
+			if !utils.InArray(p, res) {
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
+// CanManageUser 非管理员(通过角色获得users权限)管理用户时不能获得或授予超出自己的权限:
+// 不能创建/修改管理员, 也不能授予管理员; 不能修改自己的分组;
+// 不能管理权限超出自己的用户(如重置其密码); 不能把用户放入权限超出自己的分组
+// target 为修改前的用户(创建时为nil), data 为提交的数据(删除、修改密码时为nil)
+func (rs *RoleService) CanManageUser(actor, target, data *model.User) bool {
+	if AllService.UserService.IsAdmin(actor) {
+		return true
+	}
+	if actor == nil || actor.Id == 0 {
+		return false
+	}
+	if AllService.UserService.IsAdmin(target) || AllService.UserService.IsAdmin(data) {
+		return false
+	}
+	perms := rs.UserPermissions(actor)
+	if target != nil {
+		if data != nil && target.Id == actor.Id && data.GroupId != target.GroupId {
+			return false
+		}
+		if !model.CoversPermissions(perms, rs.UserPermissions(target)) {
+			return false
+		}
+	}
+	if data != nil && (target == nil || data.GroupId != target.GroupId) {
+		return model.CoversPermissions(perms, rs.GroupPermissions(data.GroupId))
+	}
+	return true
+}
+
+// HasPermission 用户是否有 resource:action 权限
+func (rs *RoleService) HasPermission(u *model.User, resource, action string) bool {
+	return model.HasPermission(rs.UserPermissions(u), resource, action)
+}
+
+// DeleteBindingsByUserId 删除用户时删除绑定
+func (rs *RoleService) DeleteBindingsByUserId(tx *gorm.DB, userId uint) error {
+	return tx.Where("user_id = ?", userId).Delete(&model.RoleBinding{}).Error
+}
+
+// DeleteBindingsByGroupId 删除分组时删除绑定
+func (rs *RoleService) DeleteBindingsByGroupId(groupId uint) error {
+	return DB.Where("group_id = ?", groupId).Delete(&model.RoleBinding{}).Error
+}
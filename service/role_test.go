@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+func TestCanManageUser(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Role{}, &model.RoleBinding{})
+	// 分组1: 只有users:write; 分组2: 全部权限; 分组3: users:write + peers:read
+	roles := []*model.Role{
+		{Name: "user-admin", Permissions: "users:write"},
+		{Name: "super", Permissions: "*"},
+		{Name: "peer-reader", Permissions: "peers:read"},
+	}
+	db.Create(&roles)
+	db.Create(&[]*model.RoleBinding{
+		{RoleId: roles[0].Id, GroupId: 1},
+		{RoleId: roles[1].Id, GroupId: 2},
+		{RoleId: roles[0].Id, GroupId: 3},
+		{RoleId: roles[2].Id, GroupId: 3},
+	})
+	yes, no := true, false
+	admin := &model.User{Username: "admin", GroupId: 1, IsAdmin: &yes}
+	actor := &model.User{Username: "actor", GroupId: 1, IsAdmin: &no}
+	other := &model.User{Username: "other", GroupId: 1, IsAdmin: &no}
+	super := &model.User{Username: "super", GroupId: 2, IsAdmin: &no}
+	db.Create(&[]*model.User{admin, actor, other, super})
+
+	rs := &RoleService{}
+	withGroup := func(u *model.User, gid uint) *model.User {
+		cp := *u
+		cp.GroupId = gid
+		return &cp
+	}
+	cases := []struct {
+		name         string
+		actor        *model.User
+		target, data *model.User
+		want         bool
+	}{
+		{"admin moves anyone", admin, other, withGroup(other, 2), true},
+		{"create in same-permission group", actor, nil, withGroup(other, 1), true},
+		{"create in broader group", actor, nil, withGroup(other, 2), false},
+		{"move other to broader group", actor, other, withGroup(other, 2), false},
+		{"move other to partially broader group", actor, other, withGroup(other, 3), false},
+		{"edit other without group change", actor, other, withGroup(other, 1), true},
+		{"edit own group", actor, actor, withGroup(actor, 2), false},
+		{"edit own group to same permissions", actor, actor, withGroup(actor, 0), false},
+		{"edit self without group change", actor, actor, withGroup(actor, 1), true},
+		{"reset password of broader user", actor, super, nil, false},
+		{"reset password of peer", actor, other, nil, true},
+		{"grant admin", actor, other, &model.User{IdModel: other.IdModel, GroupId: 1, IsAdmin: &yes}, false},
+		{"edit admin", actor, admin, withGroup(admin, 1), false},
+		{"no actor", nil, other, nil, false},
+	}
+	for _, c := range cases {
+		if got := rs.CanManageUser(c.actor, c.target, c.data); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
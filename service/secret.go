@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"github.com/lejianwen/rustdesk-api/v2/lib/crypt"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+)
+
+type SecretService struct {
+}
+
+// secretColumn 使用 serializer:encrypted 的字段
+type secretColumn struct {
+	Name   string
+	Model  interface{}
+	Pk     string
+	Column string
+}
+
+var secretColumns = []secretColumn{
+	{"address_book.password", &model.AddressBook{}, "row_id", "password"},
+	{"share_record.password", &model.ShareRecord{}, "id", "password"},
+	{"oauth.client_secret", &model.Oauth{}, "id", "client_secret"},
+	{"user_token.id_token", &model.UserToken{}, "id", "id_token"},
+}
+
+const rekeyBatchSize = 500
+
+// Rekey 把明文和旧主密钥加密的值用当前主密钥重新加密, 返回每个字段更新的行数
+func (ss *SecretService) Rekey(k *crypt.Keyring) (map[string]int, error) {
+	res := make(map[string]int)
+	for _, sc := range secretColumns {
+		n, err := ss.rekeyColumn(k, sc)
+		res[sc.Name] = n
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", sc.Name, err)
+		}
+	}
+	return res, nil
+}
+
+func (ss *SecretService) rekeyColumn(k *crypt.Keyring, sc secretColumn) (int, error) {
+	n := 0
+	var lastId uint
+	for {
+		// 查询到map中, 不经过serializer, 拿到的是数据库中的原始值
+		var rows []map[string]interface{}
+		err := DB.Model(sc.Model).Select(sc.Pk, sc.Column).
+			Where(sc.Pk+" > ?", lastId).Order(sc.Pk + " asc").Limit(rekeyBatchSize).
+			Find(&rows).Error
+		if err != nil {
+			return n, err
+		}
+		for _, row := range rows {
+			id := toUint(row[sc.Pk])
+			lastId = id
+			raw := toString(row[sc.Column])
+			if !k.NeedsRekey(raw) {
+				continue
+			}
+			plain, err := k.Decrypt(raw)
+			if err != nil {
+				return n, fmt.Errorf("id %d: %w", id, err)
+			}
+			enc, err := k.Encrypt(plain)
+			if err != nil {
+				return n, err
+			}
+			// UpdateColumn 使用map, 同样不经过serializer, 不会二次加密
+			if err = DB.Model(sc.Model).Where(sc.Pk+" = ?", id).UpdateColumn(sc.Column, enc).Error; err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(rows) < rekeyBatchSize {
+			return n, nil
+		}
+	}
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	}
+	return ""
+}
+
+func toUint(v interface{}) uint {
+	switch val := v.(type) {
+	case int64:
+		return uint(val)
+	case uint64:
+		return uint(val)
+	case int32:
+		return uint(val)
+	case uint32:
+		return uint(val)
+	case int:
+		return uint(val)
+	case uint:
+		return val
+	}
+	return 0
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/servercmd"
 	"github.com/lejianwen/rustdesk-api/v2/model"
 	"net"
-	"time"
 )
 
 type ServerCmdService struct{}
@@ -39,16 +41,21 @@ func (is *ServerCmdService) Create(u *model.ServerCmd) error {
 	return res
 }
 
-// SendCmd 发送命令
-func (is *ServerCmdService) SendCmd(port int, cmd string, arg string) (string, error) {
+// SendCmd 发送命令到本机, ctx 没有期限时使用默认的超时
+func (is *ServerCmdService) SendCmd(ctx context.Context, port int, cmd string, arg string) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.DefaultCmdTargetTimeout)
+		defer cancel()
+	}
 	//组装命令
 	cmd = cmd + " " + arg
-	res, err := is.SendSocketCmd("v6", port, cmd)
-	if err == nil {
-		return res, nil
+	res, err := is.SendSocketCmd(ctx, "v6", port, cmd)
+	if err == nil || ctx.Err() != nil {
+		return res, err
 	}
 	//v6连接失败，尝试v4
-	res, err = is.SendSocketCmd("v4", port, cmd)
+	res, err = is.SendSocketCmd(ctx, "v4", port, cmd)
 	if err == nil {
 		return res, nil
 	}
@@ -56,34 +63,30 @@ func (is *ServerCmdService) SendCmd(port int, cmd string, arg string) (string, e
 }
 
 // SendSocketCmd
-func (is *ServerCmdService) SendSocketCmd(ty string, port int, cmd string) (string, error) {
+func (is *ServerCmdService) SendSocketCmd(ctx context.Context, ty string, port int, cmd string) (string, error) {
 	addr := "[::1]"
 	tcp := "tcp6"
 	if ty == "v4" {
 		tcp = "tcp"
 		addr = "127.0.0.1"
 	}
-	conn, err := net.Dial(tcp, fmt.Sprintf("%s:%v", addr, port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, tcp, fmt.Sprintf("%s:%v", addr, port))
 	if err != nil {
 		Logger.Debugf("%s connect to id server failed: %v", ty, err)
 		return "", err
 	}
 	defer conn.Close()
-	//发送命令
-	_, err = conn.Write([]byte(cmd))
+	return is.exchange(ctx, ty, conn, cmd)
+}
+
+// exchange 发送命令并读取全部返回
+func (is *ServerCmdService) exchange(ctx context.Context, ty string, conn net.Conn, cmd string) (string, error) {
+	res, err := servercmd.Exchange(ctx, conn, cmd, servercmd.DefaultIdle)
 	if err != nil {
 		Logger.Debugf("%s send cmd failed: %v", ty, err)
 		return "", err
 	}
-	time.Sleep(100 * time.Millisecond)
-	//读取返回
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
-	if err != nil && err.Error() != "EOF" {
-		Logger.Debugf("%s read response failed: %v", ty, err)
-		return "", err
-	}
-	return string(buf[:n]), nil
+	return res, nil
 }
 
 func (is *ServerCmdService) Update(f *model.ServerCmd) error {
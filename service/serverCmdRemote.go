@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// 错误的值为i18n的messageId
+var (
+	ErrCmdTargetNotFound = errors.New("ItemNotFound")
+	ErrCmdNotAllowed     = errors.New("NoAccess")
+)
+
+// Hosts 可以发送命令的hbbs/hbbr, 本机的在前
+func (is *ServerCmdService) Hosts() []*model.ServerCmdHost {
+	res := []*model.ServerCmdHost{
+		{Target: model.ServerCmdTargetIdServer},
+		{Target: model.ServerCmdTargetRelayServer},
+	}
+	for i := range Config.Admin.CmdTargets {
+		t := &Config.Admin.CmdTargets[i]
+		res = append(res, &model.ServerCmdHost{Name: t.Name, Target: is.TargetOf(t), Addr: t.Addr})
+	}
+	return res
+}
+
+// CmdTarget 按名称取远程目标
+func (is *ServerCmdService) CmdTarget(name string) *config.CmdTarget {
+	for i := range Config.Admin.CmdTargets {
+		if Config.Admin.CmdTargets[i].Name == name {
+			return &Config.Admin.CmdTargets[i]
+		}
+	}
+	return nil
+}
+
+// TargetOf 远程目标对应的命令类型
+func (is *ServerCmdService) TargetOf(t *config.CmdTarget) string {
+	if t.Type == config.CmdTargetRelayServer {
+		return model.ServerCmdTargetRelayServer
+	}
+	return model.ServerCmdTargetIdServer
+}
+
+// SendCmdTo 发送命令到名为 name 的远程目标
+func (is *ServerCmdService) SendCmdTo(ctx context.Context, name string, cmd string, arg string) (string, error) {
+	t := is.CmdTarget(name)
+	if t == nil {
+		return "", ErrCmdTargetNotFound
+	}
+	return is.SendCmdToTarget(ctx, t, cmd, arg)
+}
+
+// SendCmdToTarget 发送命令到远程目标, 整个过程不超过目标的 Timeout
+func (is *ServerCmdService) SendCmdToTarget(ctx context.Context, t *config.CmdTarget, cmd string, arg string) (string, error) {
+	if !is.cmdAllowed(t, cmd) {
+		return "", ErrCmdNotAllowed
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	conn, err := is.dialTarget(ctx, t)
+	if err != nil {
+		Logger.Debugf("%s connect failed: %v", t.Name, err)
+		return "", err
+	}
+	defer conn.Close()
+	line := cmd + " " + arg
+	if t.Secret != "" {
+		line = t.Secret + "\n" + line
+	}
+	return is.exchange(ctx, t.Name, conn, line)
+}
+
+// cmdAllowed 命令是否在目标的白名单中, 白名单中的命令的别名也允许
+func (is *ServerCmdService) cmdAllowed(t *config.CmdTarget, cmd string) bool {
+	if len(t.AllowCmds) == 0 {
+		return true
+	}
+	if utils.InArray(cmd, t.AllowCmds) {
+		return true
+	}
+	sys := model.SysIdServerCmds
+	if is.TargetOf(t) == model.ServerCmdTargetRelayServer {
+		sys = model.SysRelayServerCmds
+	}
+	for _, c := range sys {
+		if c.Alias == cmd && utils.InArray(c.Cmd, t.AllowCmds) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialTarget 直接或通过ssh隧道连接, 需要时再套上TLS
+func (is *ServerCmdService) dialTarget(ctx context.Context, t *config.CmdTarget) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if t.Ssh != "" {
+		conn, err = is.dialSsh(ctx, t)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", t.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !t.Tls {
+		return conn, nil
+	}
+	tc, err := is.tlsConfig(t)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, tc)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (is *ServerCmdService) tlsConfig(t *config.CmdTarget) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(t.Addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{ServerName: host, InsecureSkipVerify: t.TlsSkipVerify}
+	if t.TlsCaFile != "" {
+		pem, err := os.ReadFile(t.TlsCaFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("invalid tls-ca-file")
+		}
+		tc.RootCAs = pool
+	}
+	return tc, nil
+}
+
+// sshConn 关闭隧道中的连接时同时关闭ssh连接
+// ssh的channel不支持设置期限, 设置到底层的tcp连接上, 超时后整个ssh连接中断
+type sshConn struct {
+	net.Conn
+	client *ssh.Client
+	raw    net.Conn
+}
+
+func (c *sshConn) Close() error {
+	err := c.Conn.Close()
+	c.client.Close()
+	return err
+}
+
+func (c *sshConn) SetDeadline(t time.Time) error {
+	return c.raw.SetDeadline(t)
+}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error {
+	return c.raw.SetReadDeadline(t)
+}
+
+func (c *sshConn) SetWriteDeadline(t time.Time) error {
+	return c.raw.SetWriteDeadline(t)
+}
+
+// sshHostKeyCallback 校验主机密钥, 没有配置 ssh-known-hosts 时拒绝连接, 避免把密码发送给伪造的主机
+// 明确配置了 ssh-insecure 时才不校验
+func (is *ServerCmdService) sshHostKeyCallback(t *config.CmdTarget) (ssh.HostKeyCallback, error) {
+	if t.SshKnownHosts != "" {
+		return knownhosts.New(t.SshKnownHosts)
+	}
+	if !t.SshInsecure {
+		return nil, errors.New(t.Name + ": ssh-known-hosts is required, or set ssh-insecure: true")
+	}
+	Logger.Warnf("%s: ssh host key is not verified (ssh-insecure)", t.Name)
+	return ssh.InsecureIgnoreHostKey(), nil
+}
+
+// dialSsh 登录ssh服务器后从服务器连接 Addr
+func (is *ServerCmdService) dialSsh(ctx context.Context, t *config.CmdTarget) (net.Conn, error) {
+	user, host, ok := strings.Cut(t.Ssh, "@")
+	if !ok {
+		return nil, errors.New("ssh must be user@host:port")
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	var auth []ssh.AuthMethod
+	if t.SshKeyFile != "" {
+		key, err := os.ReadFile(t.SshKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if t.SshPassword != "" {
+		auth = append(auth, ssh.Password(t.SshPassword))
+	}
+	hostKey, err := is.sshHostKeyCallback(t)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	// ssh握手不支持ctx, 用连接的期限代替
+	if d, ok := ctx.Deadline(); ok {
+		_ = raw.SetDeadline(d)
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(raw, host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+	})
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	_ = raw.SetDeadline(time.Time{})
+	client := ssh.NewClient(sc, chans, reqs)
+	conn, err := client.DialContext(ctx, "tcp", t.Addr)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &sshConn{Conn: conn, client: client, raw: raw}, nil
+}
+
+// SendCmdToIdServers 发送命令到hbbs; 配置了 id-server 类型的 cmd-targets 时发送到这些目标, 否则发送到本机
+func (is *ServerCmdService) SendCmdToIdServers(ctx context.Context, cmd string, arg string) []*model.RelaySyncResult {
+	var res []*model.RelaySyncResult
+	for i := range Config.Admin.CmdTargets {
+		t := &Config.Admin.CmdTargets[i]
+		if t.Type != config.CmdTargetIdServer {
+			continue
+		}
+		item := &model.RelaySyncResult{Target: t.Name}
+		out, err := is.SendCmdToTarget(ctx, t, cmd, arg)
+		item.Output = out
+		if err != nil {
+			item.Error = err.Error()
+		}
+		res = append(res, item)
+	}
+	if res != nil {
+		return res
+	}
+	item := &model.RelaySyncResult{}
+	out, err := is.SendCmd(ctx, Config.Admin.IdServerPort-1, cmd, arg)
+	item.Output = out
+	if err != nil {
+		item.Error = err.Error()
+	}
+	return []*model.RelaySyncResult{item}
+}
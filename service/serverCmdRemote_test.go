@@ -0,0 +1,88 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lejianwen/rustdesk-api/v2/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestCmdAllowed(t *testing.T) {
+	is := &ServerCmdService{}
+	hbbs := &config.CmdTarget{Name: "hbbs", Type: config.CmdTargetIdServer, AllowCmds: []string{"relay-servers"}}
+	hbbr := &config.CmdTarget{Name: "hbbr", Type: config.CmdTargetRelayServer, AllowCmds: []string{"usage", "blocklist-add"}}
+	cases := []struct {
+		target *config.CmdTarget
+		cmd    string
+		want   bool
+	}{
+		{&config.CmdTarget{Name: "any"}, "ip-blocker", true},
+		{hbbs, "relay-servers", true},
+		{hbbs, "rs", true},
+		{hbbs, "ip-blocker", false},
+		{hbbs, "ib", false},
+		{hbbr, "usage", true},
+		{hbbr, "u", true},
+		{hbbr, "Ba", true},
+		// 别名区分大小写, B 是 blocklist 不是 blocklist-add
+		{hbbr, "B", false},
+		{hbbr, "blocklist", false},
+		// 别名只按目标的类型查找, rs 是hbbs的命令
+		{&config.CmdTarget{Type: config.CmdTargetRelayServer, AllowCmds: []string{"relay-servers"}}, "rs", false},
+	}
+	for _, c := range cases {
+		if got := is.cmdAllowed(c.target, c.cmd); got != c.want {
+			t.Errorf("cmdAllowed(%s, %q) = %v, want %v", c.target.Name, c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestSshHostKeyCallback(t *testing.T) {
+	oldLogger := Logger
+	defer func() { Logger = oldLogger }()
+	Logger = log.New()
+	is := &ServerCmdService{}
+
+	if _, err := is.sshHostKeyCallback(&config.CmdTarget{Name: "t", Ssh: "u@h:22"}); err == nil {
+		t.Fatal("want error without ssh-known-hosts")
+	}
+	if cb, err := is.sshHostKeyCallback(&config.CmdTarget{Name: "t", SshInsecure: true}); err != nil || cb == nil {
+		t.Fatalf("want insecure callback, got %v", err)
+	}
+
+	newKey := func() ssh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	known, other := newKey(), newKey()
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(file, []byte(knownhosts.Line([]string{"[h.example.com]:2222"}, known)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cb, err := is.sshHostKeyCallback(&config.CmdTarget{Name: "t", SshKnownHosts: file, SshInsecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	if err = cb("h.example.com:2222", addr, known); err != nil {
+		t.Fatalf("known key rejected: %v", err)
+	}
+	// 配置了 known_hosts 时 ssh-insecure 不生效
+	if err = cb("h.example.com:2222", addr, other); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lejianwen/rustdesk-api/v2/lib/cron"
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"gorm.io/gorm"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type ServerCmdScheduleService struct {
+}
+
+func (ss *ServerCmdScheduleService) InfoById(id uint) *model.ServerCmdSchedule {
+	s := &model.ServerCmdSchedule{}
+	DB.Where("id = ?", id).First(s)
+	return s
+}
+
+func (ss *ServerCmdScheduleService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *model.ServerCmdScheduleList) {
+	res = &model.ServerCmdScheduleList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.ServerCmdSchedule{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.ServerCmdSchedules)
+	return
+}
+
+// Prepare 校验定时指令并计算下次执行时间, 创建和更新前调用
+func (ss *ServerCmdScheduleService) Prepare(s *model.ServerCmdSchedule) error {
+	if s.Target != model.ServerCmdTargetIdServer && s.Target != model.ServerCmdTargetRelayServer {
+		return errors.New("invalid target")
+	}
+	if s.Server != "" {
+		t := AllService.ServerCmdService.CmdTarget(s.Server)
+		if t == nil {
+			return errors.New("cmd target not found")
+		}
+		if AllService.ServerCmdService.TargetOf(t) != s.Target {
+			return errors.New("cmd target type mismatch")
+		}
+	}
+	cmd, option, err := ss.command(s)
+	if err != nil {
+		return err
+	}
+	if cmd == "" {
+		return errors.New("cmd is required")
+	}
+	if strings.TrimSpace(s.Params) != "" && !json.Valid([]byte(s.Params)) {
+		return errors.New("params must be a json object")
+	}
+	if _, err := renderCmdOption(option, s.Params); err != nil {
+		return err
+	}
+	next, err := ss.next(s, time.Now())
+	if err != nil {
+		return err
+	}
+	s.NextRunAt = next
+	return nil
+}
+
+// command 使用的指令和参数模板
+func (ss *ServerCmdScheduleService) command(s *model.ServerCmdSchedule) (string, string, error) {
+	if s.CmdId == 0 {
+		return strings.TrimSpace(s.Cmd), s.Option, nil
+	}
+	c := AllService.ServerCmdService.Info(s.CmdId)
+	if c.Id == 0 {
+		return "", "", errors.New("server cmd not found")
+	}
+	return c.Cmd, c.Option, nil
+}
+
+// next t 之后的下次执行时间, 禁用时为0
+func (ss *ServerCmdScheduleService) next(s *model.ServerCmdSchedule, t time.Time) (int64, error) {
+	sch, err := cron.Parse(s.Cron)
+	if err != nil {
+		return 0, err
+	}
+	loc := time.Local
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return 0, err
+		}
+	}
+	if s.Status != model.COMMON_STATUS_ENABLE {
+		return 0, nil
+	}
+	n := sch.Next(t.In(loc))
+	if n.IsZero() {
+		return 0, cron.ErrNoNext
+	}
+	return n.Unix(), nil
+}
+
+func (ss *ServerCmdScheduleService) Create(s *model.ServerCmdSchedule) error {
+	return DB.Create(s).Error
+}
+
+// Update 更新, Select 才能把字段更新为空
+func (ss *ServerCmdScheduleService) Update(s *model.ServerCmdSchedule) error {
+	return DB.Model(s).Select("name", "cron", "timezone", "cmd_id", "cmd", "option", "params", "target", "server", "status", "next_run_at").Updates(s).Error
+}
+
+// Delete 删除, 同时删除执行历史
+func (ss *ServerCmdScheduleService) Delete(s *model.ServerCmdSchedule) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", s.Id).Delete(&model.ServerCmdRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(s).Error
+	})
+}
+
+// Run 执行并保存每个目标的结果
+func (ss *ServerCmdScheduleService) Run(ctx context.Context, s *model.ServerCmdSchedule, trigger string) []*model.ServerCmdRun {
+	start := time.Now()
+	var runs []*model.ServerCmdRun
+	cmd, option, err := ss.command(s)
+	if err == nil {
+		option, err = renderCmdOption(option, s.Params)
+	}
+	full := strings.TrimSpace(cmd + " " + option)
+	if err != nil {
+		runs = append(runs, &model.ServerCmdRun{Host: s.Server, Error: err.Error()})
+	} else if s.Server != "" {
+		out, err := AllService.ServerCmdService.SendCmdTo(ctx, s.Server, cmd, option)
+		run := &model.ServerCmdRun{Host: s.Server, Output: out}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		runs = append(runs, run)
+	} else if s.Target == model.ServerCmdTargetRelayServer {
+		for _, r := range AllService.RelayServerService.SendCmdToAll(ctx, cmd, option) {
+			runs = append(runs, &model.ServerCmdRun{Host: r.Name, Output: r.Output, Error: r.Error})
+		}
+	} else {
+		for _, r := range AllService.ServerCmdService.SendCmdToIdServers(ctx, cmd, option) {
+			runs = append(runs, &model.ServerCmdRun{Host: r.Target, Output: r.Output, Error: r.Error})
+		}
+	}
+	d := time.Since(start).Milliseconds()
+	for _, r := range runs {
+		r.ScheduleId = s.Id
+		r.Trigger = trigger
+		r.Cmd = full
+		r.Duration = d
+		r.Status = model.ServerCmdRunSuccess
+		if r.Error != "" {
+			r.Status = model.ServerCmdRunFailed
+		}
+	}
+	if err := DB.Create(&runs).Error; err != nil {
+		Logger.Error("save server cmd runs failed: ", err)
+	}
+	return runs
+}
+
+// RunDue 执行到期的定时指令; 通过条件更新 next_run_at 抢占, 多个实例时只有一个会执行
+// 停机期间错过的多次执行只补执行一次
+func (ss *ServerCmdScheduleService) RunDue(now time.Time) {
+	var list []*model.ServerCmdSchedule
+	DB.Where("status = ? and next_run_at > 0 and next_run_at <= ?", model.COMMON_STATUS_ENABLE, now.Unix()).Find(&list)
+	for _, s := range list {
+		next, err := ss.next(s, now)
+		if err != nil {
+			Logger.Warn("server cmd schedule ", s.Id, " stopped: ", err)
+		}
+		res := DB.Model(&model.ServerCmdSchedule{}).Where("id = ? and next_run_at = ?", s.Id, s.NextRunAt).
+			Updates(map[string]interface{}{"last_run_at": now.Unix(), "next_run_at": next})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		go ss.Run(context.Background(), s, model.ServerCmdRunTriggerSchedule)
+	}
+}
+
+// RunEvery 定时检查到期的指令
+func (ss *ServerCmdScheduleService) RunEvery(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for t := range ticker.C {
+		ss.RunDue(t)
+	}
+}
+
+func (ss *ServerCmdScheduleService) RunList(page, pageSize uint, where func(tx *gorm.DB)) (res *model.ServerCmdRunList) {
+	res = &model.ServerCmdRunList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.ServerCmdRun{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Order("id desc").Find(&res.ServerCmdRuns)
+	return
+}
+
+// renderCmdOption 用 params(json对象) 渲染参数模板, 模板中用到但 params 中没有的参数会报错
+func renderCmdOption(option, params string) (string, error) {
+	if !strings.Contains(option, "{{") {
+		return strings.TrimSpace(option), nil
+	}
+	data := map[string]interface{}{}
+	if strings.TrimSpace(params) != "" {
+		if err := json.Unmarshal([]byte(params), &data); err != nil {
+			return "", err
+		}
+	}
+	tpl, err := template.New("option").Option("missingkey=error").Parse(option)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestRenderCmdOption(t *testing.T) {
+	cases := []struct {
+		option, params, want string
+		err                  bool
+	}{
+		{"10", "", "10", false},
+		{" {{.speed}} ", `{"speed":"100"}`, "100", false},
+		{"{{.speed}}", `{"speed":10}`, "10", false},
+		{"{{.ip}}|{{.ip2}}", `{"ip":"1.2.3.4","ip2":"5.6.7.8"}`, "1.2.3.4|5.6.7.8", false},
+		{"{{.speed}}", `{}`, "", true},
+		{"{{.speed}}", `not json`, "", true},
+		{"{{.speed", `{"speed":"1"}`, "", true},
+	}
+	for _, c := range cases {
+		got, err := renderCmdOption(c.option, c.params)
+		if (err != nil) != c.err {
+			t.Errorf("renderCmdOption(%q, %q) error = %v", c.option, c.params, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("renderCmdOption(%q, %q) = %q, want %q", c.option, c.params, got, c.want)
+		}
+	}
+}
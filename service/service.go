@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/lejianwen/rustdesk-api/v2/config"
+	"github.com/lejianwen/rustdesk-api/v2/lib/cache"
 	"github.com/lejianwen/rustdesk-api/v2/lib/jwt"
 	"github.com/lejianwen/rustdesk-api/v2/lib/lock"
 	"github.com/lejianwen/rustdesk-api/v2/model"
@@ -24,6 +25,15 @@ type Service struct {
 	*ServerCmdService
 	*LdapService
 	*AppService
+	*AccessTokenService
+	*RoleService
+	*SecretService
+	*AddressBookInvitationService
+	*RelayServerService
+	*RelayUsageService
+	*IpRuleService
+	*ServerCmdScheduleService
+	*AdminAuditService
 }
 
 type Dependencies struct {
@@ -39,15 +49,17 @@ var DB *gorm.DB
 var Logger *log.Logger
 var Jwt *jwt.Jwt
 var Lock lock.Locker
+var Cache cache.Handler
 
 var AllService *Service
 
-func New(c *config.Config, g *gorm.DB, l *log.Logger, j *jwt.Jwt, lo lock.Locker) *Service {
+func New(c *config.Config, g *gorm.DB, l *log.Logger, j *jwt.Jwt, lo lock.Locker, ca cache.Handler) *Service {
 	Config = c
 	DB = g
 	Logger = l
 	Jwt = j
 	Lock = lo
+	Cache = ca
 	AllService = new(Service)
 	return AllService
 }
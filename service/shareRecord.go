@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"github.com/lejianwen/rustdesk-api/v2/model"
 	"gorm.io/gorm"
+	"time"
 )
 
 type ShareRecordService struct {
@@ -35,6 +37,7 @@ func (srs *ShareRecordService) Create(u *model.ShareRecord) error {
 	return res
 }
 func (srs *ShareRecordService) Delete(u *model.ShareRecord) error {
+	DB.Where("share_record_id = ?", u.Id).Delete(&model.ShareAccessLog{})
 	return DB.Delete(u).Error
 }
 
@@ -44,5 +47,101 @@ func (srs *ShareRecordService) Update(u *model.ShareRecord) error {
 }
 
 func (srs *ShareRecordService) BatchDelete(ids []uint) error {
+	DB.Where("share_record_id in (?)", ids).Delete(&model.ShareAccessLog{})
 	return DB.Where("id in (?)", ids).Delete(&model.ShareRecord{}).Error
 }
+
+// Revoke 吊销分享链接
+func (srs *ShareRecordService) Revoke(u *model.ShareRecord) error {
+	if u.RevokedAt != 0 {
+		return nil
+	}
+	u.RevokedAt = time.Now().Unix()
+	return DB.Model(u).Update("revoked_at", u.RevokedAt).Error
+}
+
+// Access 检查分享链接是否可以访问, 通过时使用次数加一, 返回的结果记录到访问日志
+func (srs *ShareRecordService) Access(sr *model.ShareRecord, ip string, u *model.User) string {
+	if sr.RevokedAt != 0 {
+		return model.ShareAccessRevoked
+	}
+	if sr.Expire != 0 {
+		//判断是否过期,created_at + expire > now
+		ca := time.Time(sr.CreatedAt)
+		if ca.Add(time.Second * time.Duration(sr.Expire)).Before(time.Now()) {
+			return model.ShareAccessExpired
+		}
+	}
+	if !sr.IpAllowed(ip) {
+		return model.ShareAccessIpDenied
+	}
+	if sr.IsRequireLogin() && (u == nil || u.Id == 0) {
+		return model.ShareAccessLoginRequired
+	}
+	// 并发时也不会超过最大次数
+	tx := DB.Model(&model.ShareRecord{}).Where("id = ?", sr.Id)
+	if sr.MaxUses > 0 {
+		tx.Where("used_count < ?", sr.MaxUses)
+	}
+	res := tx.UpdateColumn("used_count", gorm.Expr("used_count + ?", 1))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return model.ShareAccessLimitReached
+	}
+	sr.UsedCount++
+	return model.ShareAccessOk
+}
+
+// AddAccessLog 记录访问日志
+func (srs *ShareRecordService) AddAccessLog(sr *model.ShareRecord, u *model.User, ip, userAgent, result string) {
+	l := &model.ShareAccessLog{
+		ShareRecordId: sr.Id,
+		Ip:            ip,
+		UserAgent:     userAgent,
+		Result:        result,
+	}
+	if len(l.UserAgent) > 512 {
+		l.UserAgent = l.UserAgent[:512]
+	}
+	if u != nil {
+		l.UserId = u.Id
+		l.Username = u.Username
+	}
+	if err := DB.Create(l).Error; err != nil {
+		Logger.Warn("AddAccessLog error: ", err)
+	}
+}
+
+func (srs *ShareRecordService) AccessLogList(page, pageSize uint, where func(tx *gorm.DB)) (res *model.ShareAccessLogList) {
+	res = &model.ShareAccessLogList{}
+	res.Page = int64(page)
+	res.PageSize = int64(pageSize)
+	tx := DB.Model(&model.ShareAccessLog{})
+	if where != nil {
+		where(tx)
+	}
+	tx.Count(&res.Total)
+	tx.Scopes(Paginate(page, pageSize))
+	tx.Find(&res.ShareAccessLogs)
+	return
+}
+
+// shareRecordRecentLogs 列表中每条分享记录返回的访问记录数
+const shareRecordRecentLogs = 10
+
+// LoadRecentAccessLogs 加载每条记录最近的访问记录
+// 每条记录一次 limit 查询(走 share_record_id 索引), 次数受分页大小限制; mysql5.7不支持窗口函数
+func (srs *ShareRecordService) LoadRecentAccessLogs(records []*model.ShareRecord) {
+	for _, sr := range records {
+		sr.AccessLogs = nil
+		DB.Where("share_record_id = ?", sr.Id).Order("id desc").Limit(shareRecordRecentLogs).Find(&sr.AccessLogs)
+	}
+}
+
+// ValidateShareRecord 校验分享限制
+func (srs *ShareRecordService) ValidateShareRecord(sr *model.ShareRecord) error {
+	if sr.MaxUses < 0 {
+		return errors.New("max_uses must be >= 0")
+	}
+	_, err := sr.CidrList()
+	return err
+}
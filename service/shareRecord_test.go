@@ -0,0 +1,102 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/model"
+	"github.com/lejianwen/rustdesk-api/v2/model/custom_types"
+)
+
+func TestShareRecordIpAllowed(t *testing.T) {
+	cases := []struct {
+		cidrs string
+		ip    string
+		want  bool
+	}{
+		{"", "1.2.3.4", true},
+		{"10.0.0.0/8", "10.1.2.3", true},
+		{"10.0.0.0/8", "11.1.2.3", false},
+		{"10.0.0.0/8, 1.2.3.4", "1.2.3.4", true},
+		{"1.2.3.4", "1.2.3.5", false},
+		{"2001:db8::/32", "2001:db8::1", true},
+		{"2001:db8::1", "2001:db8::2", false},
+		{"10.0.0.0/8", "not-an-ip", false},
+		// 配置错误时拒绝所有访问
+		{"10.0.0.0/33", "10.1.2.3", false},
+	}
+	for _, c := range cases {
+		sr := &model.ShareRecord{AllowedCidrs: c.cidrs}
+		if got := sr.IpAllowed(c.ip); got != c.want {
+			t.Errorf("IpAllowed(%q, %q) = %v, want %v", c.cidrs, c.ip, got, c.want)
+		}
+	}
+}
+
+func TestShareRecordAccess(t *testing.T) {
+	db := newTestDB(t, &model.ShareRecord{}, &model.ShareAccessLog{})
+	srs := &ShareRecordService{}
+	yes := true
+	user := &model.User{IdModel: model.IdModel{Id: 1}}
+	expired := &model.ShareRecord{Expire: 60}
+	expired.CreatedAt = custom_types.AutoTime(time.Now().Add(-time.Hour))
+	cases := []struct {
+		name string
+		sr   *model.ShareRecord
+		ip   string
+		user *model.User
+		want []string // 连续访问的结果
+	}{
+		{"unlimited", &model.ShareRecord{}, "1.2.3.4", nil, []string{model.ShareAccessOk, model.ShareAccessOk}},
+		{"max uses", &model.ShareRecord{MaxUses: 2}, "1.2.3.4", nil, []string{model.ShareAccessOk, model.ShareAccessOk, model.ShareAccessLimitReached}},
+		{"revoked", &model.ShareRecord{RevokedAt: 1}, "1.2.3.4", nil, []string{model.ShareAccessRevoked}},
+		{"expired", expired, "1.2.3.4", nil, []string{model.ShareAccessExpired}},
+		{"ip denied", &model.ShareRecord{AllowedCidrs: "10.0.0.0/8"}, "1.2.3.4", nil, []string{model.ShareAccessIpDenied}},
+		{"ip allowed", &model.ShareRecord{AllowedCidrs: "10.0.0.0/8", MaxUses: 1}, "10.0.0.1", nil, []string{model.ShareAccessOk, model.ShareAccessLimitReached}},
+		{"login required", &model.ShareRecord{RequireLogin: &yes}, "1.2.3.4", nil, []string{model.ShareAccessLoginRequired}},
+		{"logged in", &model.ShareRecord{RequireLogin: &yes}, "1.2.3.4", user, []string{model.ShareAccessOk}},
+	}
+	for _, c := range cases {
+		if err := db.Create(c.sr).Error; err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range c.want {
+			if got := srs.Access(c.sr, c.ip, c.user); got != want {
+				t.Errorf("%s: access %d = %q, want %q", c.name, i+1, got, want)
+			}
+		}
+	}
+
+	// 吊销后不能再访问, 已使用次数不变
+	sr := &model.ShareRecord{MaxUses: 5}
+	db.Create(sr)
+	srs.Access(sr, "1.2.3.4", nil)
+	if err := srs.Revoke(sr); err != nil {
+		t.Fatal(err)
+	}
+	sr = srs.InfoById(sr.Id)
+	if got := srs.Access(sr, "1.2.3.4", nil); got != model.ShareAccessRevoked || sr.UsedCount != 1 {
+		t.Fatalf("after revoke: %q, used %d", got, sr.UsedCount)
+	}
+}
+
+func TestLoadRecentAccessLogs(t *testing.T) {
+	db := newTestDB(t, &model.ShareRecord{}, &model.ShareAccessLog{})
+	srs := &ShareRecordService{}
+	records := []*model.ShareRecord{{PeerId: "a"}, {PeerId: "b"}, {PeerId: "c"}}
+	db.Create(&records)
+	for i := 0; i < shareRecordRecentLogs+5; i++ {
+		srs.AddAccessLog(records[0], nil, strconv.Itoa(i), "", model.ShareAccessOk)
+	}
+	srs.AddAccessLog(records[1], nil, "b", "", model.ShareAccessOk)
+
+	srs.LoadRecentAccessLogs(records)
+	logs := records[0].AccessLogs
+	if len(logs) != shareRecordRecentLogs || logs[0].Ip != strconv.Itoa(shareRecordRecentLogs+4) || logs[len(logs)-1].Ip != "5" {
+		t.Fatalf("want the %d newest logs, got %d", shareRecordRecentLogs, len(logs))
+	}
+	if len(records[1].AccessLogs) != 1 || len(records[2].AccessLogs) != 0 {
+		t.Fatalf("unexpected logs: %d %d", len(records[1].AccessLogs), len(records[2].AccessLogs))
+	}
+}
@@ -32,35 +32,6 @@ func (s *TagService) ListByUserIdAndCollectionId(userId, cid uint) (res *model.T
 	})
 	return
 }
-func (s *TagService) UpdateTags(userId uint, tags map[string]uint) {
-	tx := DB.Begin()
-	//先查询所有tag
-	var allTags []*model.Tag
-	tx.Where("user_id = ?", userId).Find(&allTags)
-	for _, t := range allTags {
-		if _, ok := tags[t.Name]; !ok {
-			//删除
-			tx.Delete(t)
-		} else {
-			if tags[t.Name] != t.Color {
-				//更新
-				t.Color = tags[t.Name]
-				tx.Save(t)
-			}
-			//移除
-			delete(tags, t.Name)
-		}
-	}
-	//新增
-	for tag, color := range tags {
-		t := &model.Tag{}
-		t.Name = tag
-		t.Color = color
-		t.UserId = userId
-		tx.Create(t)
-	}
-	tx.Commit()
-}
 
 // InfoById 根据用户id取用户信息
 func (s *TagService) InfoById(id uint) *model.Tag {
@@ -84,15 +55,51 @@ func (s *TagService) List(page, pageSize uint, where func(tx *gorm.DB)) (res *mo
 }
 
 // Create 创建
-func (s *TagService) Create(u *model.Tag) error {
-	res := DB.Create(u).Error
-	return res
+func (s *TagService) Create(op *model.User, u *model.Tag) error {
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		abs := AllService.AddressBookService
+		if err := abs.logTagChange(tx, nil, u); err != nil {
+			return err
+		}
+		_, err := abs.BumpRevision(tx, u.UserId, u.CollectionId)
+		return err
+	})
 }
-func (s *TagService) Delete(u *model.Tag) error {
-	return DB.Delete(u).Error
+func (s *TagService) Delete(op *model.User, u *model.Tag) error {
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(u).Error; err != nil {
+			return err
+		}
+		abs := AllService.AddressBookService
+		if err := abs.logTagChange(tx, u, nil); err != nil {
+			return err
+		}
+		_, err := abs.BumpRevision(tx, u.UserId, u.CollectionId)
+		return err
+	})
 }
 
 // Update 更新
-func (s *TagService) Update(u *model.Tag) error {
-	return DB.Model(u).Select("*").Omit("created_at").Updates(u).Error
+func (s *TagService) Update(op *model.User, u *model.Tag) error {
+	return operatorDB(op).Transaction(func(tx *gorm.DB) error {
+		before := &model.Tag{}
+		tx.Where("id = ?", u.Id).First(before)
+		if err := tx.Model(u).Select("*").Omit("created_at").Updates(u).Error; err != nil {
+			return err
+		}
+		abs := AllService.AddressBookService
+		if err := abs.logTagChange(tx, before, u); err != nil {
+			return err
+		}
+		if before.Id > 0 && (before.UserId != u.UserId || before.CollectionId != u.CollectionId) {
+			if _, err := abs.BumpRevision(tx, before.UserId, before.CollectionId); err != nil {
+				return err
+			}
+		}
+		_, err := abs.BumpRevision(tx, u.UserId, u.CollectionId)
+		return err
+	})
 }
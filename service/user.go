@@ -59,18 +59,18 @@ func (us *UserService) InfoByUsernamePassword(username, password string) *model.
 	return u
 }
 
-// InfoByAccesstoken 根据accesstoken取用户信息
+// InfoByAccesstoken 根据accesstoken取用户信息, 每个认证请求都会调用, 结果会被缓存, 见 authCacheTtl
+// 缓存中的用户不含密码, 需要密码时使用 InfoById
 func (us *UserService) InfoByAccessToken(token string) (*model.User, *model.UserToken) {
 	u := &model.User{}
-	ut := &model.UserToken{}
-	DB.Where("token = ?", token).First(ut)
+	ut := us.cachedToken(token)
 	if ut.Id == 0 {
 		return u, ut
 	}
 	if ut.ExpiredAt < time.Now().Unix() {
 		return u, ut
 	}
-	DB.Where("id = ?", ut.UserId).First(u)
+	u = us.cachedUser(ut.UserId)
 	return u, ut
 }
 
@@ -186,6 +186,7 @@ func (us *UserService) GetUuidByToken(u *model.User, token string) string {
 // Logout 退出登录 -> 删除token, 解绑uuid
 func (us *UserService) Logout(u *model.User, token string) error {
 	uuid := us.GetUuidByToken(u, token)
+	us.forgetTokens("user_id = ? and token = ?", u.Id, token)
 	err := DB.Where("user_id = ? and token = ?", u.Id, token).Delete(&model.UserToken{}).Error
 	if err != nil {
 		return err
@@ -196,6 +197,56 @@ func (us *UserService) Logout(u *model.User, token string) error {
 	return nil
 }
 
+// BindOidcSession 记录token对应的IdP会话, 用于登出
+func (us *UserService) BindOidcSession(ut *model.UserToken, op, openId, sid, idToken string) error {
+	ut.Op = op
+	ut.OpenId = openId
+	ut.Sid = sid
+	ut.IdToken = idToken
+	// id_token 是加密字段, 不能用map更新
+	return DB.Model(ut).Select("op", "open_id", "sid", "id_token").Updates(ut).Error
+}
+
+// OidcLogoutUrl 获取token对应的IdP登出地址, 不是oidc登录的token返回空
+func (us *UserService) OidcLogoutUrl(token string) string {
+	ut := &model.UserToken{}
+	DB.Where("token = ?", token).First(ut)
+	if ut.Id == 0 || ut.IdToken == "" {
+		return ""
+	}
+	return AllService.OauthService.EndSessionUrl(ut.Op, ut.IdToken)
+}
+
+// LogoutByOidcSession 吊销IdP会话对应的所有token, sid为空时吊销该sub的所有token
+// sub和sid都有时两者都要匹配, 避免不同用户的sid相同时吊销别人的会话
+func (us *UserService) LogoutByOidcSession(op, openId, sid string) (int64, error) {
+	var uts []*model.UserToken
+	tx := DB.Where("op = ?", op)
+	if sid != "" {
+		tx.Where("sid = ?", sid)
+	}
+	if openId != "" {
+		tx.Where("open_id = ?", openId)
+	}
+	tx.Find(&uts)
+	if len(uts) == 0 {
+		return 0, nil
+	}
+	ids := make([]uint, 0, len(uts))
+	for _, ut := range uts {
+		ids = append(ids, ut.Id)
+	}
+	if err := us.BatchDeleteUserToken(ids); err != nil {
+		return 0, err
+	}
+	for _, ut := range uts {
+		if ut.DeviceUuid != "" {
+			AllService.PeerService.UuidUnbindUserId(ut.DeviceUuid, ut.UserId)
+		}
+	}
+	return int64(len(uts)), nil
+}
+
 // Delete 删除用户和oauth信息
 func (us *UserService) Delete(u *model.User) error {
 	userCount := us.getAdminUserCount()
@@ -218,6 +269,15 @@ func (us *UserService) Delete(u *model.User) error {
 		tx.Rollback()
 		return err
 	}
+	//  删除地址簿的版本和快照
+	if err := tx.Where("user_id = ?", u.Id).Delete(&model.AddressBookRevision{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("user_id = ?", u.Id).Delete(&model.AddressBookSnapshot{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
 	//  删除关联的abc
 	if err := tx.Where("user_id = ?", u.Id).Delete(&model.AddressBookCollection{}).Error; err != nil {
 		tx.Rollback()
@@ -228,7 +288,24 @@ func (us *UserService) Delete(u *model.User) error {
 		tx.Rollback()
 		return err
 	}
+	//  删除发出的地址簿邀请
+	if err := tx.Where("user_id = ?", u.Id).Delete(&model.AddressBookInvitation{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	//  删除个人访问令牌
+	if err := tx.Where("user_id = ?", u.Id).Delete(&model.AccessToken{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	//  删除角色绑定
+	if err := AllService.RoleService.DeleteBindingsByUserId(tx, u.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
 	tx.Commit()
+	us.forgetUser(u.Id)
+	AllService.AddressBookService.forgetPermissions()
 	// 删除关联的peer
 	if err := AllService.PeerService.EraseUserId(u.Id); err != nil {
 		Logger.Warn("User deleted successfully, but failed to unlink peer.")
@@ -248,21 +325,33 @@ func (us *UserService) Update(u *model.User) error {
 			return errors.New("The last admin user cannot be disabled or demoted")
 		}
 	}
-	return DB.Model(u).Updates(u).Error
+	if err := DB.Model(u).Updates(u).Error; err != nil {
+		return err
+	}
+	us.forgetUser(u.Id)
+	if u.GroupId > 0 && u.GroupId != currentUser.GroupId {
+		if err := AllService.AddressBookService.SyncMyDevices(u.Id); err != nil {
+			Logger.Warn("sync my devices failed, user: ", u.Id, ", err: ", err)
+		}
+	}
+	return nil
 }
 
 // FlushToken 清空token
 func (us *UserService) FlushToken(u *model.User) error {
+	us.forgetTokens("user_id = ?", u.Id)
 	return DB.Where("user_id = ?", u.Id).Delete(&model.UserToken{}).Error
 }
 
 // FlushTokenByUuid 清空token
 func (us *UserService) FlushTokenByUuid(uuid string) error {
+	us.forgetTokens("device_uuid = ?", uuid)
 	return DB.Where("device_uuid = ?", uuid).Delete(&model.UserToken{}).Error
 }
 
 // FlushTokenByUuids 清空token
 func (us *UserService) FlushTokenByUuids(uuids []string) error {
+	us.forgetTokens("device_uuid in (?)", uuids)
 	return DB.Where("device_uuid in (?)", uuids).Delete(&model.UserToken{}).Error
 }
 
@@ -273,21 +362,32 @@ func (us *UserService) UpdatePassword(u *model.User, password string) error {
 	if err != nil {
 		return err
 	}
+	us.forgetUser(u.Id)
 	err = us.FlushToken(u)
 	return err
 }
 
 // IsAdmin 是否管理员
 func (us *UserService) IsAdmin(u *model.User) bool {
-	return u != nil && *u.IsAdmin
+	return u != nil && u.IsAdmin != nil && *u.IsAdmin
 }
 
-// RouteNames
+// RouteNames 前端路由, 非管理员根据角色权限追加
 func (us *UserService) RouteNames(u *model.User) []string {
 	if us.IsAdmin(u) {
 		return model.AdminRouteNames
 	}
-	return model.UserRouteNames
+	perms := AllService.RoleService.UserPermissions(u)
+	if len(perms) == 0 {
+		return model.UserRouteNames
+	}
+	res := append([]string{}, model.UserRouteNames...)
+	for _, resource := range model.PermissionResources {
+		if model.HasPermission(perms, resource, model.ActionRead) {
+			res = append(res, model.PermissionRouteNames[resource]...)
+		}
+	}
+	return res
 }
 
 // InfoByOauthId 根据oauth的name和openId取用户信息
@@ -309,7 +409,9 @@ func (us *UserService) RegisterByOauth(oauthUser *model.OauthUser, op string) (e
 	defer Lock.UnLock("registerByOauth")
 	ut := AllService.OauthService.UserThirdInfo(op, oauthUser.OpenId)
 	if ut.Id != 0 {
-		return nil, us.InfoById(ut.UserId)
+		user := us.InfoById(ut.UserId)
+		us.SyncOauthClaims(user, oauthUser, op)
+		return nil, user
 	}
 	err, oauthType := AllService.OauthService.GetTypeByOp(op)
 	if err != nil {
@@ -335,6 +437,7 @@ func (us *UserService) RegisterByOauth(oauthUser *model.OauthUser, op string) (e
 		if user.Id != 0 {
 			ut.FromOauthUser(user.Id, oauthUser, oauthType, op)
 			DB.Create(ut)
+			us.SyncOauthClaims(user, oauthUser, op)
 			return nil, user
 		}
 	}
@@ -358,9 +461,52 @@ func (us *UserService) RegisterByOauth(oauthUser *model.OauthUser, op string) (e
 	ut.UserId = user.Id
 	tx.Create(ut)
 	tx.Commit()
+	us.SyncOauthClaims(user, oauthUser, op)
 	return nil, user
 }
 
+// SyncOauthClaims 根据IdP的claim更新用户的管理员权限和分组, 每次oauth登录时调用
+// 仅在oauth配置了RoleClaim且IdP返回了这个claim时生效, 配置了AdminRoles时是否管理员由IdP决定
+func (us *UserService) SyncOauthClaims(u *model.User, oauthUser *model.OauthUser, op string) {
+	if u == nil || u.Id == 0 || oauthUser == nil {
+		return
+	}
+	oauthInfo := AllService.OauthService.InfoByOp(op)
+	if oauthInfo.Id == 0 || !oauthInfo.ClaimMappingEnabled() {
+		return
+	}
+	isAdmin, groupId, found := AllService.OauthService.MapClaims(oauthInfo, oauthUser.Claims)
+	if !found {
+		// IdP没有返回claim时不修改, 避免取消本地设置的管理员
+		return
+	}
+	if len(oauthInfo.AdminRoleList()) == 0 {
+		// 没有配置管理员角色时只映射分组
+		isAdmin = us.IsAdmin(u)
+	}
+	if groupId > 0 && AllService.GroupService.InfoById(groupId).Id == 0 {
+		Logger.Warnf("oauth %s maps to group %d which does not exist", op, groupId)
+		groupId = 0
+	}
+	if groupId == 0 {
+		groupId = u.GroupId
+	}
+	if us.IsAdmin(u) == isAdmin && u.GroupId == groupId {
+		return
+	}
+	up := &model.User{
+		IsAdmin: &isAdmin,
+		GroupId: groupId,
+	}
+	up.Id = u.Id
+	if err := us.Update(up); err != nil {
+		Logger.Warnf("sync oauth claims of user %d failed: %v", u.Id, err)
+		return
+	}
+	u.IsAdmin = &isAdmin
+	u.GroupId = groupId
+}
+
 // GenerateUsernameByOauth 生成用户名
 func (us *UserService) GenerateUsernameByOauth(name string) string {
 	for us.IsUsernameExists(name) {
@@ -448,6 +594,7 @@ func (us *UserService) TokenInfoById(id uint) *model.UserToken {
 }
 
 func (us *UserService) DeleteToken(l *model.UserToken) error {
+	us.forgetTokens("id = ?", l.Id)
 	return DB.Delete(l).Error
 }
 
@@ -485,6 +632,7 @@ func (us *UserService) UserTokenExpireTimestamp() int64 {
 func (us *UserService) RefreshAccessToken(ut *model.UserToken) {
 	ut.ExpiredAt = us.UserTokenExpireTimestamp()
 	DB.Model(ut).Update("expired_at", ut.ExpiredAt)
+	forgetAuthCache(authCacheTokenPrefix + ut.Token)
 }
 func (us *UserService) AutoRefreshAccessToken(ut *model.UserToken) {
 	if ut.ExpiredAt-time.Now().Unix() < 86400 {
@@ -493,6 +641,7 @@ func (us *UserService) AutoRefreshAccessToken(ut *model.UserToken) {
 }
 
 func (us *UserService) BatchDeleteUserToken(ids []uint) error {
+	us.forgetTokens("id in ?", ids)
 	return DB.Where("id in ?", ids).Delete(&model.UserToken{}).Error
 }
 
@@ -40,9 +40,7 @@ type BanRecord struct {
 type LoginLimiter struct {
 	mu          sync.Mutex
 	policy      SecurityPolicy
-	attempts    map[string][]time.Time //
-	captchas    map[string]CaptchaMeta
-	bannedIPs   map[string]BanRecord
+	store       LimiterStore
 	provider    CaptchaProvider
 	cleanupStop chan struct{}
 }
@@ -55,6 +53,11 @@ var defaultSecurityPolicy = SecurityPolicy{
 }
 
 func NewLoginLimiter(policy SecurityPolicy) *LoginLimiter {
+	return NewLoginLimiterWithStore(policy, NewMemoryLimiterStore())
+}
+
+// NewLoginLimiterWithStore 使用指定的存储, 多实例部署时使用共享的存储(如 RedisLimiterStore)
+func NewLoginLimiterWithStore(policy SecurityPolicy, store LimiterStore) *LoginLimiter {
 	// 设置默认值
 	if policy.AttemptsWindow == 0 {
 		policy.AttemptsWindow = 5 * time.Minute
@@ -65,9 +68,7 @@ func NewLoginLimiter(policy SecurityPolicy) *LoginLimiter {
 
 	ll := &LoginLimiter{
 		policy:      policy,
-		attempts:    make(map[string][]time.Time),
-		captchas:    make(map[string]CaptchaMeta),
-		bannedIPs:   make(map[string]BanRecord),
+		store:       store,
 		cleanupStop: make(chan struct{}),
 	}
 	go ll.cleanupRoutine()
@@ -91,82 +92,77 @@ func (ll *LoginLimiter) RecordFailedAttempt(ip string) {
 	if ll.isDisabled() {
 		return
 	}
-	ll.mu.Lock()
-	defer ll.mu.Unlock()
-
-	if banned, _ := ll.isBanned(ip); banned {
+	now := time.Now()
+	if _, banned := ll.store.Banned(ip, now); banned {
 		return
 	}
 
-	now := time.Now()
-	windowStart := now.Add(-ll.policy.AttemptsWindow)
-
-	// 清理过期尝试
-	validAttempts := ll.pruneAttempts(ip, windowStart)
-
-	// 记录新尝试
-	validAttempts = append(validAttempts, now)
-	ll.attempts[ip] = validAttempts
+	// 记录新尝试, 返回窗口内的尝试次数
+	count := ll.store.AddAttempt(ip, now, ll.policy.AttemptsWindow)
 
 	// 检查封禁条件
-	if ll.policy.BanThreshold > 0 && len(validAttempts) >= ll.policy.BanThreshold {
-		ll.banIP(ip, "excessive failed attempts")
-		return
+	if ll.policy.BanThreshold > 0 && count >= ll.policy.BanThreshold {
+		ll.store.Ban(ip, BanRecord{
+			ExpiresAt: now.Add(ll.policy.BanDuration),
+			Reason:    "excessive failed attempts",
+		})
+		ll.store.RemoveAttempts(ip)
 	}
-
-	return
 }
 
 // 生成验证码
 func (ll *LoginLimiter) RequireCaptcha() (error, CaptchaMeta) {
 	ll.mu.Lock()
-	defer ll.mu.Unlock()
+	provider := ll.provider
+	ll.mu.Unlock()
 
-	if ll.provider == nil {
+	if provider == nil {
 		return errors.New("no captcha provider available"), CaptchaMeta{}
 	}
 
-	id, content, answer, err := ll.provider.Generate()
+	id, content, answer, err := provider.Generate()
 	if err != nil {
 		return err, CaptchaMeta{}
 	}
 
 	// 存储验证码
-	ll.captchas[id] = CaptchaMeta{
+	captcha := CaptchaMeta{
 		Id:        id,
 		Content:   content,
 		Answer:    answer,
-		ExpiresAt: time.Now().Add(ll.provider.Expiration()),
+		ExpiresAt: time.Now().Add(provider.Expiration()),
 	}
+	ll.store.SetCaptcha(captcha)
 
-	return nil, ll.captchas[id]
+	return nil, captcha
 }
 
 // 验证验证码
 func (ll *LoginLimiter) VerifyCaptcha(id, answer string) bool {
 	ll.mu.Lock()
-	defer ll.mu.Unlock()
+	provider := ll.provider
+	ll.mu.Unlock()
 
 	// 查找匹配验证码
-	if ll.provider == nil {
+	if provider == nil {
 		return false
 	}
 
 	// 获取并验证验证码
-	captcha, exists := ll.captchas[id]
+	captcha, exists := ll.store.Captcha(id)
 	if !exists {
 		return false
 	}
 
 	// 清理过期验证码
 	if time.Now().After(captcha.ExpiresAt) {
-		delete(ll.captchas, id)
+		ll.store.DeleteCaptcha(id)
 		return false
 	}
 
 	// 验证并清理状态
 	if answer == captcha.Answer {
-		delete(ll.captchas, id)
+		ll.store.DeleteCaptcha(id)
 		return true
 	}
 
@@ -180,13 +176,13 @@ func (ll *LoginLimiter) DrawCaptcha(content string) (err error, str string) {
 
 // 清除记录窗口
 func (ll *LoginLimiter) RemoveAttempts(ip string) {
-	ll.mu.Lock()
-	defer ll.mu.Unlock()
+	ll.store.RemoveAttempts(ip)
+}
 
-	_, exists := ll.attempts[ip]
-	if exists {
-		delete(ll.attempts, ip)
-	}
+// Unban 解除封禁并清除失败记录
+func (ll *LoginLimiter) Unban(ip string) {
+	ll.store.Unban(ip)
+	ll.store.RemoveAttempts(ip)
 }
 
 // CheckSecurityStatus 检查安全状态
@@ -194,19 +190,15 @@ func (ll *LoginLimiter) CheckSecurityStatus(ip string) (banned bool, captchaRequ
 	if ll.isDisabled() {
 		return
 	}
-	ll.mu.Lock()
-	defer ll.mu.Unlock()
+	now := time.Now()
 
 	// 检查封禁状态
-	if banned, _ = ll.isBanned(ip); banned {
+	if _, banned = ll.store.Banned(ip, now); banned {
 		return
 	}
 
-	// 清理过期数据
-	ll.pruneAttempts(ip, time.Now().Add(-ll.policy.AttemptsWindow))
-
 	// 检查验证码要求
-	captchaRequired = len(ll.attempts[ip]) >= ll.policy.CaptchaThreshold
+	captchaRequired = ll.store.Attempts(ip, now, ll.policy.AttemptsWindow) >= ll.policy.CaptchaThreshold
 
 	return
 }
@@ -219,78 +211,9 @@ func (ll *LoginLimiter) cleanupRoutine() {
 	for {
 		select {
 		case <-ticker.C:
-			ll.cleanupExpired()
+			ll.store.Cleanup(time.Now(), ll.policy.AttemptsWindow)
 		case <-ll.cleanupStop:
 			return
 		}
 	}
 }
-
-// 内部工具方法
-func (ll *LoginLimiter) isBanned(ip string) (bool, BanRecord) {
-	record, exists := ll.bannedIPs[ip]
-	if !exists {
-		return false, BanRecord{}
-	}
-	if time.Now().After(record.ExpiresAt) {
-		delete(ll.bannedIPs, ip)
-		return false, BanRecord{}
-	}
-	return true, record
-}
-
-func (ll *LoginLimiter) banIP(ip, reason string) {
-	ll.bannedIPs[ip] = BanRecord{
-		ExpiresAt: time.Now().Add(ll.policy.BanDuration),
-		Reason:    reason,
-	}
-	delete(ll.attempts, ip)
-	delete(ll.captchas, ip)
-}
-
-func (ll *LoginLimiter) pruneAttempts(ip string, cutoff time.Time) []time.Time {
-	var valid []time.Time
-	for _, t := range ll.attempts[ip] {
-		if t.After(cutoff) {
-			valid = append(valid, t)
-		}
-	}
-	if len(valid) == 0 {
-		delete(ll.attempts, ip)
-	} else {
-		ll.attempts[ip] = valid
-	}
-	return valid
-}
-
-func (ll *LoginLimiter) pruneCaptchas(id string) {
-	if captcha, exists := ll.captchas[id]; exists {
-		if time.Now().After(captcha.ExpiresAt) {
-			delete(ll.captchas, id)
-		}
-	}
-}
-
-func (ll *LoginLimiter) cleanupExpired() {
-	ll.mu.Lock()
-	defer ll.mu.Unlock()
-
-	now := time.Now()
-
-	// 清理封禁记录
-	for ip, record := range ll.bannedIPs {
-		if now.After(record.ExpiresAt) {
-			delete(ll.bannedIPs, ip)
-		}
-	}
-
-	// 清理尝试记录
-	for ip := range ll.attempts {
-		ll.pruneAttempts(ip, now.Add(-ll.policy.AttemptsWindow))
-	}
-
-	// 清理验证码
-	for id := range ll.captchas {
-		ll.pruneCaptchas(id)
-	}
-}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RedisLimiterStore 保存在redis中, 多个实例共享失败记录、封禁和验证码
+// 数据都设置了过期时间, 不需要清理; redis出错时视为没有记录, 不影响正常登录
+type RedisLimiterStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+	seq    uint64
+	// OnError redis出错时的回调, 如记录日志
+	OnError func(err error)
+}
+
+func NewRedisLimiterStore(rdb redis.UniversalClient, prefix string) *RedisLimiterStore {
+	return &RedisLimiterStore{rdb: rdb, prefix: prefix}
+}
+
+func (s *RedisLimiterStore) attemptsKey(ip string) string {
+	return s.prefix + "attempts:" + ip
+}
+
+func (s *RedisLimiterStore) banKey(ip string) string {
+	return s.prefix + "ban:" + ip
+}
+
+func (s *RedisLimiterStore) captchaKey(id string) string {
+	return s.prefix + "captcha:" + id
+}
+
+// AddAttempt 失败记录保存在有序集合中, score 为时间
+func (s *RedisLimiterStore) AddAttempt(ip string, now time.Time, window time.Duration) int {
+	key := s.attemptsKey(ip)
+	ctx := context.Background()
+	// member 需要唯一, 同一时刻的多次失败都要记录
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(atomic.AddUint64(&s.seq, 1), 10)
+	var card *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+		p.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: member})
+		card = p.ZCard(ctx, key)
+		p.PExpire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		s.error(err)
+		return 0
+	}
+	return int(card.Val())
+}
+
+func (s *RedisLimiterStore) Attempts(ip string, now time.Time, window time.Duration) int {
+	n, err := s.rdb.ZCount(context.Background(), s.attemptsKey(ip), "("+strconv.FormatInt(now.Add(-window).UnixNano(), 10), "+inf").Result()
+	if err != nil {
+		s.error(err)
+		return 0
+	}
+	return int(n)
+}
+
+func (s *RedisLimiterStore) RemoveAttempts(ip string) {
+	s.del(s.attemptsKey(ip))
+}
+
+func (s *RedisLimiterStore) Ban(ip string, record BanRecord) {
+	s.set(s.banKey(ip), record, record.ExpiresAt)
+}
+
+func (s *RedisLimiterStore) Banned(ip string, now time.Time) (BanRecord, bool) {
+	record := BanRecord{}
+	if !s.get(s.banKey(ip), &record) || now.After(record.ExpiresAt) {
+		return BanRecord{}, false
+	}
+	return record, true
+}
+
+func (s *RedisLimiterStore) Unban(ip string) {
+	s.del(s.banKey(ip))
+}
+
+func (s *RedisLimiterStore) SetCaptcha(captcha CaptchaMeta) {
+	s.set(s.captchaKey(captcha.Id), captcha, captcha.ExpiresAt)
+}
+
+func (s *RedisLimiterStore) Captcha(id string) (CaptchaMeta, bool) {
+	captcha := CaptchaMeta{}
+	if !s.get(s.captchaKey(id), &captcha) {
+		return CaptchaMeta{}, false
+	}
+	return captcha, true
+}
+
+func (s *RedisLimiterStore) DeleteCaptcha(id string) {
+	s.del(s.captchaKey(id))
+}
+
+func (s *RedisLimiterStore) Cleanup(now time.Time, window time.Duration) {
+}
+
+// set 保存为json, 到 expiresAt 时自动删除
+func (s *RedisLimiterStore) set(key string, value interface{}, expiresAt time.Time) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		s.error(err)
+		return
+	}
+	if err = s.rdb.Set(context.Background(), key, b, ttl).Err(); err != nil {
+		s.error(err)
+	}
+}
+
+func (s *RedisLimiterStore) get(key string, value interface{}) bool {
+	b, err := s.rdb.Get(context.Background(), key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			s.error(err)
+		}
+		return false
+	}
+	if err = json.Unmarshal(b, value); err != nil {
+		s.error(err)
+		return false
+	}
+	return true
+}
+
+func (s *RedisLimiterStore) del(key string) {
+	if err := s.rdb.Del(context.Background(), key).Err(); err != nil {
+		s.error(err)
+	}
+}
+
+func (s *RedisLimiterStore) error(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+	}
+}
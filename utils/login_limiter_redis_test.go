@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lejianwen/rustdesk-api/v2/lib/redistest"
+)
+
+func TestRedisLimiterStoreShared(t *testing.T) {
+	srv := redistest.NewServer(t)
+	var errs []error
+	onError := func(err error) { errs = append(errs, err) }
+	a := NewRedisLimiterStore(srv.Client(t), "limiter:")
+	b := NewRedisLimiterStore(srv.Client(t), "limiter:")
+	a.OnError, b.OnError = onError, onError
+	ip := "10.0.0.1"
+	now := time.Now()
+	window := 5 * time.Minute
+
+	// 一个实例的封禁在另一个实例上生效
+	a.Ban(ip, BanRecord{ExpiresAt: now.Add(time.Hour), Reason: "too many attempts"})
+	if r, ok := b.Banned(ip, now); !ok || r.Reason != "too many attempts" {
+		t.Fatalf("ban not visible from another instance: %+v %v", r, ok)
+	}
+	if _, ok := b.Banned(ip, now.Add(2*time.Hour)); ok {
+		t.Fatal("expired ban still reported")
+	}
+	b.Unban(ip)
+	if _, ok := a.Banned(ip, now); ok {
+		t.Fatal("unban not visible from another instance")
+	}
+
+	// 失败次数合并计算, 超出窗口的不计算
+	a.AddAttempt(ip, now.Add(-10*time.Minute), window)
+	a.AddAttempt(ip, now, window)
+	if n := b.AddAttempt(ip, now, window); n != 2 {
+		t.Fatalf("AddAttempt = %d, want 2", n)
+	}
+	if n := a.Attempts(ip, now, window); n != 2 {
+		t.Fatalf("Attempts = %d, want 2", n)
+	}
+	if ttl := srv.TTL("limiter:attempts:" + ip); ttl <= 0 || ttl > window {
+		t.Fatalf("attempts key ttl = %v", ttl)
+	}
+	b.RemoveAttempts(ip)
+	if n := a.Attempts(ip, now, window); n != 0 {
+		t.Fatalf("Attempts after remove = %d", n)
+	}
+
+	a.SetCaptcha(CaptchaMeta{Id: "c1", Answer: "42", ExpiresAt: now.Add(time.Minute)})
+	if c, ok := b.Captcha("c1"); !ok || c.Answer != "42" {
+		t.Fatalf("captcha not visible from another instance: %+v %v", c, ok)
+	}
+	b.DeleteCaptcha("c1")
+	if _, ok := a.Captcha("c1"); ok {
+		t.Fatal("captcha not deleted")
+	}
+	if len(errs) > 0 {
+		t.Fatalf("unexpected redis errors: %v", errs)
+	}
+}
+
+func TestRedisLimiterStoreExpires(t *testing.T) {
+	srv := redistest.NewServer(t)
+	s := NewRedisLimiterStore(srv.Client(t), "limiter:")
+	ip := "10.0.0.2"
+	s.Ban(ip, BanRecord{ExpiresAt: time.Now().Add(time.Minute)})
+	srv.FastForward(time.Minute)
+	if _, ok := srv.Get("limiter:ban:" + ip); ok {
+		t.Fatal("ban key not expired")
+	}
+	if _, ok := s.Banned(ip, time.Now()); ok {
+		t.Fatal("expired ban still reported")
+	}
+}
+
+func TestLoginLimiterWithRedisStore(t *testing.T) {
+	srv := redistest.NewServer(t)
+	policy := SecurityPolicy{
+		CaptchaThreshold: 2,
+		BanThreshold:     3,
+		AttemptsWindow:   5 * time.Minute,
+		BanDuration:      5 * time.Minute,
+	}
+	a := NewLoginLimiterWithStore(policy, NewRedisLimiterStore(srv.Client(t), "limiter:"))
+	b := NewLoginLimiterWithStore(policy, NewRedisLimiterStore(srv.Client(t), "limiter:"))
+	ip := "10.0.0.3"
+	a.RecordFailedAttempt(ip)
+	b.RecordFailedAttempt(ip)
+	if _, captcha := a.CheckSecurityStatus(ip); !captcha {
+		t.Fatal("captcha not required after attempts on two instances")
+	}
+	a.RecordFailedAttempt(ip)
+	if banned, _ := b.CheckSecurityStatus(ip); !banned {
+		t.Fatal("ban on one instance not visible from another")
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// LimiterStore 登录限制的状态(失败记录、封禁、验证码)存储
+// 默认保存在进程内存中, 多实例部署时使用共享的存储, 否则换一个实例就能绕过封禁
+type LimiterStore interface {
+	// AddAttempt 记录一次失败, 返回 window 内的失败次数
+	AddAttempt(ip string, now time.Time, window time.Duration) int
+	// Attempts window 内的失败次数
+	Attempts(ip string, now time.Time, window time.Duration) int
+	RemoveAttempts(ip string)
+	Ban(ip string, record BanRecord)
+	// Banned 未过期的封禁记录
+	Banned(ip string, now time.Time) (BanRecord, bool)
+	Unban(ip string)
+	SetCaptcha(captcha CaptchaMeta)
+	Captcha(id string) (CaptchaMeta, bool)
+	DeleteCaptcha(id string)
+	// Cleanup 清理过期数据, 数据会自动过期的存储可以不处理
+	Cleanup(now time.Time, window time.Duration)
+}
+
+// MemoryLimiterStore 进程内存中的存储
+type MemoryLimiterStore struct {
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	captchas  map[string]CaptchaMeta
+	bannedIPs map[string]BanRecord
+}
+
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{
+		attempts:  make(map[string][]time.Time),
+		captchas:  make(map[string]CaptchaMeta),
+		bannedIPs: make(map[string]BanRecord),
+	}
+}
+
+func (s *MemoryLimiterStore) AddAttempt(ip string, now time.Time, window time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	valid := append(s.pruneAttempts(ip, now.Add(-window)), now)
+	s.attempts[ip] = valid
+	return len(valid)
+}
+
+func (s *MemoryLimiterStore) Attempts(ip string, now time.Time, window time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pruneAttempts(ip, now.Add(-window)))
+}
+
+func (s *MemoryLimiterStore) RemoveAttempts(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, ip)
+}
+
+func (s *MemoryLimiterStore) Ban(ip string, record BanRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bannedIPs[ip] = record
+}
+
+func (s *MemoryLimiterStore) Banned(ip string, now time.Time) (BanRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.bannedIPs[ip]
+	if !exists {
+		return BanRecord{}, false
+	}
+	if now.After(record.ExpiresAt) {
+		delete(s.bannedIPs, ip)
+		return BanRecord{}, false
+	}
+	return record, true
+}
+
+func (s *MemoryLimiterStore) Unban(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bannedIPs, ip)
+}
+
+func (s *MemoryLimiterStore) SetCaptcha(captcha CaptchaMeta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.captchas[captcha.Id] = captcha
+}
+
+func (s *MemoryLimiterStore) Captcha(id string) (CaptchaMeta, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	captcha, exists := s.captchas[id]
+	return captcha, exists
+}
+
+func (s *MemoryLimiterStore) DeleteCaptcha(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.captchas, id)
+}
+
+func (s *MemoryLimiterStore) Cleanup(now time.Time, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 清理封禁记录
+	for ip, record := range s.bannedIPs {
+		if now.After(record.ExpiresAt) {
+			delete(s.bannedIPs, ip)
+		}
+	}
+
+	// 清理尝试记录
+	for ip := range s.attempts {
+		s.pruneAttempts(ip, now.Add(-window))
+	}
+
+	// 清理验证码
+	for id, captcha := range s.captchas {
+		if now.After(captcha.ExpiresAt) {
+			delete(s.captchas, id)
+		}
+	}
+}
+
+func (s *MemoryLimiterStore) pruneAttempts(ip string, cutoff time.Time) []time.Time {
+	var valid []time.Time
+	for _, t := range s.attempts[ip] {
+		if t.After(cutoff) {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) == 0 {
+		delete(s.attempts, ip)
+	} else {
+		s.attempts[ip] = valid
+	}
+	return valid
+}
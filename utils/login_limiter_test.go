@@ -201,6 +201,20 @@ func TestBanFlow(t *testing.T) {
 		t.Error("should be banned")
 	}
 }
+func TestUnban(t *testing.T) {
+	policy := SecurityPolicy{CaptchaThreshold: 3, BanThreshold: 5}
+	limiter := NewLoginLimiter(policy)
+	ip := "10.0.0.2"
+	for i := 0; i < 5; i++ {
+		limiter.RecordFailedAttempt(ip)
+	}
+	limiter.Unban(ip)
+
+	// 解封后失败记录也被清除
+	if banned, need := limiter.CheckSecurityStatus(ip); banned || need {
+		t.Error("should not be banned or need captcha after unban")
+	}
+}
 func TestBanDisableFlow(t *testing.T) {
 	policy := SecurityPolicy{BanThreshold: 0}
 	limiter := NewLoginLimiter(policy)
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"reflect"
 	"runtime/debug"
+	"strconv"
 	"strings"
 )
 
@@ -109,3 +110,66 @@ func StringConcat(strs ...string) string {
 	}
 	return builder.String()
 }
+
+// JsonPathGet 按路径取json解码后的值, 支持 `$.data.user.id`, `data.items[0].id` 格式, `$`前缀可省略
+func JsonPathGet(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil, false
+	}
+	cur := data
+	for _, seg := range strings.Split(path, ".") {
+		key := seg
+		var idxs []int
+		if i := strings.Index(seg, "["); i >= 0 {
+			key = seg[:i]
+			for _, part := range strings.Split(seg[i:], "[")[1:] {
+				if !strings.HasSuffix(part, "]") {
+					return nil, false
+				}
+				n, err := strconv.Atoi(strings.TrimSuffix(part, "]"))
+				if err != nil || n < 0 {
+					return nil, false
+				}
+				idxs = append(idxs, n)
+			}
+		}
+		if key != "" {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, false
+			}
+		}
+		for _, n := range idxs {
+			arr, ok := cur.([]interface{})
+			if !ok || n >= len(arr) {
+				return nil, false
+			}
+			cur = arr[n]
+		}
+	}
+	return cur, true
+}
+
+// JsonPathString 按路径取值并转为字符串, 数字不会变成科学计数法, 对象和数组返回空
+func JsonPathString(data interface{}, path string) string {
+	v, ok := JsonPathGet(data, path)
+	if !ok {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return ""
+}
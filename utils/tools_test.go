@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJsonPath(t *testing.T) {
+	raw := `{"id":12345678901234,"name":"a","data":{"user":{"email":"a@b.c","verified":true}},"items":[{"id":"x"},{"id":"y"}],"matrix":[[1,2],[3,4]]}`
+	var data map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"id":                   "12345678901234",
+		"$.id":                 "12345678901234",
+		"name":                 "a",
+		"data.user.email":      "a@b.c",
+		"$.data.user.verified": "true",
+		"items[1].id":          "y",
+		"matrix[1][0]":         "3",
+		"data.user":            "",
+		"items[2].id":          "",
+		"missing":              "",
+		"":                     "",
+		"items[a]":             "",
+	}
+	for path, want := range cases {
+		if got := JsonPathString(data, path); got != want {
+			t.Errorf("JsonPathString(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	var plain map[string]interface{}
+	_ = json.Unmarshal([]byte(raw), &plain)
+	if got := JsonPathString(plain, "id"); got != "12345678901234" {
+		t.Errorf("float id = %q", got)
+	}
+}